@@ -0,0 +1,255 @@
+// Package api is the JSON schema of the server's versioned api (/api/v1), the
+// types here are what is sent and received, store types are never exposed
+// directly. The OpenAPI document is generated from them, see Spec.
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/lukasmwerner/mark/store"
+)
+
+// Bookmark is a saved link as it is returned by the api.
+type Bookmark struct {
+	Id          int64    `json:"id" doc:"Unique id of the bookmark"`
+	Url         string   `json:"url" doc:"The bookmarked url, after redirects unless it was saved with keep_original"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Summary     string   `json:"summary" doc:"Generated from the page text by mark summarize, read only"`
+
+	Canonical   string `json:"canonical" doc:"Canonical url of the page, read only"`
+	Favicon     string `json:"favicon" doc:"Read only"`
+	SiteName    string `json:"site_name" doc:"Read only"`
+	Author      string `json:"author" doc:"Read only"`
+	Published   string `json:"published" doc:"When the page was published, as given by the page, read only"`
+	OriginalUrl string `json:"original_url" doc:"The url as it was submitted, read only"`
+
+	LastStatus  int    `json:"last_status" doc:"Http status from the last mark check, 0 if it could not be loaded or was never checked, read only"`
+	LastChecked string `json:"last_checked" doc:"RFC 3339 time of the last mark check, read only"`
+	RedirectUrl string `json:"redirect_url" doc:"Where the url redirected to on the last mark check, read only"`
+	CreatedAt   string `json:"created_at" doc:"RFC 3339 time the bookmark was saved, empty for old bookmarks, read only"`
+}
+
+// NewBookmark is the body for creating a bookmark.
+type NewBookmark struct {
+	Url         string   `json:"url" doc:"Absolute url to bookmark"`
+	Title       string   `json:"title,omitempty" doc:"Fetched from the page with enrich=true when left out"`
+	Description string   `json:"description,omitempty" doc:"Fetched from the page with enrich=true when left out"`
+	Tags        []string `json:"tags,omitempty" doc:"Tags can not contain commas, tagging rules add to them"`
+}
+
+// BookmarkPatch documents the fields a JSON merge patch (RFC 7386) may change,
+// fields that are left out keep their value and null clears them. It has to
+// list the same fields as ApplyPatch.
+type BookmarkPatch struct {
+	Url         string   `json:"url,omitempty" doc:"Absolute url, can not be cleared"`
+	Title       string   `json:"title,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty" doc:"Replaces all tags, null removes them"`
+}
+
+// SearchResult is a bookmark matching a search.
+type SearchResult struct {
+	Bookmark
+	Score                float64 `json:"score" doc:"Higher for better matches"`
+	Snippet              string  `json:"snippet,omitempty" doc:"Part of the page text that matched, with content=true"`
+	TitleHighlight       string  `json:"title_highlight,omitempty" doc:"Title with matches in <mark>, with highlight=true"`
+	DescriptionHighlight string  `json:"description_highlight,omitempty" doc:"Description with matches in <mark>, with highlight=true"`
+	Fuzzy                bool    `json:"fuzzy,omitempty" doc:"Only matched with typo tolerance"`
+}
+
+// BookmarkPage is a page of a listing.
+type BookmarkPage struct {
+	Bookmarks  []Bookmark `json:"bookmarks"`
+	NextCursor string     `json:"next_cursor,omitempty" doc:"Pass as cursor to get the next page, left out on the last page"`
+}
+
+type Tag struct {
+	Name  string `json:"name"`
+	Count int    `json:"count" doc:"Number of bookmarks with the tag"`
+}
+
+// Page is what was found on a page before it is saved.
+type Page struct {
+	Url         string    `json:"url" doc:"Where the page ended up after redirects, or its canonical url"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	SiteName    string    `json:"site_name"`
+	Favicon     string    `json:"favicon"`
+	Keywords    []string  `json:"keywords" doc:"Keywords from the page's metadata, suggestions for tags"`
+	Existing    *Bookmark `json:"existing,omitempty" doc:"The bookmark already saved for the page"`
+}
+
+type TagSuggestionRequest struct {
+	Url         string   `json:"url"`
+	Title       string   `json:"title,omitempty" doc:"Fetched from the page along with the description when both are left out"`
+	Description string   `json:"description,omitempty"`
+	Keywords    []string `json:"keywords,omitempty" doc:"Keywords from the page's metadata"`
+}
+
+type TagSuggestions struct {
+	Tags []string `json:"tags"`
+}
+
+// SavedSearch is a named query, usable as @name in searches.
+type SavedSearch struct {
+	Name      string `json:"name"`
+	Query     string `json:"query"`
+	CreatedAt string `json:"created_at"`
+}
+
+// Collection is a saved search along with the bookmarks it matches.
+type Collection struct {
+	SavedSearch
+	Bookmarks []SearchResult `json:"bookmarks"`
+}
+
+// BulkRequest is the body for changing many bookmarks at once, the
+// operations are applied in order in one transaction.
+type BulkRequest struct {
+	Operations []BulkOperation `json:"operations"`
+}
+
+type BulkOperation struct {
+	Action string `json:"action" doc:"create, update or delete"`
+	Id     int64  `json:"id,omitempty" doc:"Bookmark to update or delete"`
+	// Bookmark is kept raw as it is a NewBookmark or a BookmarkPatch
+	// depending on the action
+	Bookmark json.RawMessage `json:"bookmark,omitempty" doc:"A NewBookmark to create or a BookmarkPatch to update with"`
+}
+
+type BulkResponse struct {
+	Ids []int64 `json:"ids" doc:"Id of the bookmark each operation was on, in order"`
+}
+
+// Error is the body of every error response.
+type Error struct {
+	Error string `json:"error"`
+	Field string `json:"field,omitempty" doc:"The invalid field, for validation errors"`
+	Index *int   `json:"index,omitempty" doc:"The bulk operation that failed"`
+}
+
+// ValidationError is a field with an invalid value.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+func invalid(field, format string, a ...any) *ValidationError {
+	return &ValidationError{Field: field, Message: fmt.Sprintf(format, a...)}
+}
+
+func FromBookmark(b store.Bookmark) Bookmark {
+	tags := b.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return Bookmark{
+		Id:          int64(b.Id),
+		Url:         b.Url,
+		Title:       b.Title,
+		Description: b.Description,
+		Tags:        tags,
+		Summary:     b.Summary,
+		Canonical:   b.Canonical,
+		Favicon:     b.Favicon,
+		SiteName:    b.SiteName,
+		Author:      b.Author,
+		Published:   b.Published,
+		OriginalUrl: b.OriginalUrl,
+		LastStatus:  b.LastStatus,
+		LastChecked: b.LastChecked,
+		RedirectUrl: b.RedirectUrl,
+		CreatedAt:   b.CreatedAt,
+	}
+}
+
+func FromBookmarks(bookmarks []store.Bookmark) []Bookmark {
+	out := make([]Bookmark, 0, len(bookmarks))
+	for _, b := range bookmarks {
+		out = append(out, FromBookmark(b))
+	}
+	return out
+}
+
+func FromSearchResults(results []store.SearchResult) []SearchResult {
+	out := make([]SearchResult, 0, len(results))
+	for _, r := range results {
+		out = append(out, SearchResult{
+			Bookmark:             FromBookmark(r.Bookmark),
+			Score:                r.Score,
+			Snippet:              r.Snippet,
+			TitleHighlight:       r.TitleHighlight,
+			DescriptionHighlight: r.DescriptionHighlight,
+			Fuzzy:                r.Fuzzy,
+		})
+	}
+	return out
+}
+
+// Validate checks the bookmark and cleans up its tags.
+func (n *NewBookmark) Validate() error {
+	if err := ValidateUrl(n.Url); err != nil {
+		return err
+	}
+	tags, err := cleanTags(n.Tags)
+	if err != nil {
+		return err
+	}
+	n.Tags = tags
+	return nil
+}
+
+func (n NewBookmark) Bookmark() store.Bookmark {
+	return store.Bookmark{
+		Url:         n.Url,
+		Title:       n.Title,
+		Description: n.Description,
+		Tags:        n.Tags,
+	}
+}
+
+// ValidateUrl checks the url is an absolute http or https url, like
+// https://example.com. Anything else, like javascript: urls, could run in the
+// pages that link to it.
+func ValidateUrl(value string) error {
+	if strings.TrimSpace(value) == "" {
+		return invalid("url", "is required")
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return invalid("url", "is not a valid url")
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return invalid("url", "has to be an absolute http or https url, like https://example.com")
+	}
+	return nil
+}
+
+// cleanTags trims the tags and drops empty ones, commas are not allowed as
+// tags are stored as a comma separated list.
+func cleanTags(tags []string) ([]string, error) {
+	cleaned := []string{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		if strings.Contains(tag, ",") {
+			return nil, invalid("tags", "%q can not contain a comma", tag)
+		}
+		cleaned = append(cleaned, tag)
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,23 @@
+package api
+
+import "testing"
+
+func TestValidateUrl(t *testing.T) {
+	valid := []string{"https://example.com", "http://example.com/a?b=c#d", "https://127.0.0.1:1990/"}
+	for _, value := range valid {
+		if err := ValidateUrl(value); err != nil {
+			t.Errorf("ValidateUrl(%q) = %v", value, err)
+		}
+	}
+
+	invalid := []string{
+		"", " ", "example.com", "/relative", "https://", "ftp://example.com",
+		"javascript:alert(1)", "JavaScript:alert(1)", "data:text/html,<script>alert(1)</script>",
+		"mailto:me@example.com", "file:///etc/passwd",
+	}
+	for _, value := range invalid {
+		if err := ValidateUrl(value); err == nil {
+			t.Errorf("ValidateUrl(%q) accepted it", value)
+		}
+	}
+}
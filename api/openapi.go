@@ -0,0 +1,263 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Version of the api, bumped along with the /api/v<n> prefix.
+const Version = "1.0.0"
+
+// Prefix is where the versioned api is served.
+const Prefix = "/api/v1"
+
+// schemas are generated from these types so the document can not drift from
+// what the server sends and receives.
+var schemas = []any{
+	Bookmark{}, NewBookmark{}, BookmarkPatch{}, SearchResult{}, BookmarkPage{}, Page{},
+	Tag{}, TagSuggestionRequest{}, TagSuggestions{}, SavedSearch{}, Collection{},
+	BulkRequest{}, BulkOperation{}, BulkResponse{}, Error{},
+}
+
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+// Spec is the OpenAPI 3.1 document for the api.
+func Spec() map[string]any {
+	components := map[string]any{}
+	for _, v := range schemas {
+		t := reflect.TypeOf(v)
+		components[t.Name()] = structSchema(t)
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "mark",
+			"version":     Version,
+			"description": "Api of mark server for searching and managing bookmarks. Every error is returned as an Error.",
+		},
+		"servers":  []any{map[string]any{"url": Prefix}},
+		"security": []any{map[string]any{"bearer": []any{}}},
+		"components": map[string]any{
+			"schemas": components,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "An api key from mark keys new, read keys can only use GET routes (except /pages) and tag suggestions",
+				},
+			},
+		},
+		"paths": paths(),
+	}
+}
+
+func paths() map[string]any {
+	id := param("id", "path", "Id of the bookmark", map[string]any{"type": "integer"}, true)
+	limit := param("limit", "query", "Most results returned, 100 by default", map[string]any{"type": "integer", "minimum": 1, "maximum": 1000}, false)
+	flag := func(name, description string) map[string]any {
+		return param(name, "query", description, map[string]any{"type": "boolean"}, false)
+	}
+	text := func(name, description string) map[string]any {
+		return param(name, "query", description, map[string]any{"type": "string"}, false)
+	}
+	archiveFormat := param("format", "query", "html (the default) or text", map[string]any{"type": "string", "enum": []string{"html", "text"}}, false)
+
+	return map[string]any{
+		"/bookmarks": map[string]any{
+			"get": operation("listBookmarks", "List bookmarks a page at a time", []any{
+				limit,
+				text("cursor", "next_cursor of the previous page"),
+				param("sort", "query", "Order of the bookmarks, - sorts descending", map[string]any{"type": "string", "enum": []string{"id", "-id", "created", "-created", "title", "-title", "url", "-url"}}, false),
+				text("tag", "Only bookmarks with this tag"),
+				text("url", "Only the bookmark saved under this url"),
+			}, nil, "200", "A page of bookmarks", ref("BookmarkPage")),
+			"post": operation("createBookmark", "Save a bookmark", []any{
+				flag("enrich", "Fetch the page after saving to fill in what was left out"),
+				flag("keep_original", "Keep the submitted url instead of the one after redirects"),
+			}, ref("NewBookmark"), "201", "The saved bookmark", ref("Bookmark")),
+		},
+		"/bookmarks/{id}": map[string]any{
+			"get": operation("getBookmark", "Get a bookmark", []any{id}, nil, "200", "The bookmark", ref("Bookmark")),
+			"patch": withContentType(operation("updateBookmark", "Change a bookmark with a JSON merge patch", []any{id},
+				ref("BookmarkPatch"), "200", "The changed bookmark", ref("Bookmark")), "application/merge-patch+json"),
+			"delete": operation("deleteBookmark", "Delete a bookmark and its archive", []any{id}, nil, "204", "Deleted", nil),
+		},
+		"/bookmarks/bulk": map[string]any{
+			"post": operation("bulkBookmarks", "Create, update and delete bookmarks in one transaction", nil,
+				ref("BulkRequest"), "200", "Every operation was applied", ref("BulkResponse")),
+		},
+		"/bookmarks/search": map[string]any{
+			"get": operation("searchBookmarks", "Search bookmarks, best matches first", []any{
+				text("q", "The query, filters like tag:go and @saved searches work here, empty lists everything"),
+				limit,
+				flag("content", "Also search the text of the pages"),
+				flag("raw", "Pass the query to sqlite FTS5 unchanged"),
+				flag("fuzzy", "Add typo tolerant matches"),
+				flag("prefix", "Match the last word as a prefix, for searching as you type"),
+				flag("semantic", "Rank by meaning with embeddings from mark embed"),
+				flag("blend", "Combine keyword and semantic ranking"),
+				flag("highlight", "Mark matches in title_highlight and description_highlight"),
+			}, nil, "200", "Matching bookmarks", arrayOf(ref("SearchResult"))),
+		},
+		"/bookmarks/{id}/archive": map[string]any{
+			"get": map[string]any{
+				"operationId": "getArchive",
+				"summary":     "Get the offline copy of a bookmarked page",
+				"parameters":  []any{id},
+				"responses": withErrors(map[string]any{
+					"200": map[string]any{
+						"description": "The archived page",
+						"content": map[string]any{
+							"text/html":  map[string]any{"schema": map[string]any{"type": "string"}},
+							"text/plain": map[string]any{"schema": map[string]any{"type": "string"}},
+						},
+					},
+				}),
+			},
+			"post": operation("archiveBookmark", "Fetch and archive the page", []any{id, archiveFormat}, nil, "204", "Archived", nil),
+		},
+		"/pages": map[string]any{
+			"get": operation("getPage", "Fetch a page to fill in a bookmark before saving it, needs a write key", []any{
+				param("url", "query", "Absolute url of a page on a public address", map[string]any{"type": "string"}, true),
+			}, nil, "200", "What was found on the page", ref("Page")),
+		},
+		"/tags": map[string]any{
+			"get": operation("listTags", "Every tag in use, the most used first", nil, nil, "200", "The tags", arrayOf(ref("Tag"))),
+		},
+		"/tags/suggest": map[string]any{
+			"post": operation("suggestTags", "Suggest tags for a page", []any{
+				flag("fetch", "Fetch the page when only the url is given, true by default. Only done for write keys and public addresses"),
+			}, ref("TagSuggestionRequest"), "200", "The suggested tags", ref("TagSuggestions")),
+		},
+		"/collections": map[string]any{
+			"get": operation("listCollections", "List the saved searches", nil, nil, "200", "The saved searches", arrayOf(ref("SavedSearch"))),
+		},
+		"/collections/{name}": map[string]any{
+			"get": operation("getCollection", "A saved search with the bookmarks it matches", []any{
+				param("name", "path", "Name of the saved search", map[string]any{"type": "string"}, true),
+			}, nil, "200", "The saved search and its bookmarks", ref("Collection")),
+		},
+	}
+}
+
+func operation(id, summary string, params []any, body map[string]any, status, description string, response map[string]any) map[string]any {
+	op := map[string]any{
+		"operationId": id,
+		"summary":     summary,
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	if body != nil {
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": body}},
+		}
+	}
+	ok := map[string]any{"description": description}
+	if response != nil {
+		ok["content"] = map[string]any{"application/json": map[string]any{"schema": response}}
+	}
+	op["responses"] = withErrors(map[string]any{status: ok})
+	return op
+}
+
+// withContentType accepts the request body as another content type too.
+func withContentType(op map[string]any, contentType string) map[string]any {
+	content := op["requestBody"].(map[string]any)["content"].(map[string]any)
+	content[contentType] = content["application/json"]
+	return op
+}
+
+func withErrors(responses map[string]any) map[string]any {
+	responses["default"] = map[string]any{
+		"description": "An error",
+		"content":     map[string]any{"application/json": map[string]any{"schema": ref("Error")}},
+	}
+	return responses
+}
+
+func param(name, in, description string, schema map[string]any, required bool) map[string]any {
+	return map[string]any{
+		"name":        name,
+		"in":          in,
+		"description": description,
+		"required":    required,
+		"schema":      schema,
+	}
+}
+
+func ref(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+func arrayOf(items map[string]any) map[string]any {
+	return map[string]any{"type": "array", "items": items}
+}
+
+// structSchema describes the json encoding of a struct, fields without
+// omitempty are required and the doc tag is their description.
+func structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	addFields(t, properties, &required)
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func addFields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if f.Anonymous && tag == "" {
+			// embedded structs are flattened like encoding/json does
+			addFields(f.Type, properties, required)
+			continue
+		}
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+
+		schema := typeSchema(f.Type)
+		if doc := f.Tag.Get("doc"); doc != "" {
+			schema["description"] = doc
+		}
+		properties[name] = schema
+		if !strings.Contains(options, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func typeSchema(t reflect.Type) map[string]any {
+	if t == rawMessageType {
+		return map[string]any{}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice:
+		return arrayOf(typeSchema(t.Elem()))
+	case reflect.Struct:
+		return ref(t.Name())
+	}
+	return map[string]any{}
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/lukasmwerner/mark/store"
+)
+
+// readOnly are the fields of a Bookmark a patch can not change.
+var readOnly = map[string]bool{
+	"id": true, "summary": true, "canonical": true, "favicon": true,
+	"site_name": true, "author": true, "published": true, "original_url": true,
+	"last_status": true, "last_checked": true, "redirect_url": true, "created_at": true,
+}
+
+// ApplyPatch applies a JSON merge patch (RFC 7386) to the bookmark, only the
+// fields in BookmarkPatch can be changed. Errors are *ValidationError.
+func ApplyPatch(bm *store.Bookmark, patch []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &fields); err != nil || fields == nil {
+		return invalid("", "the patch has to be a json object")
+	}
+
+	for name, raw := range fields {
+		null := bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+		switch name {
+		case "url":
+			var value string
+			if null {
+				return invalid(name, "can not be removed")
+			}
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return invalid(name, "has to be a string")
+			}
+			if err := ValidateUrl(value); err != nil {
+				return err
+			}
+			bm.Url = value
+		case "title":
+			if err := patchString(name, raw, null, &bm.Title); err != nil {
+				return err
+			}
+		case "description":
+			if err := patchString(name, raw, null, &bm.Description); err != nil {
+				return err
+			}
+		case "tags":
+			var tags []string
+			if !null {
+				if err := json.Unmarshal(raw, &tags); err != nil {
+					return invalid(name, "has to be a list of strings")
+				}
+			}
+			tags, err := cleanTags(tags)
+			if err != nil {
+				return err
+			}
+			bm.Tags = tags
+		default:
+			if readOnly[name] {
+				return invalid(name, "is read only")
+			}
+			return invalid(name, "is not a field of a bookmark")
+		}
+	}
+	return nil
+}
+
+func patchString(name string, raw json.RawMessage, null bool, field *string) error {
+	if null {
+		*field = ""
+		return nil
+	}
+	if err := json.Unmarshal(raw, field); err != nil {
+		return invalid(name, "has to be a string")
+	}
+	return nil
+}
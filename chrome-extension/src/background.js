@@ -171,16 +171,16 @@ async function quickSave(tab) {
 
         // Prepare bookmark data
         const bookmark = {
-          Url: response.url,
-          Title: response.title,
-          Description: response.description,
-          Tags: autoTags || [], // Use generated tags
+          url: response.url,
+          title: response.title,
+          description: response.description,
+          tags: autoTags || [], // Use generated tags
         };
 
         try {
           // Send to API
           const apiResponse = await fetch(
-            "http://localhost:1990/api/bookmarks",
+            "http://localhost:1990/api/v1/bookmarks",
             {
               method: "POST",
               headers: {
@@ -192,13 +192,13 @@ async function initializeDetailedSave() {
         }
 
         const bookmark = {
-          Url: pageInfo.url,
-          Title: pageInfo.title,
-          Description: document.getElementById("description").value,
-          Tags: Array.from(tags),
+          url: pageInfo.url,
+          title: pageInfo.title,
+          description: document.getElementById("description").value,
+          tags: Array.from(tags),
         };
 
-        const response = await fetch("http://localhost:1990/api/bookmarks", {
+        const response = await fetch("http://localhost:1990/api/v1/bookmarks", {
           method: "POST",
           headers: {
             "Content-Type": "application/json",
@@ -7,18 +7,30 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"net/http"
 	"net/url"
+	"slices"
 	"strings"
+	"time"
 
-	"github.com/PuerkitoBio/goquery"
+	"github.com/charmbracelet/huh"
+	"github.com/lukasmwerner/mark/metadata"
 	"github.com/lukasmwerner/mark/store"
+	"github.com/lukasmwerner/mark/summarizer"
+	"github.com/lukasmwerner/mark/tagger"
 	"github.com/spf13/cobra"
 )
 
 var tags []string
 var title string
 var description string
+var offline bool
+var fetchTimeout time.Duration
+var fetchMaxSize int64
+var fetchUserAgent string
+var keepOriginal bool
+var archive bool
+var suggestTags bool
+var summarize bool
 
 // addCmd represents the add command
 var addCmd = &cobra.Command{
@@ -26,7 +38,8 @@ var addCmd = &cobra.Command{
 	Short: "Add a bookmark",
 	Long: `Adds a new bookmark to the bookmark manager.
 
-> NOTICE: This method may call out to the network to gather more info about the page
+> NOTICE: This method may call out to the network to gather more info about the page,
+> use --offline to skip this
 
 Example:
 mark add [--tags list,of,seperated,tags] url`,
@@ -54,30 +67,92 @@ mark add [--tags list,of,seperated,tags] url`,
 
 		defer db.Close()
 
-		if title == "" {
-			title = fetchTitle(link)
-		}
-		fmt.Println("title", title)
+		fetcher, fetched, page := fetchForAdd(link)
 
-		if description == "" {
-			description = fetchDescription(link)
+		text := ""
+		if fetched != nil && fetched.Doc != nil {
+			text = metadata.ReadableText(fetched.Doc)
 		}
 
-		fmt.Println("desc", description)
-
 		bm := store.Bookmark{
 			Url:         link.String(),
 			Tags:        tags,
 			Title:       title,
 			Description: description,
 		}
+		enrichBookmark(&bm, page, keepOriginal)
+		bm.Tags = loadRules(db).Apply(bm.Tags, rulesPage(bm, page.ContentType))
+
+		fmt.Println("title", bm.Title)
+		fmt.Println("desc", bm.Description)
+		if bm.Url != bm.OriginalUrl {
+			fmt.Println("resolved", bm.Url)
+		}
+
+		if len(page.Keywords) > 0 && !suggestTags {
+			fmt.Println("suggested tags", strings.Join(page.Keywords, ","))
+		}
+
+		existing, found, err := store.FindDuplicate(db, bm.Url, bm.OriginalUrl, bm.Canonical)
+		if err != nil {
+			log.Fatalln("unable to check for duplicates: ", err.Error())
+			return
+		}
+		if found {
+			fmt.Println("already saved as", existing.Url)
+			return
+		}
+
+		if suggestTags {
+			bm.Tags, err = pickSuggestedTags(db, bm, tagInput(bm, page))
+			if err == huh.ErrUserAborted {
+				return
+			}
+			if err != nil {
+				fmt.Println("unable to suggest tags:", err.Error())
+			}
+		}
+
+		if summarize {
+			if text == "" {
+				fmt.Println("unable to summarize, the page was not fetched")
+			} else {
+				in := summarizer.Input{Url: bm.Url, Title: bm.Title, Text: text}
+				bm.Summary, err = summarizer.FromEnv().Summarize(in)
+				if err != nil {
+					fmt.Println("unable to summarize page:", err.Error())
+				} else {
+					fmt.Println("summary", bm.Summary)
+				}
+			}
+		}
 
-		_, err = store.InsertBookmark(db, bm)
+		id, err := store.InsertBookmark(db, bm)
 		if err != nil {
 			log.Fatalln("unable to save bookmark: ", err.Error())
 			return
 		}
 
+		if text != "" {
+			err = store.SaveContent(db, id, text)
+			if err != nil {
+				fmt.Println("unable to index page content:", err.Error())
+			}
+		}
+
+		if archive {
+			if fetched == nil {
+				fmt.Println("unable to archive, the page was not fetched")
+				return
+			}
+			err = saveArchive(db, fetcher, fetched, id, archiveFormat)
+			if err != nil {
+				fmt.Println("unable to archive page:", err.Error())
+				return
+			}
+			fmt.Println("archived", archiveFormat)
+		}
+
 	},
 }
 
@@ -96,58 +171,103 @@ func init() {
 	addCmd.Flags().StringSliceVar(&tags, "tags", []string{}, "Tags for bookmark")
 	addCmd.Flags().StringVarP(&title, "title", "t", "", "Overrides the title from the scraper")
 	addCmd.Flags().StringVarP(&description, "description", "d", "", "Sets the link's description")
+	addCmd.Flags().BoolVar(&keepOriginal, "keep-original", false, "Saves the url as given instead of the url after redirects or the canonical url")
+	addCmd.Flags().BoolVar(&archive, "archive", false, "Saves an offline copy of the page")
+	addCmd.Flags().StringVar(&archiveFormat, "archive-format", metadata.FormatHTML, "Archive format: html,text")
+	addCmd.Flags().BoolVar(&suggestTags, "suggest-tags", false, "Suggests tags with a local LLM (ollama, see MARK_TAG_MODEL) or from the page and your existing tags")
+	addCmd.Flags().BoolVar(&summarize, "summarize", false, "Writes a short summary of the page with a local LLM (ollama, see MARK_SUMMARY_MODEL)")
+	addCmd.Flags().BoolVar(&offline, "offline", false, "Skips fetching the page, nothing is sent over the network")
+	addCmd.Flags().DurationVar(&fetchTimeout, "timeout", metadata.DefaultTimeout, "How long to wait for the page to load")
+	addCmd.Flags().Int64Var(&fetchMaxSize, "max-size", metadata.DefaultMaxBodySize, "Maximum number of bytes read from the page")
+	addCmd.Flags().StringVar(&fetchUserAgent, "user-agent", metadata.DefaultUserAgent, "User agent sent when fetching the page")
 }
 
-func fetchTitle(u *url.URL) string {
-	resp, err := http.Get(u.String())
-	if err != nil {
-		return ""
+// fetchForAdd downloads the page with the limits of the flags, nothing is
+// fetched with --offline. fetched is nil when the page was not downloaded.
+func fetchForAdd(link *url.URL) (*metadata.Fetcher, *metadata.Page, metadata.Metadata) {
+	fetcher := metadata.NewFetcher(fetchTimeout, fetchMaxSize, fetchUserAgent)
+	if offline {
+		return fetcher, nil, metadata.Metadata{}
 	}
-	defer resp.Body.Close()
-	fmt.Println("\tstatus", resp.Status)
-	if resp.StatusCode != 200 {
-		return ""
+	fetched, err := fetcher.Page(link)
+	if err != nil {
+		fmt.Println("\tunable to fetch page:", err.Error())
+		return fetcher, nil, metadata.Metadata{}
 	}
+	return fetcher, fetched, fetched.Metadata()
+}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return ""
+// enrichBookmark fills in anything missing on the bookmark from the fetched
+// page. The submitted url is kept as OriginalUrl and, unless keepOriginal is
+// set, replaced with the resolved one.
+func enrichBookmark(bm *store.Bookmark, page metadata.Metadata, keepOriginal bool) {
+	if bm.Title == "" {
+		bm.Title = page.Title
+	}
+	if bm.Description == "" {
+		bm.Description = page.Description
+	}
+	if bm.Canonical == "" {
+		bm.Canonical = page.Canonical
+	}
+	if bm.Favicon == "" {
+		bm.Favicon = page.Favicon
+	}
+	if bm.SiteName == "" {
+		bm.SiteName = page.SiteName
+	}
+	if bm.Author == "" {
+		bm.Author = page.Author
+	}
+	if bm.Published == "" {
+		bm.Published = page.Published
 	}
 
-	title, exists := doc.Find(`meta[property='og:title']`).Attr("content")
-	if !exists {
-		fmt.Println("\tog title missing, returning title tag")
-		title := doc.Find("title").Text()
-		if title == "" {
-			fmt.Println("\ttitle tag missing, returning empty string")
-			return ""
-		}
-		return strings.TrimSpace(title)
+	if bm.OriginalUrl == "" {
+		bm.OriginalUrl = bm.Url
+	}
+	if resolved := page.Resolved(); resolved != "" && !keepOriginal {
+		bm.Url = resolved
 	}
-	return strings.TrimSpace(title)
+}
 
+// tagInput is everything known about the page for suggesting tags.
+func tagInput(bm store.Bookmark, page metadata.Metadata) tagger.Input {
+	return tagger.Input{
+		Url:         bm.Url,
+		Title:       bm.Title,
+		Description: bm.Description,
+		Keywords:    page.Keywords,
+	}
 }
 
-func fetchDescription(u *url.URL) string {
-	resp, err := http.Get(u.String())
+// pickSuggestedTags lets the user choose from the suggestions, which are all
+// selected to start with, and returns them along with the bookmark's tags.
+func pickSuggestedTags(db *store.DB, bm store.Bookmark, in tagger.Input) ([]string, error) {
+	vocabulary, err := store.TagNames(db)
 	if err != nil {
-		return ""
+		return bm.Tags, err
 	}
-	defer resp.Body.Close()
-	fmt.Println("\tstatus", resp.Status)
-	if resp.StatusCode != 200 {
-		return ""
+	suggested, err := tagger.FromEnv().Suggest(in, vocabulary)
+	if err != nil {
+		fmt.Println("\tunable to ask the model for tags, using the page and existing tags:", err.Error())
 	}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return ""
+	options := []huh.Option[string]{}
+	for _, tag := range suggested {
+		if !slices.Contains(bm.Tags, tag) {
+			options = append(options, huh.NewOption(tag, tag).Selected(true))
+		}
+	}
+	if len(options) == 0 {
+		fmt.Println("no tags to suggest")
+		return bm.Tags, nil
 	}
 
-	desc, exists := doc.Find(`meta[property='og:description']`).Attr("content")
-	if !exists {
-		fmt.Println("\tog desc missing, returning nothing")
-		return ""
+	picked := []string{}
+	err = huh.NewMultiSelect[string]().Title("Tags").Options(options...).Value(&picked).Run()
+	if err != nil {
+		return bm.Tags, err
 	}
-	return strings.TrimSpace(desc)
+	return append(bm.Tags, picked...), nil
 }
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lukasmwerner/mark/metadata"
+)
+
+// setFetchFlags sets the flags of mark add for the test.
+func setFetchFlags(t *testing.T, skip bool, timeout time.Duration) {
+	t.Helper()
+	offline, fetchTimeout = skip, timeout
+	fetchMaxSize, fetchUserAgent = metadata.DefaultMaxBodySize, metadata.DefaultUserAgent
+	t.Cleanup(func() { offline, fetchTimeout = false, metadata.DefaultTimeout })
+}
+
+func TestFetchForAddOffline(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		fmt.Fprint(w, "<title>Online</title>")
+	}))
+	defer server.Close()
+	link, _ := url.Parse(server.URL)
+
+	setFetchFlags(t, true, metadata.DefaultTimeout)
+	if _, fetched, page := fetchForAdd(link); fetched != nil || page.Title != "" {
+		t.Errorf("--offline fetched %q", page.Title)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("--offline sent %d requests", n)
+	}
+
+	setFetchFlags(t, false, metadata.DefaultTimeout)
+	if _, fetched, page := fetchForAdd(link); fetched == nil || page.Title != "Online" {
+		t.Errorf("fetched title %q, want Online", page.Title)
+	}
+}
+
+func TestFetchForAddTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(5 * time.Second):
+		case <-done:
+		}
+		fmt.Fprint(w, "<title>Slow</title>")
+	}))
+	defer server.Close()
+	defer close(done)
+	link, _ := url.Parse(server.URL)
+
+	setFetchFlags(t, false, 100*time.Millisecond)
+	start := time.Now()
+	if _, fetched, _ := fetchForAdd(link); fetched != nil {
+		t.Error("a page slower than --timeout was fetched")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("--timeout 100ms gave up after %s", elapsed)
+	}
+}
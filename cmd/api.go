@@ -0,0 +1,257 @@
+/*
+Copyright © 2025 Lukas Werner <me@lukaswerner.com>
+*/
+package cmd
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/lukasmwerner/mark/api"
+	"github.com/lukasmwerner/mark/embeddings"
+	"github.com/lukasmwerner/mark/metadata"
+	"github.com/lukasmwerner/mark/store"
+)
+
+const (
+	// defaultPageSize is how many bookmarks a list or search returns when no
+	// limit is given
+	defaultPageSize = 100
+	maxPageSize     = 1000
+	// maxBulkOperations keeps a single bulk request from holding the database
+	// for too long
+	maxBulkOperations = 1000
+)
+
+// clientFetcher fetches the urls api clients send, it can not be pointed at
+// this machine or its network.
+var clientFetcher = metadata.NewPublicFetcher(metadata.DefaultTimeout, metadata.DefaultMaxBodySize, metadata.DefaultUserAgent)
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		fmt.Println("unable to write response:", err.Error())
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, api.Error{Error: message})
+}
+
+// decodeStrict decodes a json body, fields the type does not have are an
+// error instead of being ignored.
+func decodeStrict(r io.Reader, v any) error {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// writeInvalid answers with the field that failed validation, if it did.
+func writeInvalid(w http.ResponseWriter, err error) {
+	var invalid *api.ValidationError
+	if errors.As(err, &invalid) {
+		writeJSON(w, http.StatusBadRequest, api.Error{Error: invalid.Error(), Field: invalid.Field})
+		return
+	}
+	writeError(w, http.StatusBadRequest, err.Error())
+}
+
+// router is where routes are registered, an *http.ServeMux.
+type router interface {
+	Handle(pattern string, handler http.Handler)
+}
+
+// handleBoth serves a route that is the same in the versioned api at both
+// /api and /api/v1.
+func handleBoth(mux router, pattern string, handler http.Handler) {
+	mux.Handle(pattern, handler)
+	method, route, _ := strings.Cut(pattern, " ")
+	mux.Handle(method+" "+api.Prefix+strings.TrimPrefix(route, "/api"), handler)
+}
+
+// pathId reads the {id} of the route.
+func pathId(r *http.Request) (store.BookmarkId, error) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return 0, errors.New("Invalid id")
+	}
+	return store.BookmarkId(id), nil
+}
+
+// pageLimit reads the limit parameter, defaultPageSize when it is not given.
+func pageLimit(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return defaultPageSize, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > maxPageSize {
+		return 0, fmt.Errorf("Invalid limit, use 1 to %d", maxPageSize)
+	}
+	return limit, nil
+}
+
+// bulkOperations turns the request into store operations. newBookmark reads
+// the bookmark to create and patch applies the changes of an update, rules
+// are applied to created bookmarks like they are for POST /api/bookmarks.
+func bulkOperations(db *store.DB, req api.BulkRequest, newBookmark func(json.RawMessage) (store.Bookmark, error), patch func(*store.Bookmark, []byte) error) ([]store.BulkOperation, *api.Error) {
+	ops := []store.BulkOperation{}
+	rules := loadRules(db)
+	for i, requested := range req.Operations {
+		op := store.BulkOperation{Action: requested.Action, Id: store.BookmarkId(requested.Id)}
+		fail := func(err error) *api.Error {
+			e := &api.Error{Error: err.Error(), Index: &i}
+			var invalid *api.ValidationError
+			if errors.As(err, &invalid) {
+				e.Field = invalid.Field
+			}
+			return e
+		}
+
+		switch requested.Action {
+		case store.BulkCreate:
+			bookmark, err := newBookmark(requested.Bookmark)
+			if err != nil {
+				return nil, fail(err)
+			}
+			bookmark.Tags = rules.Apply(bookmark.Tags, rulesPage(bookmark, ""))
+			op.Bookmark = bookmark
+		case store.BulkUpdate:
+			if len(requested.Bookmark) == 0 {
+				return nil, fail(errors.New("Missing bookmark"))
+			}
+			changes := requested.Bookmark
+			op.Modify = func(bm *store.Bookmark) error {
+				return patch(bm, changes)
+			}
+		case store.BulkDelete:
+		default:
+			return nil, fail(fmt.Errorf("Unknown action %q, use create, update or delete", requested.Action))
+		}
+		if requested.Action != store.BulkCreate && requested.Id == 0 {
+			return nil, fail(errors.New("Missing id"))
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// runBulk applies the operations and answers with the failed operation if
+// one of them did not go through. ok is false when an error was written.
+func runBulk(w http.ResponseWriter, db *store.DB, ops []store.BulkOperation) ([]store.BookmarkId, bool) {
+	ids, err := store.Bulk(db, ops)
+	var bulkErr *store.BulkError
+	if errors.As(err, &bulkErr) {
+		e := api.Error{Error: bulkErr.Err.Error(), Index: &bulkErr.Index}
+		status := http.StatusBadRequest
+		var invalid *api.ValidationError
+		if errors.As(err, &invalid) {
+			e.Field = invalid.Field
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			status, e.Error = http.StatusNotFound, "Bookmark not found"
+		}
+		writeJSON(w, status, e)
+		return nil, false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	return ids, true
+}
+
+// runSearch searches with the options of the request on top of the server's,
+// on failure the status is what to answer with.
+func runSearch(db *store.DB, provider embeddings.Provider, searchOpts store.SearchOptions, r *http.Request) ([]store.SearchResult, int, error) {
+	// an empty query lists everything, up to the limit
+	query := r.URL.Query().Get("q")
+	limit, err := pageLimit(r)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	opts := searchOpts
+	opts.Limit = limit
+	opts.Content = r.URL.Query().Get("content") == "true"
+	opts.Raw = opts.Raw || r.URL.Query().Get("raw") == "true"
+	opts.Fuzzy = opts.Fuzzy || r.URL.Query().Get("fuzzy") == "true"
+	opts.Prefix = opts.Prefix || r.URL.Query().Get("prefix") == "true"
+	if r.URL.Query().Get("highlight") == "true" {
+		opts.HighlightStart, opts.HighlightEnd = "<mark>", "</mark>"
+	}
+
+	var results []store.SearchResult
+	semantic, blend := r.URL.Query().Get("semantic") == "true", r.URL.Query().Get("blend") == "true"
+	if semantic || blend {
+		results, err = semanticSearch(db, provider, query, opts, blend)
+	} else {
+		results, err = store.Search(db, query, opts)
+	}
+	if err != nil && (opts.Raw || strings.HasPrefix(query, store.RawPrefix)) {
+		// raw queries can be invalid fts5 syntax
+		return nil, http.StatusBadRequest, err
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, http.StatusOK, nil
+}
+
+// createBookmark saves a bookmark submitted to the server, with enrich=true
+// the page is fetched after it was saved. On failure the status is what to
+// answer with.
+func createBookmark(db *store.DB, bookmark store.Bookmark, r *http.Request) (store.BookmarkId, bool, int, error) {
+	enrich := r.URL.Query().Get("enrich") == "true" && needsEnrichment(bookmark)
+	keepOriginal := r.URL.Query().Get("keep_original") == "true"
+	var link *url.URL
+	if enrich {
+		var err error
+		link, err = url.Parse(bookmark.Url)
+		if err != nil || bookmark.Url == "" {
+			return 0, false, http.StatusBadRequest, errors.New("Invalid url")
+		}
+
+		existing, found, err := store.FindDuplicate(db, bookmark.Url)
+		if err != nil {
+			return 0, false, http.StatusInternalServerError, err
+		}
+		if found {
+			return 0, false, http.StatusConflict, errors.New("Bookmark already exists: " + existing.Url)
+		}
+		bookmark.OriginalUrl = bookmark.Url
+	}
+
+	// rules are read on every request so edits apply without a restart
+	bookmark.Tags = loadRules(db).Apply(bookmark.Tags, rulesPage(bookmark, ""))
+
+	id, err := store.InsertBookmark(db, bookmark)
+	if err != nil {
+		return 0, false, http.StatusInternalServerError, err
+	}
+	if enrich {
+		go enrichInBackground(db, id, link, keepOriginal)
+	}
+	return id, enrich, http.StatusCreated, nil
+}
+
+// listOptions reads the listing parameters of the request.
+func listOptions(r *http.Request) (store.ListOptions, error) {
+	limit, err := pageLimit(r)
+	return store.ListOptions{
+		Tag:    r.URL.Query().Get("tag"),
+		Sort:   r.URL.Query().Get("sort"),
+		Limit:  limit,
+		Cursor: r.URL.Query().Get("cursor"),
+	}, err
+}
@@ -0,0 +1,248 @@
+/*
+Copyright © 2025 Lukas Werner <me@lukaswerner.com>
+*/
+package cmd
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/lukasmwerner/mark/api"
+	"github.com/lukasmwerner/mark/embeddings"
+	"github.com/lukasmwerner/mark/metadata"
+	"github.com/lukasmwerner/mark/store"
+)
+
+// handleV1 serves the routes of the versioned api that send or receive
+// bookmarks, which use the types in the api package instead of the store's.
+// The ones the unversioned api answers the same way are served at both with
+// handleBoth, the rest of the unversioned routes are in routes.
+func handleV1(mux router, db *store.DB, keys *store.KeyStore, searchOpts store.SearchOptions, provider embeddings.Provider) {
+	mux.Handle("GET "+api.Prefix+"/bookmarks", AuthRequired(keys, store.ScopeRead, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		opts, err := listOptions(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// a url is looked up directly, giving a page of one or none
+		if r.URL.Query().Has("url") {
+			page := api.BookmarkPage{Bookmarks: []api.Bookmark{}}
+			bookmark, err := store.GetBookmark(db, r.URL.Query().Get("url"))
+			if err != nil && err != sql.ErrNoRows {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			if err == nil {
+				page.Bookmarks = append(page.Bookmarks, api.FromBookmark(bookmark))
+			}
+			writeJSON(w, http.StatusOK, page)
+			return
+		}
+
+		bookmarks, next, err := store.ListPage(db, opts)
+		if errors.Is(err, store.ErrInvalidCursor) || errors.Is(err, store.ErrInvalidSort) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, api.BookmarkPage{Bookmarks: api.FromBookmarks(bookmarks), NextCursor: next})
+	})))
+
+	mux.Handle("POST "+api.Prefix+"/bookmarks", AuthRequired(keys, store.ScopeWrite, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var submitted api.NewBookmark
+		if err := decodeStrict(r.Body, &submitted); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := submitted.Validate(); err != nil {
+			writeInvalid(w, err)
+			return
+		}
+
+		id, _, status, err := createBookmark(db, submitted.Bookmark(), r)
+		if err != nil {
+			writeError(w, status, err.Error())
+			return
+		}
+		bookmark, err := store.GetBookmarkById(db, id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.Header().Set("Location", fmt.Sprintf("%s/bookmarks/%d", api.Prefix, id))
+		writeJSON(w, http.StatusCreated, api.FromBookmark(bookmark))
+	})))
+
+	handleBoth(mux, "GET /api/bookmarks/{id}", AuthRequired(keys, store.ScopeRead, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		bookmark, err := store.GetBookmarkById(db, id)
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "Bookmark not found")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, api.FromBookmark(bookmark))
+	})))
+
+	// the body is a json merge patch, fields that are left out are kept
+	handleBoth(mux, "PATCH /api/bookmarks/{id}", AuthRequired(keys, store.ScopeWrite, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		var patch json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var invalid error
+		bookmark, err := store.ModifyBookmark(db, id, func(bm *store.Bookmark) error {
+			invalid = api.ApplyPatch(bm, patch)
+			return invalid
+		})
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "Bookmark not found")
+			return
+		}
+		if invalid != nil {
+			writeInvalid(w, invalid)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, api.FromBookmark(bookmark))
+	})))
+
+	handleBoth(mux, "POST /api/bookmarks/bulk", AuthRequired(keys, store.ScopeWrite, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req api.BulkRequest
+		if err := decodeStrict(r.Body, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if len(req.Operations) > maxBulkOperations {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Too many operations, send at most %d", maxBulkOperations))
+			return
+		}
+		newBookmark := func(raw json.RawMessage) (store.Bookmark, error) {
+			var submitted api.NewBookmark
+			if err := decodeStrict(bytes.NewReader(raw), &submitted); err != nil {
+				return store.Bookmark{}, err
+			}
+			if err := submitted.Validate(); err != nil {
+				return store.Bookmark{}, err
+			}
+			return submitted.Bookmark(), nil
+		}
+		ops, invalid := bulkOperations(db, req, newBookmark, api.ApplyPatch)
+		if invalid != nil {
+			writeJSON(w, http.StatusBadRequest, invalid)
+			return
+		}
+
+		ids, ok := runBulk(w, db, ops)
+		if !ok {
+			return
+		}
+		response := api.BulkResponse{Ids: []int64{}}
+		for _, id := range ids {
+			response.Ids = append(response.Ids, int64(id))
+		}
+		writeJSON(w, http.StatusOK, response)
+	})))
+
+	handleBoth(mux, "GET /api/bookmarks/search", AuthRequired(keys, store.ScopeRead, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		results, status, err := runSearch(db, provider, searchOpts, r)
+		if err != nil {
+			writeError(w, status, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, api.FromSearchResults(results))
+	})))
+
+	// the save page fills in its form from here before anything is saved, it
+	// needs a write key as the server makes a request for the client
+	mux.Handle("GET "+api.Prefix+"/pages", AuthRequired(keys, store.ScopeWrite, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		submitted := r.URL.Query().Get("url")
+		if err := api.ValidateUrl(submitted); err != nil {
+			writeInvalid(w, err)
+			return
+		}
+		link, _ := url.Parse(submitted)
+		found, err := clientFetcher.Fetch(link)
+		if errors.Is(err, metadata.ErrPrivateAddress) {
+			writeInvalid(w, &api.ValidationError{Field: "url", Message: "is not a public address"})
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+
+		page := api.Page{
+			Url:         found.Resolved(),
+			Title:       found.Title,
+			Description: found.Description,
+			SiteName:    found.SiteName,
+			Favicon:     found.Favicon,
+			Keywords:    found.Keywords,
+		}
+		if page.Url == "" {
+			page.Url = submitted
+		}
+		if page.Keywords == nil {
+			page.Keywords = []string{}
+		}
+		existing, saved, err := store.FindDuplicate(db, submitted, found.URL, found.Canonical)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if saved {
+			bookmark := api.FromBookmark(existing)
+			page.Existing = &bookmark
+		}
+		writeJSON(w, http.StatusOK, page)
+	})))
+
+	handleBoth(mux, "GET /api/collections/{name}", AuthRequired(keys, store.ScopeRead, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		saved, err := store.GetSavedSearch(db, r.PathValue("name"))
+		if errors.Is(err, store.ErrSavedSearchNotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		results, err := store.Search(db, saved.Query, searchOpts)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, api.Collection{
+			SavedSearch: api.SavedSearch(saved),
+			Bookmarks:   api.FromSearchResults(results),
+		})
+	})))
+}
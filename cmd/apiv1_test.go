@@ -0,0 +1,411 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lukasmwerner/mark/api"
+	"github.com/lukasmwerner/mark/store"
+)
+
+// recordingMux remembers the patterns that were registered.
+type recordingMux struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func (m *recordingMux) Handle(pattern string, handler http.Handler) {
+	m.patterns = append(m.patterns, pattern)
+	m.ServeMux.Handle(pattern, handler)
+}
+
+// testServer registers the routes of mark server on a new mux, along with a
+// write key for calling them.
+func testServer(t *testing.T) (*store.DB, *recordingMux, string) {
+	t.Helper()
+	db := openTestDB(t)
+	keys, err := store.OpenKeys(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { keys.Close() })
+	secret, _, err := store.NewKey(keys, "test", store.ScopeWrite, time.Time{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := &recordingMux{ServeMux: http.NewServeMux()}
+	routes(mux, db, keys, store.SearchOptions{}, nil)
+	return db, mux, secret
+}
+
+// spec is the OpenAPI document as a client reads it.
+func spec(t *testing.T) map[string]any {
+	t.Helper()
+	b, err := json.Marshal(api.Spec())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	_, mux, _ := testServer(t)
+
+	documented := map[string]bool{}
+	for path, item := range spec(t)["paths"].(map[string]any) {
+		for method := range item.(map[string]any) {
+			documented[strings.ToUpper(method)+" "+api.Prefix+path] = true
+		}
+	}
+	served := map[string]bool{}
+	for _, pattern := range mux.patterns {
+		if strings.Contains(pattern, " "+api.Prefix+"/") {
+			served[pattern] = true
+		}
+	}
+
+	for pattern := range served {
+		if !documented[pattern] {
+			t.Errorf("%s is served but missing from the OpenAPI document", pattern)
+		}
+	}
+	for pattern := range documented {
+		if !served[pattern] {
+			t.Errorf("%s is in the OpenAPI document but not served", pattern)
+		}
+	}
+}
+
+func TestOpenAPISchemasAreUsed(t *testing.T) {
+	doc := spec(t)
+	components := doc["components"].(map[string]any)["schemas"].(map[string]any)
+
+	used := map[string]bool{}
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				name := strings.TrimPrefix(ref, "#/components/schemas/")
+				if !used[name] {
+					used[name] = true
+					walk(components[name])
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(doc["paths"])
+
+	for name := range components {
+		if !used[name] {
+			t.Errorf("schema %s is not sent or received by any route", name)
+		}
+	}
+}
+
+// apiCall is a request to the versioned api, its response is checked against
+// the OpenAPI document.
+type apiCall struct {
+	operation string
+	method    string
+	path      func() string
+	body      any
+}
+
+// TestOpenAPIMatchesHandlers calls every operation and checks the bodies sent
+// and received against the schemas. Handlers that decode a body have to reject
+// fields that are not in its schema.
+func TestOpenAPIMatchesHandlers(t *testing.T) {
+	t.Setenv("MARK_TAG_MODEL", "none")
+	db, mux, secret := testServer(t)
+	allowLocalFetches(t)
+	if err := store.SaveSearch(db, "go", "tag:go"); err != nil {
+		t.Fatal(err)
+	}
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><title>Go testing</title>
+<meta name="description" content="Writing tests in go">
+<meta name="keywords" content="go,testing"></head><body><p>Tables of tests</p></body></html>`)
+	}))
+	defer page.Close()
+
+	doc := spec(t)
+	components := doc["components"].(map[string]any)["schemas"].(map[string]any)
+	operations := map[string]map[string]any{}
+	for _, item := range doc["paths"].(map[string]any) {
+		for _, op := range item.(map[string]any) {
+			op := op.(map[string]any)
+			operations[op["operationId"].(string)] = op
+		}
+	}
+
+	var first, second int64
+	fixed := func(path string) func() string { return func() string { return path } }
+	bookmark := func(id *int64, suffix string) func() string {
+		return func() string { return fmt.Sprintf("/bookmarks/%d%s", *id, suffix) }
+	}
+	calls := []apiCall{
+		{"createBookmark", "POST", fixed("/bookmarks"), map[string]any{
+			"url": page.URL + "/one", "title": "One", "description": "The first", "tags": []string{"go", "testing"},
+		}},
+		{"bulkBookmarks", "POST", fixed("/bookmarks/bulk"), map[string]any{"operations": []any{
+			map[string]any{"action": "create", "bookmark": map[string]any{"url": page.URL + "/two", "title": "Two"}},
+			map[string]any{"action": "update", "id": 1, "bookmark": map[string]any{"description": "Changed"}},
+		}}},
+		{"listBookmarks", "GET", fixed("/bookmarks?limit=1&sort=-id"), nil},
+		{"getBookmark", "GET", bookmark(&first, ""), nil},
+		{"updateBookmark", "PATCH", bookmark(&first, ""), map[string]any{"title": "Uno", "tags": []string{"go"}}},
+		{"searchBookmarks", "GET", fixed("/bookmarks/search?q=uno&highlight=true"), nil},
+		{"archiveBookmark", "POST", bookmark(&first, "/archive"), nil},
+		{"getArchive", "GET", bookmark(&first, "/archive"), nil},
+		{"getPage", "GET", func() string { return "/pages?url=" + page.URL + "/one" }, nil},
+		{"listTags", "GET", fixed("/tags"), nil},
+		{"suggestTags", "POST", fixed("/tags/suggest?fetch=false"), map[string]any{
+			"url": page.URL + "/three", "title": "Go testing", "description": "Writing tests", "keywords": []string{"go"},
+		}},
+		{"listCollections", "GET", fixed("/collections"), nil},
+		{"getCollection", "GET", fixed("/collections/go"), nil},
+		{"deleteBookmark", "DELETE", bookmark(&second, ""), nil},
+	}
+
+	covered := map[string]bool{}
+	for _, call := range calls {
+		covered[call.operation] = true
+		op, ok := operations[call.operation]
+		if !ok {
+			t.Errorf("%s is not in the OpenAPI document", call.operation)
+			continue
+		}
+
+		var requestSchema map[string]any
+		if body, ok := op["requestBody"].(map[string]any); ok {
+			requestSchema = body["content"].(map[string]any)["application/json"].(map[string]any)["schema"].(map[string]any)
+			checkSchema(t, call.operation+" request", roundTrip(t, call.body), requestSchema, components)
+
+			// a field the schema does not have is rejected, so the
+			// handler decodes the documented type
+			probe := map[string]any{}
+			for k, v := range call.body.(map[string]any) {
+				probe[k] = v
+			}
+			probe["not_a_field"] = true
+			if w := serve(mux, secret, call.method, api.Prefix+call.path(), probe); w.Code != http.StatusBadRequest {
+				t.Errorf("%s accepted a field that is not in its schema: %d %s", call.operation, w.Code, w.Body)
+			}
+		}
+
+		w := serve(mux, secret, call.method, api.Prefix+call.path(), call.body)
+		status, response := documentedResponse(op)
+		if fmt.Sprint(w.Code) != status {
+			t.Fatalf("%s %s answered %d instead of %s: %s", call.method, call.path(), w.Code, status, w.Body)
+		}
+		if response == nil {
+			continue
+		}
+		var body any
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s did not answer with json: %v", call.operation, err)
+		}
+		checkSchema(t, call.operation+" response", body, response, components)
+
+		switch call.operation {
+		case "createBookmark":
+			first = int64(body.(map[string]any)["id"].(float64))
+		case "bulkBookmarks":
+			second = int64(body.(map[string]any)["ids"].([]any)[0].(float64))
+		case "listBookmarks":
+			if _, ok := body.(map[string]any)["next_cursor"]; !ok {
+				t.Errorf("listing one of two bookmarks has no next_cursor: %s", w.Body)
+			}
+		case "getPage":
+			if _, ok := body.(map[string]any)["existing"]; !ok {
+				t.Errorf("the page of a saved bookmark has no existing bookmark: %s", w.Body)
+			}
+		}
+	}
+
+	missing := []string{}
+	for id := range operations {
+		if !covered[id] {
+			missing = append(missing, id)
+		}
+	}
+	sort.Strings(missing)
+	if len(missing) > 0 {
+		t.Errorf("operations without a test call: %v", missing)
+	}
+}
+
+func serve(mux http.Handler, secret, method, path string, body any) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != nil {
+		b, _ := json.Marshal(body)
+		reader = bytes.NewReader(b)
+	}
+	r := httptest.NewRequest(method, path, reader)
+	r.Header.Set("Authorization", "Bearer "+secret)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	return w
+}
+
+// documentedResponse is the success status of the operation and the schema of
+// its json body, nil when it has none.
+func documentedResponse(op map[string]any) (string, map[string]any) {
+	for status, response := range op["responses"].(map[string]any) {
+		if status == "default" {
+			continue
+		}
+		content, ok := response.(map[string]any)["content"].(map[string]any)
+		if !ok {
+			return status, nil
+		}
+		body, ok := content["application/json"].(map[string]any)
+		if !ok {
+			return status, nil
+		}
+		return status, body["schema"].(map[string]any)
+	}
+	return "", nil
+}
+
+func roundTrip(t *testing.T, v any) any {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded any
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+// checkSchema reports where the value does not have the type of the schema,
+// misses a required property or has one the schema does not describe.
+func checkSchema(t *testing.T, where string, value any, schema map[string]any, components map[string]any) {
+	t.Helper()
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		checkSchema(t, where, value, components[name].(map[string]any), components)
+		return
+	}
+
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			t.Errorf("%s: %v is not an object", where, value)
+			return
+		}
+		properties := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := object[name.(string)]; !ok {
+				t.Errorf("%s: missing required %s", where, name)
+			}
+		}
+		for name, v := range object {
+			property, ok := properties[name]
+			if !ok {
+				t.Errorf("%s: %s is not in the schema", where, name)
+				continue
+			}
+			checkSchema(t, where+"."+name, v, property.(map[string]any), components)
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			t.Errorf("%s: %v is not an array", where, value)
+			return
+		}
+		for i, item := range items {
+			checkSchema(t, fmt.Sprintf("%s[%d]", where, i), item, schema["items"].(map[string]any), components)
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			t.Errorf("%s: %v is not a string", where, value)
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != float64(int64(n)) {
+			t.Errorf("%s: %v is not an integer", where, value)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			t.Errorf("%s: %v is not a number", where, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			t.Errorf("%s: %v is not a boolean", where, value)
+		}
+	}
+}
+
+func TestBulkCreateRejectsUnknownFields(t *testing.T) {
+	_, mux, secret := testServer(t)
+	body := map[string]any{"operations": []any{
+		map[string]any{"action": "create", "bookmark": map[string]any{"url": "https://example.com/one"}},
+		map[string]any{"action": "create", "bookmark": map[string]any{"url": "https://example.com/two", "Canonical": "https://example.com"}},
+	}}
+
+	w := serve(mux, secret, "POST", api.Prefix+"/bookmarks/bulk", body)
+	var response api.Error
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusBadRequest || response.Index == nil || *response.Index != 1 {
+		t.Fatalf("bulk create with an unknown field answered %d %s", w.Code, w.Body)
+	}
+	if w := serve(mux, secret, "GET", api.Prefix+"/bookmarks", nil); strings.Contains(w.Body.String(), "example.com/one") {
+		t.Errorf("the other operations were applied: %s", w.Body)
+	}
+}
+
+func TestGetPageOnlyFetchesPublicPagesForWriteKeys(t *testing.T) {
+	db, mux, writeKey := testServer(t)
+	readKey := newKey(t, db, store.ScopeRead)
+
+	var requests atomic.Int32
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		fmt.Fprint(w, "<title>Router admin</title>")
+	}))
+	defer page.Close()
+	path := "/api/v1/pages?url=" + url.QueryEscape(page.URL)
+
+	if w := serve(mux, readKey, "GET", path, nil); w.Code != http.StatusForbidden {
+		t.Errorf("a read key got %d %s", w.Code, w.Body)
+	}
+	w := serve(mux, writeKey, "GET", path, nil)
+	var e api.Error
+	json.Unmarshal(w.Body.Bytes(), &e)
+	if w.Code != http.StatusBadRequest || e.Field != "url" {
+		t.Errorf("a loopback url answered %d %s", w.Code, w.Body)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("the page got %d requests", n)
+	}
+}
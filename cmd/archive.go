@@ -0,0 +1,139 @@
+/*
+Copyright © 2025 Lukas Werner <me@lukaswerner.com>
+*/
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/huh"
+	"github.com/cli/browser"
+	"github.com/lukasmwerner/mark/metadata"
+	"github.com/lukasmwerner/mark/store"
+	"github.com/spf13/cobra"
+)
+
+var archiveFormat string
+var archiveAll bool
+
+// archiveCmd represents the archive command
+var archiveCmd = &cobra.Command{
+	Use:   "archive <search query>",
+	Short: "Saves an offline copy of a bookmarked page",
+	Long: `Downloads the page and stores it alongside the bookmarks so it can be read
+after the link has gone away, view it with: mark open --archived <query>
+
+Archives are not written to the changes files unless MARK_SYNC_ARCHIVES=true
+is set, as they can get quite large. They are kept by the url of the page, so
+bookmarks with the same url share one.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := store.Open()
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		defer db.Close()
+
+		searchQuery := strings.Join(args, " ")
+
+		bookmarks, err := searchBookmarks(db, searchQuery)
+		if err != nil {
+			fmt.Println("unable to search bookmarks", err.Error())
+			return
+		}
+		if len(bookmarks) == 0 {
+			fmt.Println("found no bookmarks")
+			return
+		}
+
+		if len(bookmarks) != 1 && !archiveAll {
+			pickedIndex := 0
+			options := make([]huh.Option[int], len(bookmarks))
+			for i, bookmark := range bookmarks {
+				options[i] = huh.NewOption(bookmark.Title, i)
+			}
+			err = huh.NewSelect[int]().Title("Pick your link").Options(options...).Value(&pickedIndex).Run()
+			if err != nil {
+				if err == huh.ErrUserAborted {
+					return
+				}
+				fmt.Println(err.Error())
+				return
+			}
+			bookmarks = []store.Bookmark{bookmarks[pickedIndex]}
+		}
+
+		for _, bookmark := range bookmarks {
+			fmt.Println("archiving", bookmark.Url)
+			link, err := url.Parse(bookmark.Url)
+			if err != nil {
+				fmt.Println("\tunable to parse url", err.Error())
+				continue
+			}
+			page, err := metadata.DefaultFetcher.Page(link)
+			if err != nil {
+				fmt.Println("\tunable to fetch page", err.Error())
+				continue
+			}
+			err = saveArchive(db, metadata.DefaultFetcher, page, bookmark.Id, archiveFormat)
+			if err != nil {
+				fmt.Println("\tunable to archive page", err.Error())
+				continue
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(archiveCmd)
+
+	archiveCmd.Flags().StringVarP(&archiveFormat, "format", "f", metadata.FormatHTML, "Archive format: html,text")
+	archiveCmd.Flags().BoolVarP(&archiveAll, "all", "a", false, "Archive every matching bookmark instead of picking one")
+}
+
+func saveArchive(db *store.DB, fetcher *metadata.Fetcher, page *metadata.Page, id store.BookmarkId, format string) error {
+	content, err := fetcher.Snapshot(page, format)
+	if err != nil {
+		return err
+	}
+	return store.SaveArchive(db, store.Archive{
+		BookmarkId: id,
+		Format:     format,
+		Content:    content,
+		ArchivedAt: time.Now(),
+	})
+}
+
+// openArchive writes the archive out to the cache directory and opens it.
+func openArchive(archive store.Archive) error {
+	name, err := writeArchiveFile(archive)
+	if err != nil {
+		return err
+	}
+	return browser.OpenFile(name)
+}
+
+// writeArchiveFile writes the archive to the cache directory, there is one
+// file per bookmark which is replaced every time the archive is opened.
+func writeArchiveFile(archive store.Archive) (string, error) {
+	ext := ".html"
+	if archive.Format == metadata.FormatText {
+		ext = ".txt"
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(cache, "mark", "archives")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	name := filepath.Join(dir, fmt.Sprintf("%d%s", archive.BookmarkId, ext))
+	return name, os.WriteFile(name, archive.Content, 0o600)
+}
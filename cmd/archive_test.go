@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lukasmwerner/mark/metadata"
+	"github.com/lukasmwerner/mark/store"
+)
+
+func TestWriteArchiveFileReplacesPreviousFile(t *testing.T) {
+	cache := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cache)
+
+	archive := store.Archive{BookmarkId: 7, Format: metadata.FormatHTML, Content: []byte("first")}
+	first, err := writeArchiveFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive.Content = []byte("second")
+	second, err := writeArchiveFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first != second || filepath.Dir(first) != filepath.Join(cache, "mark", "archives") {
+		t.Errorf("wrote %s and then %s", first, second)
+	}
+	content, err := os.ReadFile(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "second" {
+		t.Errorf("the archive file has %q", content)
+	}
+	files, err := os.ReadDir(filepath.Dir(second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("%d archive files were left behind", len(files))
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Copyright © 2025 Lukas Werner <me@lukaswerner.com>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lukasmwerner/mark/config"
+	"github.com/lukasmwerner/mark/store"
+	"github.com/spf13/cobra"
+)
+
+var bookmarkletKey string
+var bookmarkletLabel string
+var bookmarkletServer string
+
+// bookmarkletCmd represents the bookmarklet command
+var bookmarkletCmd = &cobra.Command{
+	Use:   "bookmarklet",
+	Short: "Print a bookmarklet that saves pages to mark server",
+	Long: `Prints a bookmarklet for browsers without the extension, like Safari,
+Firefox or a phone. Clicking it opens the /save page of mark server with the
+current page filled in, along with its description and suggested tags.
+
+The bookmarklet carries a write key, so anyone who has it can save to mark.
+Without --key a new key is made for it, which can be deleted with mark keys
+delete when the bookmarklet is no longer used. The key is passed after the #
+of the url, which browsers do not send to the server.
+
+The server url is read from config.json, set --url when the server is reached
+some other way, like through a reverse proxy or from a phone.
+
+Example:
+mark bookmarklet --url https://mark.example.com`,
+	Run: func(cmd *cobra.Command, args []string) {
+		server := bookmarkletServer
+		if server == "" {
+			conf, err := config.Load()
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			server, err = conf.Server.URL()
+			if err != nil {
+				fmt.Printf("%s, set the url with --url\n", err)
+				return
+			}
+		}
+		if u, err := url.Parse(server); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fmt.Printf("invalid server url %q, use one like https://mark.example.com\n", server)
+			return
+		}
+
+		db, err := store.Open()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer db.Close()
+
+		keys, err := store.OpenKeys(db)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer keys.Close()
+
+		secret := bookmarkletKey
+		if secret == "" {
+			var key store.ApiKey
+			secret, key, err = store.NewKey(keys, bookmarkletLabel, store.ScopeWrite, time.Time{}, false)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			fmt.Printf("(made write key %d labeled %q for the bookmarklet)\n", key.Id, key.Label)
+		} else {
+			key, err := store.LookupKey(keys, secret)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if key.Scope != store.ScopeWrite {
+				fmt.Printf("key %d can only read, the bookmarklet needs a write key\n", key.Id)
+				return
+			}
+		}
+
+		fmt.Println(bookmarklet(server, secret))
+	},
+}
+
+// bookmarklet opens the save page in a small window with the page it was
+// clicked on. The server and key are embedded as quoted js strings, and as
+// browsers percent-decode javascript: urls before running them every % is
+// escaped too.
+func bookmarklet(server, secret string) string {
+	target := strings.TrimSuffix(server, "/") + "/save"
+	page, _ := json.Marshal(target + "?url=")
+	key, _ := json.Marshal("#key=" + url.QueryEscape(secret))
+	script := "(function(){window.open(" +
+		string(page) + "+encodeURIComponent(location.href)+'&title='+encodeURIComponent(document.title)+" +
+		string(key) + ",'mark','width=560,height=720')})()"
+	return "javascript:" + strings.ReplaceAll(script, "%", "%25")
+}
+
+func init() {
+	rootCmd.AddCommand(bookmarkletCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// bookmarkletCmd.PersistentFlags().String("foo", "", "A help for foo")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	// bookmarkletCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	bookmarkletCmd.Flags().StringVar(&bookmarkletKey, "key", "", "Use this write key instead of making a new one")
+	bookmarkletCmd.Flags().StringVarP(&bookmarkletLabel, "label", "l", "bookmarklet", "Label of the new key")
+	bookmarkletCmd.Flags().StringVar(&bookmarkletServer, "url", "", "Where browsers reach mark server, e.g. https://mark.example.com")
+}
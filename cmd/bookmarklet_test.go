@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestBookmarkletEscapesServer(t *testing.T) {
+	for _, server := range []string{
+		"https://mark.example.com/",
+		"https://mark.example.com/it's",
+		`https://mark.example.com/"+alert(1)+"`,
+		`https://mark.example.com/a\'+alert(1)+'`,
+		"https://mark.example.com/%27+alert(1)+%27",
+		"https://mark.example.com/</script>\n",
+	} {
+		js := bookmarklet(server, "mark_abc+/=")
+
+		// what the browser runs, it percent-decodes javascript: urls
+		script, err := url.PathUnescape(strings.TrimPrefix(js, "javascript:"))
+		if err != nil {
+			t.Fatalf("%q: %v", server, err)
+		}
+		before, rest, ok := strings.Cut(script, "window.open(")
+		if !ok || before != "(function(){" {
+			t.Fatalf("%q: unexpected bookmarklet %s", server, script)
+		}
+
+		// the first argument starts with a single string literal of the target
+		decoder := json.NewDecoder(strings.NewReader(rest))
+		var target string
+		if err := decoder.Decode(&target); err != nil {
+			t.Fatalf("%q: the target is not one string: %v\n%s", server, err, script)
+		}
+		if want := strings.TrimSuffix(server, "/") + "/save?url="; target != want {
+			t.Errorf("%q: target %q, want %q", server, target, want)
+		}
+		after := rest[decoder.InputOffset():]
+		if !strings.HasPrefix(after, "+encodeURIComponent(location.href)+") ||
+			!strings.HasSuffix(after, `"#key=mark_abc%2B%2F%3D",'mark','width=560,height=720')})()`) {
+			t.Errorf("%q: the server broke out of its string: %s", server, script)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+/*
+Copyright © 2025 Lukas Werner <me@lukaswerner.com>
+*/
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lukasmwerner/mark/linkcheck"
+	"github.com/lukasmwerner/mark/metadata"
+	"github.com/lukasmwerner/mark/store"
+	"github.com/spf13/cobra"
+)
+
+var checkTag string
+var checkConcurrency int
+var checkHostInterval time.Duration
+var checkTimeout time.Duration
+var checkUpdateMoved bool
+var checkTagDead bool
+
+// checkCmd represents the check command
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Checks bookmarks for dead or moved links",
+	Long: `Requests every bookmarked link and reports the ones that are broken or have
+moved. The status of each link is saved on the bookmark.
+
+Example:
+mark check [--tag x] [--concurrency 8] [--update-moved] [--tag-dead]`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := store.Open()
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		defer db.Close()
+
+		bookmarks, err := store.ListBookmarks(db, store.ListOptions{Tag: checkTag})
+		if err != nil {
+			fmt.Println("unable to list bookmarks", err.Error())
+			return
+		}
+		if len(bookmarks) == 0 {
+			fmt.Println("found no bookmarks")
+			return
+		}
+
+		urls := make([]string, len(bookmarks))
+		for i, bookmark := range bookmarks {
+			urls[i] = bookmark.Url
+		}
+
+		checker := linkcheck.NewChecker(checkConcurrency, checkHostInterval, checkTimeout, metadata.DefaultUserAgent)
+		checked := 0
+		results := checker.Check(urls, func(result linkcheck.Result) {
+			checked++
+			fmt.Printf("\r%d/%d checked", checked, len(urls))
+		})
+		fmt.Println()
+
+		broken, moved := 0, 0
+		for i, result := range results {
+			bookmark := bookmarks[i]
+
+			redirect := ""
+			if result.Moved() {
+				moved++
+				fmt.Println("moved", bookmark.Url, "->", result.Location)
+				redirect = result.Location
+			}
+			if result.Moved() && checkUpdateMoved {
+				existing, duplicate, err := moveBookmark(db, bookmark, result.Location)
+				switch {
+				case err != nil:
+					fmt.Println("\tunable to update bookmark", err.Error())
+				case duplicate:
+					fmt.Println("\tnot updated, already saved as", existing.Url)
+				default:
+					// moved links that got updated no longer redirect
+					redirect = ""
+				}
+			}
+			err := store.RecordLinkStatus(db, bookmark.Id, result.Status, redirect, result.Checked)
+			if err != nil {
+				fmt.Println("unable to save link status", err.Error())
+				return
+			}
+
+			if result.Broken() {
+				broken++
+				if result.Err != nil {
+					fmt.Println("broken", bookmark.Url, result.Err.Error())
+				} else {
+					fmt.Println("broken", bookmark.Url, result.Status)
+				}
+				if checkTagDead && !slices.Contains(bookmark.Tags, "dead") {
+					updated := bookmark
+					updated.Tags = append(slices.Clone(bookmark.Tags), "dead")
+					if err := store.UpdateBookmark(db, bookmark, updated); err != nil {
+						fmt.Println("\tunable to tag bookmark", err.Error())
+					}
+				}
+			}
+		}
+		fmt.Printf("%d checked, %d broken, %d moved\n", len(results), broken, moved)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+
+	checkCmd.Flags().StringVar(&checkTag, "tag", "", "Only check bookmarks with this tag")
+	checkCmd.Flags().IntVarP(&checkConcurrency, "concurrency", "c", linkcheck.DefaultConcurrency, "Number of links checked at the same time")
+	checkCmd.Flags().DurationVar(&checkHostInterval, "host-interval", linkcheck.DefaultHostInterval, "Minimum time between requests to the same host")
+	checkCmd.Flags().DurationVar(&checkTimeout, "timeout", linkcheck.DefaultTimeout, "How long to wait for each link")
+	checkCmd.Flags().BoolVar(&checkUpdateMoved, "update-moved", false, "Replace the url of moved links with where they moved to")
+	checkCmd.Flags().BoolVar(&checkTagDead, "tag-dead", false, "Tag broken links with dead")
+}
+
+// moveBookmark points the bookmark at where its link moved to. When another
+// bookmark is already saved under that url nothing is changed and the other
+// bookmark is returned, so moved links do not turn into duplicates.
+func moveBookmark(db *store.DB, bookmark store.Bookmark, location string) (store.Bookmark, bool, error) {
+	existing, found, err := store.FindOtherDuplicate(db, bookmark.Id, location)
+	if err != nil || found {
+		return existing, found, err
+	}
+	updated := bookmark
+	updated.Url = location
+	if updated.OriginalUrl == "" {
+		updated.OriginalUrl = bookmark.Url
+	}
+	return store.Bookmark{}, false, store.UpdateBookmarkById(db, bookmark.Id, updated)
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/lukasmwerner/mark/store"
+)
+
+func TestMoveBookmarkSkipsDuplicates(t *testing.T) {
+	db := openTestDB(t)
+	insert := func(bm store.Bookmark) store.Bookmark {
+		t.Helper()
+		id, err := store.InsertBookmark(db, bm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bm, err = store.GetBookmarkById(db, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bm
+	}
+	old := insert(store.Bookmark{Url: "http://example.com/old", Title: "Old", Canonical: "https://example.com/new"})
+	other := insert(store.Bookmark{Url: "http://other.com/old", Title: "Other"})
+	insert(store.Bookmark{Url: "https://other.com/new", Title: "Already saved"})
+
+	// its own canonical url is not a duplicate
+	if _, duplicate, err := moveBookmark(db, old, "https://example.com/new"); err != nil || duplicate {
+		t.Fatalf("moving to its canonical url: duplicate %t, %v", duplicate, err)
+	}
+	moved, err := store.GetBookmarkById(db, old.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.Url != "https://example.com/new" || moved.OriginalUrl != "http://example.com/old" || moved.Title != "Old" {
+		t.Errorf("moved to %q from %q titled %q", moved.Url, moved.OriginalUrl, moved.Title)
+	}
+
+	existing, duplicate, err := moveBookmark(db, other, "https://other.com/new/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !duplicate || existing.Title != "Already saved" {
+		t.Errorf("moving onto a saved url: duplicate %t of %q", duplicate, existing.Title)
+	}
+	unchanged, err := store.GetBookmarkById(db, other.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unchanged.Url != "http://other.com/old" {
+		t.Errorf("the duplicate was saved as %q", unchanged.Url)
+	}
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lukasmwerner/mark/metadata"
+	"github.com/lukasmwerner/mark/store"
+)
+
+// newKey makes another key for the server of testServer.
+func newKey(t *testing.T, db *store.DB, scope string) string {
+	t.Helper()
+	keys, err := store.OpenKeys(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer keys.Close()
+	secret, _, err := store.NewKey(keys, scope+" test", scope, time.Time{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return secret
+}
+
+// allowLocalFetches lets the server fetch pages from httptest servers, which
+// are on loopback addresses that clientFetcher refuses.
+func allowLocalFetches(t *testing.T) {
+	fetcher := clientFetcher
+	clientFetcher = metadata.DefaultFetcher
+	t.Cleanup(func() { clientFetcher = fetcher })
+}
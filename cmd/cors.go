@@ -0,0 +1,87 @@
+/*
+Copyright © 2025 Lukas Werner <me@lukaswerner.com>
+*/
+package cmd
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	corsMethods = "GET, POST, PATCH, DELETE, OPTIONS"
+	corsHeaders = "Authorization, Content-Type"
+	// browsers cap this anyway, chrome at 2 hours
+	corsMaxAge = "7200"
+)
+
+// allowedOrigin reports whether the origin matches any of the patterns. A
+// pattern is an exact origin (http://localhost:3000), a scheme with a wildcard
+// (moz-extension://*, as firefox extension ids differ per install), a wildcard
+// subdomain (https://*.example.com) or * for any origin.
+func allowedOrigin(origin string, patterns []string) bool {
+	o, err := url.Parse(origin)
+	if err != nil || o.Scheme == "" || o.Host == "" {
+		return false
+	}
+	scheme, host := strings.ToLower(o.Scheme), strings.ToLower(o.Host)
+
+	for _, pattern := range patterns {
+		if pattern == "*" {
+			return true
+		}
+		p, err := url.Parse(strings.TrimRight(pattern, "/"))
+		if err != nil || strings.ToLower(p.Scheme) != scheme {
+			continue
+		}
+		patternHost := strings.ToLower(p.Host)
+		switch {
+		case patternHost == "*" || patternHost == host:
+			return true
+		case strings.HasPrefix(patternHost, "*.") && strings.HasSuffix(host, patternHost[1:]):
+			return true
+		}
+	}
+	return false
+}
+
+// cors lets browser extensions and web apps on the allowed origins call the
+// api. Preflight requests are answered here, before they reach AuthRequired,
+// as browsers never send credentials with them.
+func cors(origins []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// responses differ by origin, caches must not hand one to another
+		// origin, including responses to requests without one
+		w.Header().Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed := allowedOrigin(origin, origins)
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if preflight {
+			if !allowed {
+				writeError(w, http.StatusForbidden, "Origin not allowed")
+				return
+			}
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", corsMethods)
+			w.Header().Set("Access-Control-Allow-Headers", corsHeaders)
+			w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		// requests from other origins still go through, the browser keeps the
+		// page from reading the response
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
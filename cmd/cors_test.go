@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func corsTestHandler(origins []string) http.Handler {
+	return cors(origins, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func preflight(origin string) *http.Request {
+	r := httptest.NewRequest(http.MethodOptions, "/api/v1/bookmarks", nil)
+	r.Header.Set("Origin", origin)
+	r.Header.Set("Access-Control-Request-Method", "POST")
+	r.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+	return r
+}
+
+func TestCorsPreflightAllowedOrigin(t *testing.T) {
+	w := httptest.NewRecorder()
+	corsTestHandler([]string{"http://localhost:3000"}).ServeHTTP(w, preflight("http://localhost:3000"))
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("preflight answered %d", w.Code)
+	}
+	want := map[string]string{
+		"Access-Control-Allow-Origin":  "http://localhost:3000",
+		"Access-Control-Allow-Methods": corsMethods,
+		"Access-Control-Allow-Headers": corsHeaders,
+		"Access-Control-Max-Age":       corsMaxAge,
+	}
+	for header, value := range want {
+		if got := w.Header().Get(header); got != value {
+			t.Errorf("%s = %q, want %q", header, got, value)
+		}
+	}
+}
+
+func TestCorsRejectedOrigin(t *testing.T) {
+	handler := corsTestHandler([]string{"http://localhost:3000", "https://*.example.com"})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, preflight("https://evil.test"))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("preflight from another origin answered %d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("preflight from another origin was allowed: %q", got)
+	}
+
+	// the request itself goes through, the browser keeps the page from
+	// reading it
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/bookmarks", nil)
+	r.Header.Set("Origin", "https://example.com.evil.test")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("request from another origin was allowed: %q", got)
+	}
+}
+
+func TestCorsExtensionWildcard(t *testing.T) {
+	handler := corsTestHandler([]string{"chrome-extension://*"})
+	tests := map[string]bool{
+		"chrome-extension://abcdefghijklmnopabcdefghijklmnop": true,
+		"chrome-extension://another":                          true,
+		"moz-extension://abcdef":                              false,
+		"https://chrome-extension.example":                    false,
+	}
+	for origin, allowed := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, preflight(origin))
+		if got := w.Code == http.StatusNoContent; got != allowed {
+			t.Errorf("preflight from %s allowed = %v, want %v", origin, got, allowed)
+		}
+	}
+}
+
+func TestCorsVaryOrigin(t *testing.T) {
+	handler := corsTestHandler([]string{"http://localhost:3000"})
+	for _, origin := range []string{"http://localhost:3000", "https://evil.test", ""} {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/bookmarks", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("request from %q answered %d", origin, w.Code)
+		}
+		if got := w.Header().Values("Vary"); len(got) == 0 || got[0] != "Origin" {
+			t.Errorf("response to %q has Vary %v", origin, got)
+		}
+	}
+}
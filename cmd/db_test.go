@@ -0,0 +1,28 @@
+//go:build testdb
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lukasmwerner/mark/store"
+)
+
+// openTestDB opens an empty store in a temporary directory, without cr-sqlite.
+func openTestDB(t *testing.T) *store.DB {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("MARK_STORE_LOCATION", dir)
+	t.Setenv("MARK_KEYS_LOCATION", "")
+
+	db, err := store.OpenUnsynced(dir)
+	if err != nil && strings.Contains(err.Error(), "no such module: fts5") {
+		t.Skip(`sqlite was built without fts5, run the tests with -tags "fts5 testdb"`)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
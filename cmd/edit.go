@@ -38,7 +38,7 @@ var editCmd = &cobra.Command{
 			}
 		}
 
-		bookmarks, err := store.SearchBookmarks(db, searchQuery)
+		bookmarks, err := searchBookmarks(db, searchQuery)
 		if err != nil {
 			fmt.Println("unable to search bookmarks", err.Error())
 			return
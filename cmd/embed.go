@@ -0,0 +1,126 @@
+/*
+Copyright © 2025 Lukas Werner <me@lukaswerner.com>
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lukasmwerner/mark/embeddings"
+	"github.com/lukasmwerner/mark/store"
+	"github.com/spf13/cobra"
+)
+
+// embedBatchSize is how many bookmarks are sent to the provider at once.
+const embedBatchSize = 16
+
+var embedForce bool
+
+// embedCmd represents the embed command
+var embedCmd = &cobra.Command{
+	Use:   "embed [search query]",
+	Short: "Computes embeddings of the bookmarks for mark search --semantic",
+	Long: `Embeds every bookmark (or the ones matching the search query) that is new or
+changed since it was last embedded. The title, description, tags, url and
+the indexed page text (see mark reindex) are used.
+
+Embeddings are computed by ollama, set OLLAMA_HOST to use a different server
+(anything with an ollama compatible /api/embed works) and MARK_EMBED_MODEL to
+use a different model than nomic-embed-text. Embeddings are only stored
+locally and are not synchronized.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := store.Open()
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		defer db.Close()
+
+		var bookmarks []store.Bookmark
+		searchQuery := strings.Join(args, " ")
+		if searchQuery == "" {
+			bookmarks, err = store.ListBookmarks(db, store.ListOptions{})
+		} else {
+			bookmarks, err = searchBookmarks(db, searchQuery)
+		}
+		if err != nil {
+			fmt.Println("unable to list bookmarks", err.Error())
+			return
+		}
+
+		provider := embeddings.FromEnv()
+		embedded, err := embedBookmarks(db, provider, bookmarks, embedForce)
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		fmt.Printf("embedded %d bookmarks with %s\n", embedded, provider.Model())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(embedCmd)
+
+	embedCmd.Flags().BoolVar(&embedForce, "force", false, "Embed bookmarks again even if they have not changed")
+}
+
+// embedBookmarks embeds the bookmarks that are new or changed since they were
+// last embedded with the provider's model, or all of them with force. It
+// returns how many were embedded.
+func embedBookmarks(db *store.DB, provider embeddings.Provider, bookmarks []store.Bookmark, force bool) (int, error) {
+	type pending struct {
+		bookmark store.Bookmark
+		input    string
+		hash     string
+	}
+	batch := []pending{}
+	embedded := 0
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		inputs := []string{}
+		for _, p := range batch {
+			inputs = append(inputs, p.input)
+		}
+		vectors, err := provider.Embed(inputs)
+		if err != nil {
+			return fmt.Errorf("unable to compute embeddings: %w", err)
+		}
+		for i, p := range batch {
+			if err := store.SaveEmbedding(db, p.bookmark.Id, provider.Model(), p.hash, vectors[i]); err != nil {
+				return err
+			}
+			fmt.Println("embedded", p.bookmark.Url)
+			embedded++
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for _, bookmark := range bookmarks {
+		input, err := store.EmbeddingInput(db, bookmark)
+		if err != nil {
+			return embedded, err
+		}
+		hash := store.EmbeddingHash(input)
+		if !force {
+			saved, err := store.GetEmbeddingHash(db, bookmark.Id, provider.Model())
+			if err != nil {
+				return embedded, err
+			}
+			if saved == hash {
+				continue
+			}
+		}
+
+		batch = append(batch, pending{bookmark, input, hash})
+		if len(batch) == embedBatchSize {
+			if err := flush(); err != nil {
+				return embedded, err
+			}
+		}
+	}
+	return embedded, flush()
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lukasmwerner/mark/store"
+)
+
+// countingProvider embeds every text as the same vector and remembers what it
+// was asked to embed.
+type countingProvider struct {
+	embedded []string
+	err      error
+}
+
+func (p *countingProvider) Model() string { return "counting" }
+
+func (p *countingProvider) Embed(texts []string) ([][]float32, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	vectors := [][]float32{}
+	for _, text := range texts {
+		p.embedded = append(p.embedded, text)
+		vectors = append(vectors, []float32{1, 0})
+	}
+	return vectors, nil
+}
+
+func TestEmbedBookmarksOnlyEmbedsChanges(t *testing.T) {
+	db := openTestDB(t)
+	for _, bm := range []store.Bookmark{
+		{Url: "https://go.dev/", Title: "Go"},
+		{Url: "https://www.rust-lang.org/", Title: "Rust"},
+	} {
+		if _, err := store.InsertBookmark(db, bm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list := func() []store.Bookmark {
+		t.Helper()
+		bookmarks, err := store.ListBookmarks(db, store.ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bookmarks
+	}
+	embed := func(force bool, want int) {
+		t.Helper()
+		provider := &countingProvider{}
+		embedded, err := embedBookmarks(db, provider, list(), force)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if embedded != want || len(provider.embedded) != want {
+			t.Errorf("embedded %d bookmarks (%d texts), want %d", embedded, len(provider.embedded), want)
+		}
+	}
+
+	embed(false, 2)
+	embed(false, 0)
+
+	bookmarks := list()
+	updated := bookmarks[0]
+	updated.Tags = []string{"lang"}
+	if err := store.UpdateBookmark(db, bookmarks[0], updated); err != nil {
+		t.Fatal(err)
+	}
+	embed(false, 1)
+
+	if err := store.SaveContent(db, bookmarks[1].Id, "Rust is a language."); err != nil {
+		t.Fatal(err)
+	}
+	embed(false, 1)
+	embed(false, 0)
+	embed(true, 2)
+
+	failing := &countingProvider{err: errors.New("connection refused")}
+	updated.Title = "The Go programming language"
+	if err := store.UpdateBookmark(db, bookmarks[0], updated); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := embedBookmarks(db, failing, list(), false); err == nil {
+		t.Error("the provider's error was lost")
+	}
+	// nothing was saved, so it is embedded on the next run
+	embed(false, 1)
+}
@@ -0,0 +1,309 @@
+/*
+Copyright © 2024 Lukas Werner <me@lukaswerner.com>
+*/
+package cmd
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/lukasmwerner/mark/store"
+)
+
+// firefoxRoots are the guids of the built in folders, these are never turned
+// into tags.
+var firefoxRoots = map[string]bool{
+	"root________": true,
+	"menu________": true,
+	"toolbar_____": true,
+	"unfiled_____": true,
+	"mobile______": true,
+	"tags________": true,
+}
+
+type firefoxNode struct {
+	parent int64
+	kind   int
+	title  string
+	url    string
+	guid   string
+}
+
+// readFirefoxBookmarks reads the places.sqlite file in the given profile. The
+// database is copied out first as firefox keeps it locked while running.
+func readFirefoxBookmarks(profile string) ([]store.Bookmark, error) {
+	tmp, err := os.MkdirTemp("", "mark-firefox")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	places := path.Join(tmp, "places.sqlite")
+	if err := copyFile(path.Join(profile, "places.sqlite"), places); err != nil {
+		return nil, err
+	}
+	// recent writes may still be sitting in the write ahead log
+	if err := copyFile(path.Join(profile, "places.sqlite-wal"), places+"-wal"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite3", places)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT b.id, b.parent, b.type, COALESCE(b.title, ''), COALESCE(p.url, ''), COALESCE(p.title, ''), b.guid
+	FROM moz_bookmarks b LEFT JOIN moz_places p ON p.id = b.fk
+	ORDER BY b.id;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	nodes := map[int64]firefoxNode{}
+	order := []int64{}
+	for rows.Next() {
+		var id int64
+		var node firefoxNode
+		var placeTitle string
+		err := rows.Scan(&id, &node.parent, &node.kind, &node.title, &node.url, &placeTitle, &node.guid)
+		if err != nil {
+			return nil, err
+		}
+		if node.title == "" {
+			node.title = placeTitle
+		}
+		nodes[id] = node
+		order = append(order, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// tags are stored as bookmarks of the url inside a folder under the tags root
+	tagsByUrl := map[string][]string{}
+	for _, id := range order {
+		node := nodes[id]
+		if node.kind != 1 {
+			continue
+		}
+		folder := nodes[node.parent]
+		if nodes[folder.parent].guid == "tags________" {
+			tagsByUrl[node.url] = append(tagsByUrl[node.url], folder.title)
+		}
+	}
+
+	bookmarks := []store.Bookmark{}
+	seen := map[string]bool{}
+	for _, id := range order {
+		node := nodes[id]
+		if node.kind != 1 || !importableUrl(node.url) || seen[node.url] {
+			continue
+		}
+		folder := nodes[node.parent]
+		if nodes[folder.parent].guid == "tags________" {
+			continue
+		}
+		seen[node.url] = true
+
+		tags := []string{}
+		for parent := node.parent; ; {
+			folder, ok := nodes[parent]
+			if !ok || firefoxRoots[folder.guid] {
+				break
+			}
+			tags = append(tags, folder.title)
+			parent = folder.parent
+		}
+		tags = append(tags, tagsByUrl[node.url]...)
+
+		bookmarks = append(bookmarks, store.Bookmark{
+			Url:   node.url,
+			Title: strings.TrimSpace(node.title),
+			Tags:  cleanTags(tags),
+		})
+	}
+
+	return bookmarks, nil
+}
+
+type chromeNode struct {
+	Type     string       `json:"type"`
+	Name     string       `json:"name"`
+	Url      string       `json:"url"`
+	Children []chromeNode `json:"children"`
+}
+
+// readChromeBookmarks reads the Bookmarks json file found in chromium based
+// browser profiles.
+func readChromeBookmarks(profile string) ([]store.Bookmark, error) {
+	tmp, err := os.MkdirTemp("", "mark-chrome")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	file := path.Join(tmp, "Bookmarks")
+	if err := copyFile(path.Join(profile, "Bookmarks"), file); err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var data struct {
+		Roots map[string]json.RawMessage `json:"roots"`
+	}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, err
+	}
+
+	bookmarks := []store.Bookmark{}
+	seen := map[string]bool{}
+	var walk func(node chromeNode, folders []string)
+	walk = func(node chromeNode, folders []string) {
+		switch node.Type {
+		case "url":
+			if !importableUrl(node.Url) || seen[node.Url] {
+				return
+			}
+			seen[node.Url] = true
+			bookmarks = append(bookmarks, store.Bookmark{
+				Url:   node.Url,
+				Title: strings.TrimSpace(node.Name),
+				Tags:  cleanTags(folders),
+			})
+		case "folder":
+			for _, child := range node.Children {
+				walk(child, append(folders[:len(folders):len(folders)], node.Name))
+			}
+		}
+	}
+
+	for _, name := range []string{"bookmark_bar", "other", "synced"} {
+		raw, ok := data.Roots[name]
+		if !ok {
+			continue
+		}
+		var root chromeNode
+		if err := json.Unmarshal(raw, &root); err != nil {
+			continue
+		}
+		// the roots (bookmarks bar, other bookmarks) are not useful as tags
+		for _, child := range root.Children {
+			walk(child, []string{})
+		}
+	}
+
+	return bookmarks, nil
+}
+
+func findFirefoxProfile() (string, error) {
+	homedir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	var dirs []string
+	switch runtime.GOOS {
+	case "darwin":
+		dirs = []string{path.Join(homedir, "Library", "Application Support", "Firefox", "Profiles")}
+	case "windows":
+		dirs = []string{path.Join(os.Getenv("APPDATA"), "Mozilla", "Firefox", "Profiles")}
+	default:
+		dirs = []string{
+			path.Join(homedir, ".mozilla", "firefox"),
+			path.Join(homedir, "snap", "firefox", "common", ".mozilla", "firefox"),
+		}
+	}
+	for _, dir := range dirs {
+		for _, pattern := range []string{"*.default-release", "*.default"} {
+			matches, _ := filepath.Glob(path.Join(dir, pattern))
+			for _, match := range matches {
+				if _, err := os.Stat(path.Join(match, "places.sqlite")); err == nil {
+					return match, nil
+				}
+			}
+		}
+	}
+	return "", errors.New("no firefox profile found")
+}
+
+func findChromeProfile() (string, error) {
+	homedir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	var dirs []string
+	switch runtime.GOOS {
+	case "darwin":
+		support := path.Join(homedir, "Library", "Application Support")
+		dirs = []string{
+			path.Join(support, "Google", "Chrome", "Default"),
+			path.Join(support, "Chromium", "Default"),
+			path.Join(support, "BraveSoftware", "Brave-Browser", "Default"),
+		}
+	case "windows":
+		local := os.Getenv("LOCALAPPDATA")
+		dirs = []string{
+			path.Join(local, "Google", "Chrome", "User Data", "Default"),
+			path.Join(local, "Chromium", "User Data", "Default"),
+		}
+	default:
+		dirs = []string{
+			path.Join(homedir, ".config", "google-chrome", "Default"),
+			path.Join(homedir, ".config", "chromium", "Default"),
+			path.Join(homedir, ".config", "BraveSoftware", "Brave-Browser", "Default"),
+		}
+	}
+	for _, dir := range dirs {
+		if _, err := os.Stat(path.Join(dir, "Bookmarks")); err == nil {
+			return dir, nil
+		}
+	}
+	return "", errors.New("no chrome profile found")
+}
+
+func importableUrl(u string) bool {
+	return strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://")
+}
+
+// cleanTags drops empty tags and commas as the store uses them as a separator.
+func cleanTags(tags []string) []string {
+	cleaned := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.Join(strings.Fields(strings.ReplaceAll(tag, ",", " ")), " ")
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		cleaned = append(cleaned, tag)
+	}
+	return cleaned
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
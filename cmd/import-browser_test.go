@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lukasmwerner/mark/store"
+)
+
+func TestReadFirefoxBookmarks(t *testing.T) {
+	bookmarks, err := readFirefoxBookmarks("testdata/firefox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []store.Bookmark{
+		{Url: "https://go.dev/", Title: "Go", Tags: []string{"Go tools", "Dev", "reading"}},
+		{Url: "http://example.com/", Title: "Example Domain", Tags: []string{}},
+		{Url: "https://blog.example.com/post", Title: "A post", Tags: []string{}},
+	}
+	if !reflect.DeepEqual(bookmarks, want) {
+		t.Errorf("read %+v\nwant %+v", bookmarks, want)
+	}
+}
+
+func TestReadChromeBookmarks(t *testing.T) {
+	bookmarks, err := readChromeBookmarks("testdata/chrome")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []store.Bookmark{
+		{Url: "https://go.dev/", Title: "Go", Tags: []string{"Dev", "Go"}},
+		{Url: "http://example.com/", Title: "Example", Tags: []string{}},
+		{Url: "https://blog.example.com/post", Title: "A post", Tags: []string{"Read later"}},
+	}
+	if !reflect.DeepEqual(bookmarks, want) {
+		t.Errorf("read %+v\nwant %+v", bookmarks, want)
+	}
+}
+
+func TestImportBookmarksSkipsExisting(t *testing.T) {
+	db := openTestDB(t)
+	for _, u := range []string{"https://example.com", "https://go.dev/"} {
+		if _, err := store.InsertBookmark(db, store.Bookmark{Url: u}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bookmarks, err := readChromeBookmarks("testdata/chrome")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prepared := 0
+	prepare := func(bm *store.Bookmark) { prepared++ }
+	imported, skipped, err := importBookmarks(db, bookmarks, prepare)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported != 1 || skipped != 2 || prepared != 1 {
+		t.Errorf("imported %d and skipped %d (prepared %d), want 1 and 2", imported, skipped, prepared)
+	}
+
+	imported, skipped, err = importBookmarks(db, bookmarks, prepare)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported != 0 || skipped != 3 {
+		t.Errorf("importing again imported %d and skipped %d, want 0 and 3", imported, skipped)
+	}
+}
@@ -5,40 +5,79 @@ package cmd
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"strings"
 
 	"github.com/lukasmwerner/mark/store"
+	"github.com/lukasmwerner/mark/tagger"
 	"github.com/spf13/cobra"
 )
 
+var importFrom string
+var importProfile string
+var importSuggestTags bool
+
 // importCmd represents the import command
 var importCmd = &cobra.Command{
-	Use:   "import <file>",
-	Short: "Lets you import bookmarks from an existing csv",
+	Use:   "import [file]",
+	Short: "Lets you import bookmarks from an existing csv or browser profile",
 	Long: `Import bookmarks from an existing csv file.
 
 csv format:
 title,description,tags,url
 "Title","Description","tag1,tag2","https://example.com",
+
+Bookmarks can also be read directly out of a browser profile, folders are
+turned into tags and links that are already saved are skipped:
+mark import --from firefox [--profile ~/.mozilla/firefox/abcd.default-release]
+mark import --from chrome [--profile ~/.config/google-chrome/Default]
+
+With --suggest-tags tags are added to every imported bookmark the same way
+mark add --suggest-tags suggests them, without asking.
 `,
-	Args: cobra.ExactArgs(1),
+	Args: func(cmd *cobra.Command, args []string) error {
+		if importFrom != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		if len(args) != 1 {
+			return errors.New("requires a csv file or --from firefox|chrome")
+		}
+		return nil
+	},
 	Run: func(cmd *cobra.Command, args []string) {
+		if importFrom != "" {
+			importFromBrowser(importFrom, importProfile, importSuggestTags)
+			return
+		}
+
 		f, err := os.Open(args[0])
 		if err != nil {
 			fmt.Println(err.Error())
 			return
 		}
+		defer f.Close()
 
 		db, err := store.Open()
 		if err != nil {
 			fmt.Println(err.Error())
 			return
 		}
+		defer db.Close()
 		r := csv.NewReader(f)
 
+		rs := loadRules(db)
+		autoTag := func(bm *store.Bookmark) {}
+		if importSuggestTags {
+			autoTag, err = importTagger(db)
+			if err != nil {
+				fmt.Println(err.Error())
+				return
+			}
+		}
+
 		for {
 			records, err := r.Read()
 			if err == io.EOF {
@@ -52,6 +91,8 @@ title,description,tags,url
 				Tags:        strings.Split(records[2], ","),
 				Url:         records[3],
 			}
+			bm.Tags = rs.Apply(bm.Tags, rulesPage(bm, ""))
+			autoTag(&bm)
 			id, err := store.InsertBookmark(db, bm)
 			if err != nil {
 				fmt.Println(err.Error())
@@ -74,4 +115,108 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// importCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	importCmd.Flags().StringVar(&importFrom, "from", "", "Browser to import from: firefox,chrome")
+	importCmd.Flags().StringVar(&importProfile, "profile", "", "Path to the browser profile directory (default: autodetect)")
+	importCmd.Flags().BoolVar(&importSuggestTags, "suggest-tags", false, "Adds suggested tags to every imported bookmark (see mark add --suggest-tags)")
+}
+
+func importFromBrowser(from, profile string, suggestTags bool) {
+	var read func(profile string) ([]store.Bookmark, error)
+	var find func() (string, error)
+	switch from {
+	case "firefox":
+		read, find = readFirefoxBookmarks, findFirefoxProfile
+	case "chrome", "chromium":
+		read, find = readChromeBookmarks, findChromeProfile
+	default:
+		fmt.Println("unknown browser:", from)
+		return
+	}
+
+	if profile == "" {
+		p, err := find()
+		if err != nil {
+			fmt.Println("unable to find a profile, pass one with --profile:", err.Error())
+			return
+		}
+		profile = p
+	}
+	fmt.Println("profile", profile)
+
+	bookmarks, err := read(profile)
+	if err != nil {
+		fmt.Println("unable to read bookmarks:", err.Error())
+		return
+	}
+
+	db, err := store.Open()
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	defer db.Close()
+
+	rs := loadRules(db)
+	autoTag := func(bm *store.Bookmark) {}
+	if suggestTags {
+		autoTag, err = importTagger(db)
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+	}
+
+	imported, skipped, err := importBookmarks(db, bookmarks, func(bm *store.Bookmark) {
+		bm.Tags = rs.Apply(bm.Tags, rulesPage(*bm, ""))
+		autoTag(bm)
+	})
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	fmt.Printf("imported %d bookmarks, skipped %d already saved\n", imported, skipped)
+}
+
+// importBookmarks saves the bookmarks that are not saved yet, prepare is
+// called on each of them before they are.
+func importBookmarks(db *store.DB, bookmarks []store.Bookmark, prepare func(bm *store.Bookmark)) (int, int, error) {
+	imported, skipped := 0, 0
+	for _, bm := range bookmarks {
+		_, exists, err := store.FindDuplicate(db, bm.Url)
+		if err != nil {
+			return imported, skipped, err
+		}
+		if exists {
+			skipped++
+			continue
+		}
+		prepare(&bm)
+		id, err := store.InsertBookmark(db, bm)
+		if err != nil {
+			return imported, skipped, err
+		}
+		imported++
+		fmt.Println(id, "-", bm.Url)
+	}
+	return imported, skipped, nil
+}
+
+// importTagger adds suggested tags to bookmarks as they are imported. The
+// model is only asked until it fails once, so an unreachable ollama does not
+// slow down the whole import.
+func importTagger(db *store.DB) (func(bm *store.Bookmark), error) {
+	vocabulary, err := store.TagNames(db)
+	if err != nil {
+		return nil, err
+	}
+	t := tagger.FromEnv()
+	return func(bm *store.Bookmark) {
+		in := tagger.Input{Url: bm.Url, Title: bm.Title, Description: bm.Description}
+		suggested, err := t.Suggest(in, vocabulary)
+		if err != nil && t.LLM != nil {
+			fmt.Println("unable to ask the model for tags, only using existing tags from now on:", err.Error())
+			t.LLM = nil
+		}
+		bm.Tags = cleanTags(append(bm.Tags, suggested...))
+	}, nil
 }
@@ -12,9 +12,9 @@ import (
 
 // keysDeleteCmd represents the delete command
 var keysDeleteCmd = &cobra.Command{
-	Use:   "delete <key>",
+	Use:   "delete <id|label>",
 	Short: "Delete a key",
-	Long:  `Pass in the key to delete from the allowed api keys for the local http server`,
+	Long:  `Pass in the id or label (see mark keys) of the key to delete from the allowed api keys for the local http server`,
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		db, err := store.Open()
@@ -24,7 +24,14 @@ var keysDeleteCmd = &cobra.Command{
 		}
 		defer db.Close()
 
-		err = store.DeleteKey(db, args[0])
+		keys, err := store.OpenKeys(db)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer keys.Close()
+
+		err = store.DeleteKey(keys, args[0])
 		if err != nil {
 			fmt.Println(err)
 			return
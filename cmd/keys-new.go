@@ -5,18 +5,38 @@ package cmd
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/google/uuid"
 	"github.com/lukasmwerner/mark/store"
 	"github.com/spf13/cobra"
 )
 
+var keyLabel string
+var keyScope string
+var keyExpires string
+var keyThisDevice bool
+
 // newKeyCmd represents the server command
 var newKeyCmd = &cobra.Command{
 	Use:   "new",
 	Short: "Generate a new key",
-	Long:  `Makes a new key for use in the mark http server (web app, web extension and raycast-extension)`,
+	Long: `Makes a new key for use in the mark http server (web app, web extension and raycast-extension)
+
+The key is only shown once, mark only keeps a hash of it. Read keys can search
+and read bookmarks, write keys can also add, change and delete them. Keys made
+with --this-device only work on this device, even if the key store is shared.
+
+Example:
+mark keys new --label raycast --scope read --expires 90d`,
 	Run: func(cmd *cobra.Command, args []string) {
+		expiresAt, err := parseExpiry(keyExpires, time.Now())
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
 		db, err := store.Open()
 		if err != nil {
 			fmt.Println(err)
@@ -24,13 +44,20 @@ var newKeyCmd = &cobra.Command{
 		}
 		defer db.Close()
 
-		key := generateKey()
-		err = store.AddKey(db, key)
+		keys, err := store.OpenKeys(db)
 		if err != nil {
 			fmt.Println(err)
 			return
 		}
-		fmt.Println("key: ", key)
+		defer keys.Close()
+
+		secret, key, err := store.NewKey(keys, keyLabel, keyScope, expiresAt, keyThisDevice)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println("key: ", secret)
+		fmt.Printf("(%s key %d, it will not be shown again)\n", key.Scope, key.Id)
 	},
 }
 
@@ -46,8 +73,28 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// serverCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	newKeyCmd.Flags().StringVarP(&keyLabel, "label", "l", "", "What the key is for, e.g. raycast or work chrome")
+	newKeyCmd.Flags().StringVar(&keyScope, "scope", store.ScopeWrite, "What the key may do: read,write")
+	newKeyCmd.Flags().StringVar(&keyExpires, "expires", "", "When the key stops working, a duration (90d, 12h) or a date (2026-01-31)")
+	newKeyCmd.Flags().BoolVar(&keyThisDevice, "this-device", false, "Only accepts the key on this device")
 }
 
-func generateKey() string {
-	return fmt.Sprintf("%x", uuid.New().String())
+// parseExpiry reads a duration from now, which can be in days, or a date. An
+// empty value never expires.
+func parseExpiry(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return now.AddDate(0, 0, n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return now.Add(d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid expiry %q, use a duration like 90d or a date like 2026-01-31", value)
 }
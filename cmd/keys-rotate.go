@@ -0,0 +1,74 @@
+/*
+Copyright © 2025 Lukas Werner <me@lukaswerner.com>
+*/
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lukasmwerner/mark/store"
+	"github.com/spf13/cobra"
+)
+
+var rotateExpires string
+
+// keysRotateCmd represents the rotate command
+var keysRotateCmd = &cobra.Command{
+	Use:   "rotate <id|label>",
+	Short: "Replace a key with a new one",
+	Long: `Makes a new secret for a key (see mark keys), the old one stops working right
+away. The label, scope and device stay the same, as does the expiry unless
+--expires is given.
+
+Example:
+mark keys rotate raycast --expires 90d`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		expiresAt, err := parseExpiry(rotateExpires, time.Now())
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		db, err := store.Open()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer db.Close()
+
+		keys, err := store.OpenKeys(db)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer keys.Close()
+
+		secret, key, err := store.RotateKey(keys, args[0], expiresAt)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println("key: ", secret)
+		fmt.Printf("(%s key %d, it will not be shown again)\n", key.Scope, key.Id)
+		if key.Expired(time.Now()) {
+			fmt.Println("the key has expired, give it a new expiry with --expires")
+		}
+	},
+}
+
+func init() {
+	keysCmd.AddCommand(keysRotateCmd)
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// keysRotateCmd.PersistentFlags().String("foo", "", "A help for foo")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	// keysRotateCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	keysRotateCmd.Flags().StringVar(&rotateExpires, "expires", "", "When the new key stops working, a duration (90d, 12h) or a date (2026-01-31)")
+}
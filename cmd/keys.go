@@ -5,6 +5,9 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
 
 	"github.com/lukasmwerner/mark/store"
 	"github.com/spf13/cobra"
@@ -14,6 +17,13 @@ import (
 var keysCmd = &cobra.Command{
 	Use:   "keys",
 	Short: "List all allowed api keys for the local http server",
+	Long: `Lists the api keys by label along with when they were last used. Keys are
+only stored as hashes, the key itself is shown once by mark keys new.
+
+Keys are kept in keys.db next to data.db (or MARK_KEYS_LOCATION), which is
+never synchronized with your other devices, so each device has its own keys.
+To share keys, point MARK_KEYS_LOCATION at a synced file and make keys that
+should stay on one device with mark keys new --this-device.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		db, err := store.Open()
 		if err != nil {
@@ -22,18 +32,50 @@ var keysCmd = &cobra.Command{
 		}
 		defer db.Close()
 
-		keys, err := store.GetKeys(db)
+		keys, err := store.OpenKeys(db)
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		defer keys.Close()
+
+		list, err := store.ListKeys(keys)
 		if err != nil {
 			fmt.Println(err.Error())
 			return
 		}
-		for _, key := range keys {
-			fmt.Println(key)
+		if len(list) == 0 {
+			fmt.Println("no keys, make one with mark keys new")
+			return
 		}
 
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tLABEL\tKEY\tSCOPE\tDEVICE\tCREATED\tLAST USED\tEXPIRES")
+		now := time.Now()
+		for _, key := range list {
+			expires := formatKeyTime(key.ExpiresAt, "never")
+			if key.Expired(now) {
+				expires = "expired"
+			}
+			device := key.Device
+			if device == "" {
+				device = "any"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s...\t%s\t%s\t%s\t%s\t%s\n", key.Id, key.Label, key.Prefix, key.Scope, device,
+				formatKeyTime(key.CreatedAt, "-"), formatKeyTime(key.LastUsed, "never"), expires)
+		}
+		w.Flush()
 	},
 }
 
+func formatKeyTime(value, empty string) string {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return empty
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
 func init() {
 	rootCmd.AddCommand(keysCmd)
 
@@ -0,0 +1,17 @@
+//go:build !testdb
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/lukasmwerner/mark/store"
+)
+
+// openTestDB skips the test, a database can only be opened without cr-sqlite
+// when the tests are built with the testdb tag.
+func openTestDB(t *testing.T) *store.DB {
+	t.Helper()
+	t.Skip(`the tests need a database, run them with -tags "fts5 testdb"`)
+	return nil
+}
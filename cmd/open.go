@@ -4,6 +4,7 @@ Copyright © 2024 Lukas Werner <me@lukaswerner.com>
 package cmd
 
 import (
+	"database/sql"
 	"fmt"
 	"strings"
 
@@ -13,6 +14,8 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var openArchived bool
+
 // openCmd represents the open command
 var openCmd = &cobra.Command{
 	Use:   "open",
@@ -29,7 +32,7 @@ var openCmd = &cobra.Command{
 
 		searchQuery := strings.Join(args, " ")
 
-		bookmarks, err := store.SearchBookmarks(db, searchQuery)
+		bookmarks, err := searchBookmarks(db, searchQuery)
 		if err != nil {
 			fmt.Println("unable to search bookmarks", err.Error())
 			return
@@ -39,27 +42,42 @@ var openCmd = &cobra.Command{
 			return
 		}
 
-		if len(bookmarks) == 1 {
-			fmt.Printf("Opening %s %s\n", bookmarks[0].Title, bookmarks[0].Url)
-			browser.OpenURL(bookmarks[0].Url)
-			return
+		if len(bookmarks) != 1 {
+			pickedIndex := 0
+			options := make([]huh.Option[int], len(bookmarks))
+			for i, bookmark := range bookmarks {
+				options[i] = huh.NewOption(bookmark.Title, i)
+			}
+			err = huh.NewSelect[int]().Title("Pick your link").Options(options...).Value(&pickedIndex).Run()
+			if err != nil {
+				if err == huh.ErrUserAborted {
+					return
+				}
+				fmt.Println(err.Error())
+				return
+			}
+			bookmarks = []store.Bookmark{bookmarks[pickedIndex]}
 		}
 
-		pickedLink := ""
-		options := make([]huh.Option[string], len(bookmarks))
-		for i, bookmark := range bookmarks {
-			options[i] = huh.NewOption(bookmark.Title, bookmark.Url)
-		}
-		err = huh.NewSelect[string]().Title("Pick your link").Options(options...).Value(&pickedLink).Run()
-		if err != nil {
-			if err == huh.ErrUserAborted {
+		if openArchived {
+			archive, err := store.GetArchive(db, bookmarks[0].Id)
+			if err == sql.ErrNoRows {
+				fmt.Println("no archive saved for", bookmarks[0].Url)
 				return
 			}
-			fmt.Println(err.Error())
+			if err != nil {
+				fmt.Println("unable to load archive", err.Error())
+				return
+			}
+			fmt.Printf("Opening archive of %s %s\n", bookmarks[0].Title, bookmarks[0].Url)
+			if err := openArchive(archive); err != nil {
+				fmt.Println("unable to open archive", err.Error())
+			}
 			return
 		}
 
-		err = browser.OpenURL(pickedLink)
+		fmt.Printf("Opening %s %s\n", bookmarks[0].Title, bookmarks[0].Url)
+		err = browser.OpenURL(bookmarks[0].Url)
 		if err != nil {
 			fmt.Println("unable to open url in browser", err.Error())
 			return
@@ -80,4 +98,5 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// openCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	openCmd.Flags().BoolVar(&openArchived, "archived", false, "Opens the saved offline copy instead of the live page")
 }
@@ -0,0 +1,89 @@
+/*
+Copyright © 2025 Lukas Werner <me@lukaswerner.com>
+*/
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/lukasmwerner/mark/metadata"
+	"github.com/lukasmwerner/mark/store"
+	"github.com/spf13/cobra"
+)
+
+var reindexMissing bool
+
+// reindexCmd represents the reindex command
+var reindexCmd = &cobra.Command{
+	Use:   "reindex [search query]",
+	Short: "Fetches the pages again to index their text for searching",
+	Long: `Downloads every bookmarked page (or the ones matching the search query) and
+indexes the readable text so it can be found with: mark search --content
+
+The page text is only stored locally and is not synchronized.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := store.Open()
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		defer db.Close()
+
+		var bookmarks []store.Bookmark
+		searchQuery := strings.Join(args, " ")
+		if searchQuery == "" {
+			bookmarks, err = store.ListBookmarks(db, store.ListOptions{})
+		} else {
+			bookmarks, err = searchBookmarks(db, searchQuery)
+		}
+		if err != nil {
+			fmt.Println("unable to list bookmarks", err.Error())
+			return
+		}
+
+		indexed := 0
+		for _, bookmark := range bookmarks {
+			if reindexMissing {
+				exists, err := store.HasContent(db, bookmark.Id)
+				if err != nil {
+					fmt.Println(err.Error())
+					return
+				}
+				if exists {
+					continue
+				}
+			}
+
+			fmt.Println("indexing", bookmark.Url)
+			link, err := url.Parse(bookmark.Url)
+			if err != nil {
+				fmt.Println("\tunable to parse url", err.Error())
+				continue
+			}
+			page, err := metadata.DefaultFetcher.Page(link)
+			if err != nil {
+				fmt.Println("\tunable to fetch page", err.Error())
+				continue
+			}
+			if page.Doc == nil {
+				fmt.Println("\tskipping", page.ContentType)
+				continue
+			}
+			err = store.SaveContent(db, bookmark.Id, metadata.ReadableText(page.Doc))
+			if err != nil {
+				fmt.Println("\tunable to save content", err.Error())
+				continue
+			}
+			indexed++
+		}
+		fmt.Printf("indexed %d pages\n", indexed)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reindexCmd)
+
+	reindexCmd.Flags().BoolVar(&reindexMissing, "missing", false, "Only index pages that have not been indexed yet")
+}
@@ -5,7 +5,6 @@ package cmd
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"strings"
 
@@ -41,6 +40,10 @@ var (
 
 	statusBackground = lipgloss.Color("238")
 
+	errorStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("203")).
+			Background(statusBackground)
+
 	headerStyle = lipgloss.NewStyle().
 			BorderForeground(lipgloss.Color("240")).
 			Bold(true)
@@ -57,33 +60,56 @@ var (
 
 	modalstyle = lipgloss.NewStyle().
 			Border(lipgloss.RoundedBorder())
+
+	savedStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("250")).
+			Background(statusBackground)
+
+	activeSavedStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("229")).
+				Background(lipgloss.Color("57"))
+)
+
+// matches are shown bold and underlined, only those attributes are reset
+// afterwards so the row colors stay intact
+const (
+	highlightStart = "\x1b[1;4m"
+	highlightEnd   = "\x1b[22;24m"
 )
 
+var searchWeights string
+var searchRaw bool
+var searchFuzzy bool
+
 type rootAppModel struct {
 	db           *store.DB
 	input        textinput.Model
 	table        *table.Table
-	rows         []store.Bookmark
+	rows         []store.SearchResult
+	opts         store.SearchOptions
+	lastQuery    string
+	refresh      bool
+	saved        []store.SavedSearch
+	savedIndex   int
 	currentIndex int
 	width        int
 	height       int
 	mode         mode
 	rowsCount    int
+	err          error
 }
 
 func (m rootAppModel) Init() tea.Cmd { return nil }
 
 func (m rootAppModel) updateTable() rootAppModel {
 
-	bmCount := len(m.rows)
-
-	bookmarks, err := store.SearchBookmarks(m.db, m.input.Value())
-	if err != nil {
-		log.Panicln(err)
+	if m.input.Value() == m.lastQuery && m.rowsCount != 0 && !m.refresh {
 		return m
 	}
 
-	if len(bookmarks) == bmCount {
+	bookmarks, err := store.Search(m.db, m.input.Value(), m.opts)
+	m.err = err
+	if err != nil {
 		return m
 	}
 
@@ -94,10 +120,19 @@ func (m rootAppModel) updateTable() rootAppModel {
 
 	m.rows = bookmarks
 	m.rowsCount = len(bookmarks)
+	m.lastQuery = m.input.Value()
+	m.refresh = false
 	m.currentIndex = 1
 
 	for _, bookmark := range bookmarks {
-		m.table.Row(strings.TrimSpace(bookmark.Title), bookmark.Description, strings.Join(bookmark.Tags, ","), bookmark.Url)
+		title, description := bookmark.Title, bookmark.Description
+		if bookmark.TitleHighlight != "" {
+			title = bookmark.TitleHighlight
+		}
+		if bookmark.DescriptionHighlight != "" {
+			description = bookmark.DescriptionHighlight
+		}
+		m.table.Row(strings.TrimSpace(title), description, strings.Join(bookmark.Tags, ","), bookmark.Url)
 	}
 
 	return m
@@ -127,7 +162,7 @@ func (m rootAppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.mode = NORMAL
 
 		case " ":
-			if m.mode == NORMAL {
+			if m.mode == NORMAL && m.rowsCount > 0 {
 				m.mode = PREVIEW
 			} else if m.mode == PREVIEW {
 				m.mode = NORMAL
@@ -159,6 +194,28 @@ func (m rootAppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				cmds = append(cmds, m.input.Focus())
 				return m, tea.Batch(cmds...)
 			}
+		case "tab", "shift+tab":
+			// quick switch between saved searches, wrapping back around to
+			// no search at all
+			if m.mode == NORMAL && len(m.saved) > 0 {
+				step := 1
+				if msg.String() == "shift+tab" {
+					step = len(m.saved)
+				}
+				m.savedIndex = (m.savedIndex+1+step)%(len(m.saved)+1) - 1
+				if m.savedIndex == -1 {
+					m.input.SetValue("")
+				} else {
+					m.input.SetValue(store.SavedPrefix + m.saved[m.savedIndex].Name)
+				}
+				m = m.updateTable()
+			}
+		case "f":
+			if m.mode == NORMAL {
+				m.opts.Fuzzy = !m.opts.Fuzzy
+				m.refresh = true
+				m = m.updateTable()
+			}
 		case "j", "up":
 			if m.mode == NORMAL && m.currentIndex+1 <= m.rowsCount {
 				m.currentIndex += 1
@@ -175,6 +232,7 @@ func (m rootAppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		var cmd tea.Cmd
 		m.input, cmd = m.input.Update(msg)
 		cmds = append(cmds, cmd)
+		m = m.updateTable()
 	}
 
 	return m, tea.Batch(cmds...)
@@ -191,6 +249,19 @@ func (m rootAppModel) View() string {
 	case PREVIEW:
 		statusBar = previewModeStyle.Render(" " + string(m.mode) + " ")
 	}
+	if m.opts.Fuzzy {
+		statusBar += searchModeStyle.Render(" FUZZY ")
+	}
+	for i, saved := range m.saved {
+		if i == m.savedIndex && m.input.Value() == store.SavedPrefix+saved.Name {
+			statusBar += activeSavedStyle.Render(" " + store.SavedPrefix + saved.Name + " ")
+		} else {
+			statusBar += savedStyle.Render(" " + store.SavedPrefix + saved.Name + " ")
+		}
+	}
+	if m.err != nil {
+		statusBar += errorStyle.Render(" " + m.err.Error())
+	}
 	statusBar = lipgloss.PlaceHorizontal(m.width, lipgloss.Left, statusBar, lipgloss.WithWhitespaceBackground(statusBackground))
 
 	if m.mode == PREVIEW {
@@ -200,6 +271,9 @@ func (m rootAppModel) View() string {
 		contents += "tags: " + strings.Join(m.rows[m.currentIndex-1].Tags, ", ") + "\n"
 		contents += "url: " + m.rows[m.currentIndex-1].Url + "\n"
 		contents += "desc: \n" + textSty.Render(m.rows[m.currentIndex-1].Description)
+		if summary := m.rows[m.currentIndex-1].Summary; summary != "" {
+			contents += "\nsummary: \n" + textSty.Render(summary)
+		}
 
 		modal := modalstyle.Width(m.width - 4).Render(contents)
 
@@ -238,6 +312,14 @@ file sync service. This is sort-of explained the following blog post:
 	// has an action associated with it:
 	Run: func(cmd *cobra.Command, args []string) {
 
+		opts, err := searchOptions()
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		opts.HighlightStart, opts.HighlightEnd = highlightStart, highlightEnd
+		opts.Prefix = true
+
 		db, err := store.Open()
 		if err != nil {
 			fmt.Println("unable to open database", err.Error())
@@ -251,7 +333,14 @@ file sync service. This is sort-of explained the following blog post:
 		input := textinput.New()
 		input.Placeholder = "Search / Filter"
 
-		m := rootAppModel{db: db, table: t, input: input, currentIndex: 1, rowsCount: 0, mode: NORMAL}
+		saved, err := store.ListSavedSearches(db)
+		if err != nil {
+			fmt.Println("unable to load saved searches", err.Error())
+			return
+		}
+
+		m := rootAppModel{db: db, table: t, input: input, opts: opts, saved: saved, savedIndex: -1, currentIndex: 1, rowsCount: 0, mode: NORMAL}
+		m = m.updateTable()
 
 		t.Border(lipgloss.NormalBorder())
 
@@ -281,8 +370,34 @@ func init() {
 	// will be global for your application.
 
 	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.mark.yaml)")
+	rootCmd.PersistentFlags().BoolVar(&searchRaw, "raw", false, "Pass search queries to sqlite fts5 as is, instead of escaping them (same as starting the query with fts:)")
+	rootCmd.PersistentFlags().BoolVar(&searchFuzzy, "fuzzy", false, "Also show typo tolerant matches after the exact ones (toggle with f in the TUI)")
+	rootCmd.PersistentFlags().StringVar(&searchWeights, "weights", "", "Search ranking weights, e.g. title=10,tags=5,description=2,url=1,content=0.5")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	// rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
+
+// searchOptions are the options shared by everything that searches.
+func searchOptions() (store.SearchOptions, error) {
+	opts := store.SearchOptions{Raw: searchRaw, Fuzzy: searchFuzzy}
+	if searchWeights != "" {
+		weights, err := store.ParseWeights(searchWeights)
+		if err != nil {
+			return opts, err
+		}
+		opts.Weights = &weights
+	}
+	return opts, nil
+}
+
+// searchBookmarks searches with the options from the flags, for the commands
+// that act on the bookmarks they find.
+func searchBookmarks(db *store.DB, query string) ([]store.Bookmark, error) {
+	opts, err := searchOptions()
+	if err != nil {
+		return nil, err
+	}
+	return store.SearchBookmarks(db, query, opts)
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/lukasmwerner/mark/store"
+)
+
+func TestSearchBookmarksUsesFlags(t *testing.T) {
+	db := openTestDB(t)
+	for _, bm := range []store.Bookmark{
+		{Url: "https://go.dev", Title: "Golang"},
+		{Url: "https://rust-lang.org", Title: "Rustacean"},
+	} {
+		if _, err := store.InsertBookmark(db, bm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { searchRaw, searchFuzzy, searchWeights = false, false, "" })
+
+	count := func(query string) int {
+		t.Helper()
+		bookmarks, err := searchBookmarks(db, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(bookmarks)
+	}
+
+	if n := count("golang OR rustacean"); n != 0 {
+		t.Errorf("escaped query found %d bookmarks", n)
+	}
+	searchRaw = true
+	if n := count("golang OR rustacean"); n != 2 {
+		t.Errorf("--raw query found %d bookmarks, want 2", n)
+	}
+	searchRaw = false
+
+	if n := count("rustcean"); n != 0 {
+		t.Errorf("exact search for a typo found %d bookmarks", n)
+	}
+	searchFuzzy = true
+	if n := count("rustcean"); n != 1 {
+		t.Errorf("--fuzzy search for a typo found %d bookmarks, want 1", n)
+	}
+	searchFuzzy = false
+
+	searchWeights = "title=nope"
+	if _, err := searchBookmarks(db, "golang"); err == nil {
+		t.Error("invalid --weights were ignored")
+	}
+}
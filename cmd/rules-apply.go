@@ -0,0 +1,95 @@
+/*
+Copyright © 2025 Lukas Werner <me@lukaswerner.com>
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lukasmwerner/mark/rules"
+	"github.com/lukasmwerner/mark/store"
+	"github.com/spf13/cobra"
+)
+
+var rulesDryRun bool
+
+// rulesApplyCmd represents the rules apply command
+var rulesApplyCmd = &cobra.Command{
+	Use:   "apply [search query]",
+	Short: "Tags the saved bookmarks (or the ones matching the query) with the rules",
+	Long: `Adds the tags of matching rules to bookmarks that are already saved, tags are
+never removed. Rules on content_type do not match here as the pages are not
+fetched again.
+
+Use --dry-run to see the changes without saving them.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := store.Open()
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		defer db.Close()
+
+		rs := loadRules(db)
+		if len(rs) == 0 {
+			fmt.Println("no rules, see mark rules --help")
+			return
+		}
+
+		var bookmarks []store.Bookmark
+		searchQuery := strings.Join(args, " ")
+		if searchQuery == "" {
+			bookmarks, err = store.ListBookmarks(db, store.ListOptions{})
+		} else {
+			bookmarks, err = searchBookmarks(db, searchQuery)
+		}
+		if err != nil {
+			fmt.Println("unable to list bookmarks", err.Error())
+			return
+		}
+
+		changed := tagWithRules(db, rs, bookmarks, rulesDryRun)
+		if rulesDryRun {
+			fmt.Printf("would tag %d bookmarks\n", changed)
+		} else {
+			fmt.Printf("tagged %d bookmarks\n", changed)
+		}
+	},
+}
+
+func init() {
+	rulesCmd.AddCommand(rulesApplyCmd)
+
+	rulesApplyCmd.Flags().BoolVar(&rulesDryRun, "dry-run", false, "Only show what would be tagged")
+}
+
+// tagWithRules adds the tags of the matching rules to the bookmarks and prints
+// what was added, bookmarks no rule adds a tag to are left alone. It returns
+// how many bookmarks were (or with dryRun would be) tagged.
+func tagWithRules(db *store.DB, rs rules.Rules, bookmarks []store.Bookmark, dryRun bool) int {
+	changed := 0
+	for _, bookmark := range bookmarks {
+		updated := bookmark
+		updated.Tags = rs.Apply(bookmark.Tags, rulesPage(bookmark, ""))
+		if len(updated.Tags) == len(bookmark.Tags) {
+			continue
+		}
+
+		added := []string{}
+		for _, tag := range updated.Tags[len(bookmark.Tags):] {
+			added = append(added, "+"+tag)
+		}
+		fmt.Println(bookmark.Url)
+		fmt.Println("\t" + strings.Join(added, " "))
+		changed++
+
+		if dryRun {
+			continue
+		}
+		if err := store.UpdateBookmark(db, bookmark, updated); err != nil {
+			fmt.Println("\tunable to update bookmark", err.Error())
+		}
+	}
+	return changed
+}
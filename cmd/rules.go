@@ -0,0 +1,85 @@
+/*
+Copyright © 2025 Lukas Werner <me@lukaswerner.com>
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lukasmwerner/mark/rules"
+	"github.com/lukasmwerner/mark/store"
+	"github.com/spf13/cobra"
+)
+
+// rulesCmd represents the rules command
+var rulesCmd = &cobra.Command{
+	Use:   "rules",
+	Short: "Lists the rules used to tag bookmarks automatically",
+	Long: `Rules add tags to bookmarks as they are saved with mark add, mark import and
+the server. They are read from rules.json in the store location (next to
+data.db) or the file set with MARK_RULES:
+
+[
+	{"url": "github.com/*", "tags": ["code"]},
+	{"url": "*.arxiv.org", "tags": ["paper"]},
+	{"title": "(?i)postgres", "tags": ["db"]},
+	{"content_type": "application/pdf", "tags": ["pdf"]}
+]
+
+url is a glob matched against the host, or the host and path when it has a
+/ in it, title is a regular expression and content_type a prefix of the
+page's media type. Every condition of a rule has to match.
+
+Use mark rules apply to tag the bookmarks that are already saved.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := store.Open()
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		defer db.Close()
+
+		file := rules.Path(db.StoreLoc)
+		rs, err := rules.Load(file)
+		if err != nil {
+			fmt.Println("unable to read rules", err.Error())
+			return
+		}
+		fmt.Println("rules", file)
+		for _, r := range rs {
+			conditions := []string{}
+			if r.Url != "" {
+				conditions = append(conditions, "url "+r.Url)
+			}
+			if r.Title != "" {
+				conditions = append(conditions, "title "+r.Title)
+			}
+			if r.ContentType != "" {
+				conditions = append(conditions, "content_type "+r.ContentType)
+			}
+			fmt.Printf("%s -> %s\n", strings.Join(conditions, " and "), strings.Join(r.Tags, ","))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rulesCmd)
+}
+
+// loadRules reads the rules file of the store. A broken rules file is reported
+// and treated as having no rules, so bookmarks can still be saved.
+func loadRules(db *store.DB) rules.Rules {
+	rs, err := rules.Load(rules.Path(db.StoreLoc))
+	if err != nil {
+		fmt.Println("unable to read rules", err.Error())
+		return rules.Rules{}
+	}
+	return rs
+}
+
+// rulesPage is what the rules see of a bookmark, the content type is only
+// known when the page was fetched.
+func rulesPage(bm store.Bookmark, contentType string) rules.Page {
+	return rules.Page{Url: bm.Url, Title: bm.Title, ContentType: contentType}
+}
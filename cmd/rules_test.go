@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/lukasmwerner/mark/rules"
+	"github.com/lukasmwerner/mark/store"
+)
+
+func TestTagWithRules(t *testing.T) {
+	db := openTestDB(t)
+	for _, bm := range []store.Bookmark{
+		{Url: "https://github.com/golang/go", Title: "Go", Tags: []string{"go"}},
+		{Url: "https://example.com/post", Title: "A post", Tags: []string{"blog", "later"}},
+		{Url: "https://github.com/rust-lang/rust", Title: "Rust", Tags: []string{"code"}},
+	} {
+		if _, err := store.InsertBookmark(db, bm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rs, err := rules.Parse([]byte(`[
+		{"url": "github.com/*", "tags": ["code"]},
+		{"title": "^Go$", "tags": ["go", "lang"]},
+		{"content_type": "text/html", "tags": ["web"]}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := store.ListBookmarks(db, store.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if changed := tagWithRules(db, rs, before, true); changed != 1 {
+		t.Errorf("a dry run would tag %d bookmarks, want 1", changed)
+	}
+	unchanged, err := store.ListBookmarks(db, store.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(unchanged, before) {
+		t.Errorf("a dry run changed the bookmarks to %+v", unchanged)
+	}
+
+	if changed := tagWithRules(db, rs, before, false); changed != 1 {
+		t.Errorf("tagged %d bookmarks, want 1", changed)
+	}
+	want := map[string][]string{
+		"https://github.com/golang/go": {"go", "code", "lang"},
+		// no rule matches, content_type rules never do here
+		"https://example.com/post": {"blog", "later"},
+		// already has the tag of the matching rule
+		"https://github.com/rust-lang/rust": {"code"},
+	}
+	for _, old := range before {
+		bm, err := store.GetBookmarkById(db, old.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(bm.Tags, want[bm.Url]) {
+			t.Errorf("%s is tagged %v, want %v", bm.Url, bm.Tags, want[bm.Url])
+		}
+		if len(old.Tags) == len(want[old.Url]) && !reflect.DeepEqual(bm, old) {
+			t.Errorf("%s was changed from %+v to %+v", old.Url, old, bm)
+		}
+	}
+
+	again, err := store.ListBookmarks(db, store.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed := tagWithRules(db, rs, again, false); changed != 0 {
+		t.Errorf("applying the rules again tagged %d bookmarks", changed)
+	}
+}
@@ -0,0 +1,39 @@
+/*
+Copyright © 2025 Lukas Werner <me@lukaswerner.com>
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lukasmwerner/mark/store"
+	"github.com/spf13/cobra"
+)
+
+// savedAddCmd represents the saved add command
+var savedAddCmd = &cobra.Command{
+	Use:   "add <name> <query>",
+	Short: "Save a search, replacing the query if the name is taken",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := store.Open()
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		defer db.Close()
+
+		name := strings.TrimPrefix(args[0], store.SavedPrefix)
+		query := strings.Join(args[1:], " ")
+		if err := store.SaveSearch(db, name, query); err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		fmt.Printf("saved %s%s: %s\n", store.SavedPrefix, name, query)
+	},
+}
+
+func init() {
+	savedCmd.AddCommand(savedAddCmd)
+}
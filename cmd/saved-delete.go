@@ -0,0 +1,37 @@
+/*
+Copyright © 2025 Lukas Werner <me@lukaswerner.com>
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lukasmwerner/mark/store"
+	"github.com/spf13/cobra"
+)
+
+// savedDeleteCmd represents the saved delete command
+var savedDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a saved search",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := store.Open()
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		defer db.Close()
+
+		if err := store.DeleteSavedSearch(db, strings.TrimPrefix(args[0], store.SavedPrefix)); err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		fmt.Println("Saved search deleted successfully")
+	},
+}
+
+func init() {
+	savedCmd.AddCommand(savedDeleteCmd)
+}
@@ -0,0 +1,43 @@
+/*
+Copyright © 2025 Lukas Werner <me@lukaswerner.com>
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/lukasmwerner/mark/store"
+	"github.com/spf13/cobra"
+)
+
+// savedListCmd represents the saved list command
+var savedListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the saved searches",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := store.Open()
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		defer db.Close()
+
+		searches, err := store.ListSavedSearches(db)
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		if len(searches) == 0 {
+			fmt.Println("no saved searches, add one with mark saved add <name> <query>")
+			return
+		}
+		for _, search := range searches {
+			fmt.Printf("%s%s\t%s\n", store.SavedPrefix, search.Name, search.Query)
+		}
+	},
+}
+
+func init() {
+	savedCmd.AddCommand(savedListCmd)
+}
@@ -0,0 +1,25 @@
+/*
+Copyright © 2025 Lukas Werner <me@lukaswerner.com>
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// savedCmd represents the saved command
+var savedCmd = &cobra.Command{
+	Use:   "saved",
+	Short: "Manage saved searches",
+	Long: `Saved searches are named queries that can be used as @name anywhere a search
+is made, and are synchronized like bookmarks are:
+mark saved add oncall tag:oncall
+mark open @oncall
+mark search @oncall site:github.com
+
+In the TUI tab and shift+tab switch between them.`,
+}
+
+func init() {
+	rootCmd.AddCommand(savedCmd)
+}
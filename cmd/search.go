@@ -0,0 +1,149 @@
+/*
+Copyright © 2025 Lukas Werner <me@lukaswerner.com>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lukasmwerner/mark/embeddings"
+	"github.com/lukasmwerner/mark/store"
+	"github.com/spf13/cobra"
+)
+
+var searchContent bool
+var searchOutputMode string
+var searchSemantic bool
+var searchBlend bool
+
+// searchCmd represents the search command
+var searchCmd = &cobra.Command{
+	Use:   "search <search query>",
+	Short: "Lists the bookmarks matching a search",
+	Long: `Prints every bookmark matching the search query.
+
+The same query syntax works everywhere a search is made (mark, mark open,
+show, edit and the server), words are matched against the title,
+description, tags and url and can be narrowed down with filters:
+	"exact phrase"          words in this order
+	tag:rust -tag:read      has (or does not have) a tag
+	site:github.com         saved from a site, including subdomains
+	title:postgres          word in the title
+	after:2025 before:2025-06-01
+	is:unread               not tagged read
+	@name                   a saved search (see mark saved)
+	fts:...                 passed to sqlite fts5 unchanged (or use --raw)
+
+With --content the text of the pages (see mark reindex) is searched too and
+the matching part of the page is shown.
+
+With --fuzzy close matches for misspelled words (kubernets, postgress) are
+listed after the exact ones, marked with a ~.
+
+With --semantic bookmarks are ranked by how close they are in meaning to the
+query instead of by matching words, this needs the bookmarks to be embedded
+first with mark embed. --blend mixes the word matches back in.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := store.Open()
+		if err != nil {
+			fmt.Println("unable to open database", err.Error())
+			return
+		}
+		defer db.Close()
+
+		searchQuery := strings.Join(args, " ")
+
+		opts, err := searchOptions()
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		opts.Content = searchContent
+		if searchOutputMode != "json" {
+			opts.HighlightStart, opts.HighlightEnd = highlightStart, highlightEnd
+		}
+
+		if err := checkSavedSearch(db, searchQuery); err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+
+		var results []store.SearchResult
+		if searchSemantic || searchBlend {
+			results, err = semanticSearch(db, embeddings.FromEnv(), searchQuery, opts, searchBlend)
+		} else {
+			results, err = store.Search(db, searchQuery, opts)
+		}
+		if err != nil {
+			fmt.Println("unable to search bookmarks", err.Error())
+			return
+		}
+
+		if searchOutputMode == "json" {
+			b, _ := json.Marshal(results)
+			os.Stdout.Write(b)
+			return
+		}
+
+		if len(results) == 0 && (searchSemantic || searchBlend) {
+			fmt.Println("found no bookmarks, have they been embedded with mark embed?")
+			return
+		}
+		if len(results) == 0 {
+			fmt.Println("found no bookmarks")
+			return
+		}
+		for _, result := range results {
+			title := result.Title
+			if result.TitleHighlight != "" {
+				title = result.TitleHighlight
+			}
+			if result.Fuzzy {
+				title = "~ " + title
+			}
+			fmt.Println(strings.TrimSpace(title))
+			fmt.Println("\t" + result.Url)
+			if result.Snippet != "" {
+				fmt.Println("\t" + strings.Join(strings.Fields(result.Snippet), " "))
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+
+	searchCmd.Flags().BoolVarP(&searchContent, "content", "c", false, "Also search the text of the pages")
+	searchCmd.Flags().StringVarP(&searchOutputMode, "mode", "m", "text", "Output mode: text,json")
+	searchCmd.Flags().BoolVar(&searchSemantic, "semantic", false, "Rank by meaning using embeddings (see mark embed)")
+	searchCmd.Flags().BoolVar(&searchBlend, "blend", false, "Rank by meaning and by matching words together (implies --semantic)")
+}
+
+// semanticSearch ranks by meaning, with blend the keyword matches are merged
+// in as well.
+func semanticSearch(db *store.DB, provider embeddings.Provider, query string, opts store.SearchOptions, blend bool) ([]store.SearchResult, error) {
+	semantic, err := store.SemanticSearch(db, provider, query, opts)
+	if err != nil || !blend {
+		return semantic, err
+	}
+	keyword, err := store.Search(db, query, opts)
+	if err != nil {
+		return keyword, err
+	}
+	return store.BlendResults(keyword, semantic), nil
+}
+
+// checkSavedSearch reports a missing saved search when the query is just
+// @name. Anywhere else an unknown @name is searched for as text.
+func checkSavedSearch(db *store.DB, query string) error {
+	name, ok := strings.CutPrefix(strings.TrimSpace(query), store.SavedPrefix)
+	if !ok || !store.ValidSavedSearchName(name) {
+		return nil
+	}
+	_, err := store.GetSavedSearch(db, name)
+	return err
+}
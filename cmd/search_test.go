@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lukasmwerner/mark/store"
+)
+
+func TestCheckSavedSearch(t *testing.T) {
+	db := openTestDB(t)
+	if err := store.SaveSearch(db, "review", "tag:review"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{"@review", "@golang tag:go", "talks by @golang", "golang"} {
+		if err := checkSavedSearch(db, query); err != nil {
+			t.Errorf("checkSavedSearch(%q): %v", query, err)
+		}
+	}
+	if err := checkSavedSearch(db, " @reveiw "); !errors.Is(err, store.ErrSavedSearchNotFound) {
+		t.Errorf("a search for just a missing saved search: %v", err)
+	}
+}
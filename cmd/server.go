@@ -4,139 +4,514 @@ Copyright © 2024 Lukas Werner <me@lukaswerner.com>
 package cmd
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
 	"strings"
 
+	"github.com/lukasmwerner/mark/api"
+	"github.com/lukasmwerner/mark/config"
+	"github.com/lukasmwerner/mark/embeddings"
+	"github.com/lukasmwerner/mark/metadata"
 	"github.com/lukasmwerner/mark/store"
+	"github.com/lukasmwerner/mark/tagger"
+	"github.com/lukasmwerner/mark/web"
 	"github.com/spf13/cobra"
 )
 
+var serverAddr string
+var serverPort int
+var serverTLSCert string
+var serverTLSKey string
+var serverSocket string
+var serverAllowedOrigins []string
+
 // serverCmd represents the server command
 var serverCmd = &cobra.Command{
 	Use:   "server",
 	Short: "Local HTTP server for managing bookmarks",
-	Long:  `Designed for hosting for applications where there is no strong storage api that can easily be synchronized with Dropbox, Google Drive, Syncthing or other cloud storage sync services.`,
+	Long: `Designed for hosting for applications where there is no strong storage api that can easily be synchronized with Dropbox, Google Drive, Syncthing or other cloud storage sync services.
+
+Listens on 127.0.0.1:1990 unless told otherwise, the same settings can be put
+in config.json in the store location (or the file set with MARK_CONFIG):
+{"server": {"addr": "127.0.0.1", "port": 1990, "tls_cert": "", "tls_key": "", "socket": ""}}
+Flags take precedence over the config file.
+
+Browsers only let pages and extensions on the allowed origins call the api,
+by default any chrome or firefox extension (an api key is still needed):
+{"server": {"allowed_origins": ["chrome-extension://<id>", "moz-extension://*", "http://localhost:3000", "https://*.example.com"]}}
+
+Open it in a browser to search, add and edit bookmarks with an api key, pass
+--addr 0.0.0.0 to use it from other machines on the network. The /save page
+is where the bookmarklet from mark bookmarklet saves pages from any browser.
+
+The api is served at /api/v1 and described by the OpenAPI document at
+/api/openapi.json. Bookmarks are listed a page at a time with
+GET /api/v1/bookmarks?limit=&sort=&tag=, pass the next_cursor of a page as
+cursor to get the next one. Errors are returned as {"error": "..."}. The
+unversioned /api routes are kept for older clients, they send and receive
+bookmarks with the same fields as /api/v1.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		searchOpts, err := searchOptions()
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+
+		conf, err := config.Load()
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		settings := serverSettings(cmd, conf.Server)
+		if (settings.TLSCert == "") != (settings.TLSKey == "") {
+			fmt.Println("both --tls-cert and --tls-key are needed to serve https")
+			return
+		}
+
 		db, err := store.Open()
 		if err != nil {
 			fmt.Println("error occured in opening db: ", err.Error())
 			return
 		}
 
-		http.Handle("GET /api/bookmarks/search", AuthRequired(db, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			query := r.URL.Query().Get("q")
-			if query == "" {
-				http.Error(w, "Missing query parameter", http.StatusBadRequest)
-				return
-			}
-			bookmarks, err := store.SearchBookmarks(db, query)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			w.Header().Set("Content-Type", "application/json")
-			jsonBytes, err := json.Marshal(bookmarks)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			w.Write(jsonBytes)
-		})))
+		keys, err := store.OpenKeys(db)
+		if err != nil {
+			fmt.Println("error occured in opening key store: ", err.Error())
+			return
+		}
 
-		http.Handle("POST /api/bookmarks", AuthRequired(db, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.Method != http.MethodPost {
-				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-				return
-			}
-			var bookmark store.Bookmark
-			if err := json.NewDecoder(r.Body).Decode(&bookmark); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
-			id, err := store.InsertBookmark(db, bookmark)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			w.WriteHeader(http.StatusCreated)
-			w.Write(fmt.Appendf([]byte{}, `{"id": %d}`, id))
-		})))
-
-		http.Handle("PATCH /api/bookmarks", AuthRequired(db, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			var submittedBookmark store.Bookmark
-			if err := json.NewDecoder(r.Body).Decode(&submittedBookmark); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
+		provider := embeddings.FromEnv()
 
-			originalBookmarkUrl := r.URL.Query().Get("url")
+		mux := http.NewServeMux()
+		routes(mux, db, keys, searchOpts, provider)
 
-			var originalBookmark store.Bookmark
-			originalBookmark.Url = originalBookmarkUrl
+		log.Fatal(listenAndServe(settings, cors(settings.AllowedOrigins, mux)))
+	},
+}
 
-			if err := store.UpdateBookmark(db, originalBookmark, submittedBookmark); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
+// routes registers the api and the web interface.
+func routes(mux router, db *store.DB, keys *store.KeyStore, searchOpts store.SearchOptions, provider embeddings.Provider) {
+	handleBoth(mux, "POST /api/tags/suggest", AuthRequired(keys, store.ScopeRead, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req api.TagSuggestionRequest
+		if err := decodeStrict(r.Body, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		in := tagger.Input(req)
+		link, err := url.Parse(in.Url)
+		if err != nil || in.Url == "" {
+			writeError(w, http.StatusBadRequest, "Invalid url")
+			return
+		}
+
+		// clients that only know the url get the page's metadata as well, as
+		// long as their key could save the page anyway
+		fetch := r.URL.Query().Get("fetch") != "false" && requestKey(r).Allows(store.ScopeWrite)
+		if in.Title == "" && in.Description == "" && fetch {
+			if page, err := clientFetcher.Fetch(link); err == nil {
+				in.Title, in.Description = page.Title, page.Description
+				in.Keywords = append(in.Keywords, page.Keywords...)
 			}
-			w.WriteHeader(http.StatusNoContent)
-		})))
+		}
+
+		vocabulary, err := store.TagNames(db)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		tags, err := tagger.FromEnv().Suggest(in, vocabulary)
+		if err != nil {
+			fmt.Println("unable to ask the model for tags:", err.Error())
+		}
+		if tags == nil {
+			tags = []string{}
+		}
+		writeJSON(w, http.StatusOK, api.TagSuggestions{Tags: tags})
+	})))
+
+	handleBoth(mux, "GET /api/collections", AuthRequired(keys, store.ScopeRead, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		searches, err := store.ListSavedSearches(db)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		response := []api.SavedSearch{}
+		for _, saved := range searches {
+			response = append(response, api.SavedSearch(saved))
+		}
+		writeJSON(w, http.StatusOK, response)
+	})))
+
+	mux.Handle("POST /api/bookmarks", AuthRequired(keys, store.ScopeWrite, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		var submitted api.NewBookmark
+		if err := decodeStrict(r.Body, &submitted); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := submitted.Validate(); err != nil {
+			writeInvalid(w, err)
+			return
+		}
+
+		id, enrich, status, err := createBookmark(db, submitted.Bookmark(), r)
+		if err != nil {
+			writeError(w, status, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]any{"id": id, "enriching": enrich})
+	})))
 
-		http.Handle("GET /api/bookmarks", AuthRequired(db, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			url := r.URL.Query().Get("url")
+	// the url, title, description and tags are replaced with the submitted
+	// ones, the metadata from the page is kept
+	mux.Handle("PATCH /api/bookmarks", AuthRequired(keys, store.ScopeWrite, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var submitted api.NewBookmark
+		if err := decodeStrict(r.Body, &submitted); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := submitted.Validate(); err != nil {
+			writeInvalid(w, err)
+			return
+		}
 
-			bookmarks, err := store.GetBookmark(db, url)
+		originalBookmark, err := store.GetBookmark(db, r.URL.Query().Get("url"))
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "Bookmark not found")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		_, err = store.ModifyBookmark(db, originalBookmark.Id, func(bm *store.Bookmark) error {
+			bm.Url = submitted.Url
+			bm.Title = submitted.Title
+			bm.Description = submitted.Description
+			bm.Tags = submitted.Tags
+			return nil
+		})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	// with a url this looks up the one bookmark saved under it, otherwise
+	// it lists a page of bookmarks
+	mux.Handle("GET /api/bookmarks", AuthRequired(keys, store.ScopeRead, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("url") {
+			bookmark, err := store.GetBookmark(db, r.URL.Query().Get("url"))
 			if err == sql.ErrNoRows {
-				http.Error(w, "Bookmark not found", http.StatusNotFound)
+				writeError(w, http.StatusNotFound, "Bookmark not found")
 				return
 			}
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				writeError(w, http.StatusInternalServerError, err.Error())
 				return
 			}
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(bookmarks)
-		})))
+			writeJSON(w, http.StatusOK, api.FromBookmark(bookmark))
+			return
+		}
 
-		log.Fatal(http.ListenAndServe(":1990", nil))
-	},
+		opts, err := listOptions(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		bookmarks, next, err := store.ListPage(db, opts)
+		if errors.Is(err, store.ErrInvalidCursor) || errors.Is(err, store.ErrInvalidSort) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, api.BookmarkPage{Bookmarks: api.FromBookmarks(bookmarks), NextCursor: next})
+	})))
+
+	handleBoth(mux, "DELETE /api/bookmarks/{id}", AuthRequired(keys, store.ScopeWrite, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		err = store.DeleteBookmark(db, id)
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "Bookmark not found")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	handleBoth(mux, "GET /api/tags", AuthRequired(keys, store.ScopeRead, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tags, err := store.ListTags(db)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		response := []api.Tag{}
+		for _, tag := range tags {
+			response = append(response, api.Tag(tag))
+		}
+		writeJSON(w, http.StatusOK, response)
+	})))
+
+	handleBoth(mux, "GET /api/bookmarks/{id}/archive", AuthRequired(keys, store.ScopeRead, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		archive, err := store.GetArchive(db, id)
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "Archive not found")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if archive.Format == metadata.FormatText {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		} else {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		}
+		// archives are someone else's page served from the same origin as the
+		// web ui, sandboxed they can not run scripts or reach its storage
+		w.Header().Set("Content-Security-Policy", "sandbox")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Last-Modified", archive.ArchivedAt.UTC().Format(http.TimeFormat))
+		w.Write(archive.Content)
+	})))
+
+	handleBoth(mux, "POST /api/bookmarks/{id}/archive", AuthRequired(keys, store.ScopeWrite, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathId(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = metadata.FormatHTML
+		}
+
+		bookmark, err := store.GetBookmarkById(db, id)
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "Bookmark not found")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		link, err := url.Parse(bookmark.Url)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		page, err := metadata.DefaultFetcher.Page(link)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		if err := saveArchive(db, metadata.DefaultFetcher, page, bookmark.Id, format); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	handleV1(mux, db, keys, searchOpts, provider)
+
+	mux.Handle("GET /api/openapi.json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, api.Spec())
+	}))
+	mux.Handle("GET /api/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "Not found")
+	}))
+
+	// everything else is the web interface
+	mux.Handle("GET /", web.Handler())
+}
+
+// serverSettings are the config file's settings with any flags that were set
+// on top.
+func serverSettings(cmd *cobra.Command, settings config.Server) config.Server {
+	flags := cmd.Flags()
+	if flags.Changed("addr") {
+		settings.Addr = serverAddr
+	}
+	if flags.Changed("port") {
+		settings.Port = serverPort
+	}
+	if flags.Changed("tls-cert") {
+		settings.TLSCert = serverTLSCert
+	}
+	if flags.Changed("tls-key") {
+		settings.TLSKey = serverTLSKey
+	}
+	if flags.Changed("socket") {
+		settings.Socket = serverSocket
+	}
+	if flags.Changed("allow-origin") {
+		settings.AllowedOrigins = serverAllowedOrigins
+	}
+	return settings
+}
+
+func listenAndServe(settings config.Server, handler http.Handler) error {
+	listener, err := listen(settings)
+	if err != nil {
+		return err
+	}
+	return serveOn(listener, settings, handler)
+}
+
+// listen opens the unix socket of the settings, or the address and port.
+func listen(settings config.Server) (net.Listener, error) {
+	if settings.Socket == "" {
+		return net.Listen("tcp", net.JoinHostPort(settings.Addr, strconv.Itoa(settings.Port)))
+	}
+
+	// a socket left behind by a previous run would fail the listen
+	if err := os.Remove(settings.Socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", settings.Socket)
+	if err != nil {
+		return nil, err
+	}
+	// only the user running the server gets to talk to it
+	if err := os.Chmod(settings.Socket, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// serveOn answers requests on the listener, over https when the settings have a
+// certificate.
+func serveOn(listener net.Listener, settings config.Server, handler http.Handler) error {
+	server := &http.Server{Handler: handler}
+	if settings.TLSCert != "" {
+		fmt.Println("listening on https://" + listener.Addr().String())
+		return server.ServeTLS(listener, settings.TLSCert, settings.TLSKey)
+	}
+	fmt.Println("listening on", listener.Addr().Network(), listener.Addr().String())
+	return server.Serve(listener)
 }
 
-func AuthRequired(db *store.DB, next http.Handler) http.Handler {
+func needsEnrichment(bm store.Bookmark) bool {
+	return bm.Title == "" || bm.Description == "" || bm.Canonical == ""
+}
+
+// enrichInBackground fetches the page after the bookmark has been saved and
+// updates it in place, so clients are not kept waiting on slow sites.
+func enrichInBackground(db *store.DB, id store.BookmarkId, link *url.URL, keepOriginal bool) {
+	page, err := metadata.Fetch(link)
+	if err != nil {
+		fmt.Println("unable to fetch page:", link.String(), err.Error())
+		return
+	}
+
+	// the rules and duplicates are looked up before the bookmark is modified,
+	// the transaction holds the write lock until it is done
+	rs := loadRules(db)
+	duplicate := false
+	if resolved := page.Resolved(); resolved != "" && resolved != link.String() && !keepOriginal {
+		existing, found, err := store.FindOtherDuplicate(db, id, resolved)
+		if err != nil {
+			fmt.Println("unable to enrich bookmark:", id, err.Error())
+			return
+		}
+		if found {
+			fmt.Println("resolved url already saved, keeping submitted url:", existing.Url)
+			duplicate = true
+		}
+	}
+
+	// the bookmark may have been edited while the page was loading, so it is
+	// read again by its id
+	_, err = store.ModifyBookmark(db, id, func(bm *store.Bookmark) error {
+		// a url edited in the meantime is kept
+		enrichBookmark(bm, page, keepOriginal || duplicate || bm.Url != link.String())
+		// the title and content type are only known now
+		bm.Tags = rs.Apply(bm.Tags, rulesPage(*bm, page.ContentType))
+		return nil
+	})
+	if err != nil {
+		fmt.Println("unable to enrich bookmark:", id, err.Error())
+	}
+}
+
+// AuthRequired only lets requests with a key allowing the scope through.
+func AuthRequired(keys *store.KeyStore, scope string, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 		bearer := r.Header.Get("Authorization")
 		if bearer == "" {
-			http.Error(w, "Missing Authorization header", http.StatusUnauthorized)
+			writeError(w, http.StatusUnauthorized, "Missing Authorization header")
 			return
 		}
 
 		token := strings.TrimPrefix(bearer, "Bearer ")
 
 		if token == "" {
-			http.Error(w, "Invalid Authorization header", http.StatusUnauthorized)
+			writeError(w, http.StatusUnauthorized, "Invalid Authorization header")
 			return
 		}
 
-		exists, err := store.HasKey(db, token)
+		key, err := store.Authenticate(keys, token)
+		if errors.Is(err, store.ErrInvalidKey) || errors.Is(err, store.ErrKeyExpired) || errors.Is(err, store.ErrKeyDevice) {
+			fmt.Println("Unauthorized:", err.Error(), r.Method, r.URL.Path)
+			writeError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
 		if err != nil {
 			fmt.Println(err)
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			writeError(w, http.StatusInternalServerError, "Unable to check key")
 			return
 		}
 
-		if exists {
-			next.ServeHTTP(w, r)
-		} else {
-			fmt.Println("Unauthorized, token not found: ", token[:10])
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		if !key.Allows(scope) {
+			fmt.Printf("Forbidden: %s key %d (%s) used for %s %s\n", key.Scope, key.Id, key.Label, r.Method, r.URL.Path)
+			writeError(w, http.StatusForbidden, store.ErrKeyScope.Error())
+			return
 		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), keyContext{}, key)))
 	})
 }
 
+type keyContext struct{}
+
+// requestKey is the key the request was authenticated with by AuthRequired.
+func requestKey(r *http.Request) store.ApiKey {
+	key, _ := r.Context().Value(keyContext{}).(store.ApiKey)
+	return key
+}
+
 func init() {
 	rootCmd.AddCommand(serverCmd)
 
@@ -149,4 +524,10 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// serverCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	serverCmd.Flags().StringVar(&serverAddr, "addr", config.DefaultAddr, "Address to listen on, 0.0.0.0 exposes the server to the network")
+	serverCmd.Flags().IntVar(&serverPort, "port", config.DefaultPort, "Port to listen on")
+	serverCmd.Flags().StringVar(&serverTLSCert, "tls-cert", "", "Certificate file for serving https (needs --tls-key)")
+	serverCmd.Flags().StringVar(&serverTLSKey, "tls-key", "", "Private key file for serving https (needs --tls-cert)")
+	serverCmd.Flags().StringSliceVar(&serverAllowedOrigins, "allow-origin", config.DefaultAllowedOrigins, "Origins browsers may call the api from, e.g. chrome-extension://<id>,http://localhost:3000")
+	serverCmd.Flags().StringVar(&serverSocket, "socket", "", "Listen on a unix socket at this path instead of an address and port")
 }
@@ -0,0 +1,517 @@
+package cmd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lukasmwerner/mark/config"
+	"github.com/lukasmwerner/mark/metadata"
+	"github.com/lukasmwerner/mark/rules"
+	"github.com/lukasmwerner/mark/store"
+)
+
+func TestLegacyPatchKeepsMetadata(t *testing.T) {
+	db, mux, secret := testServer(t)
+	id, err := store.InsertBookmark(db, store.Bookmark{
+		Url: "https://example.com/a", Title: "A", Tags: []string{"old"},
+		Canonical: "https://example.com/a", SiteName: "Example", Author: "Someone",
+		Published: "2024-01-02", Favicon: "https://example.com/favicon.ico", OriginalUrl: "http://example.com/a",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(mux, secret, "PATCH", "/api/bookmarks?url="+url.QueryEscape("https://example.com/a"), map[string]any{
+		"url": "https://example.com/b", "title": "B", "description": "Changed", "tags": []string{"new"},
+	})
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("PATCH answered %d %s", w.Code, w.Body)
+	}
+
+	bm, err := store.GetBookmarkById(db, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bm.Url != "https://example.com/b" || bm.Title != "B" || bm.Description != "Changed" || len(bm.Tags) != 1 || bm.Tags[0] != "new" {
+		t.Errorf("the submitted fields were not saved: %+v", bm)
+	}
+	if bm.Canonical != "https://example.com/a" || bm.SiteName != "Example" || bm.Author != "Someone" ||
+		bm.Published != "2024-01-02" || bm.Favicon != "https://example.com/favicon.ico" || bm.OriginalUrl != "http://example.com/a" {
+		t.Errorf("the page metadata was lost: %+v", bm)
+	}
+
+	w = serve(mux, secret, "PATCH", "/api/bookmarks?url="+url.QueryEscape("https://example.com/missing"), map[string]any{"url": "https://example.com/c", "title": "C"})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("PATCH of a missing bookmark answered %d", w.Code)
+	}
+}
+
+func TestLegacyPatchByIdOnlyChangesPatchFields(t *testing.T) {
+	db, mux, secret := testServer(t)
+	id, err := store.InsertBookmark(db, store.Bookmark{Url: "https://example.com/a", Title: "A", Canonical: "https://example.com/a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := "/api/bookmarks/" + strconv.FormatInt(int64(id), 10)
+
+	for _, patch := range []map[string]any{{"Id": 99}, {"canonical": "https://evil.example"}, {"OriginalUrl": "https://evil.example"}} {
+		if w := serve(mux, secret, "PATCH", path, patch); w.Code != http.StatusBadRequest {
+			t.Errorf("PATCH %v answered %d %s", patch, w.Code, w.Body)
+		}
+	}
+
+	w := serve(mux, secret, "PATCH", path, map[string]any{"title": "B", "tags": []string{"new"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("PATCH answered %d %s", w.Code, w.Body)
+	}
+	bm, err := store.GetBookmarkById(db, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bm.Title != "B" || bm.Canonical != "https://example.com/a" || len(bm.Tags) != 1 {
+		t.Errorf("PATCH saved %+v", bm)
+	}
+}
+
+func TestEnrichInBackgroundUpdatesById(t *testing.T) {
+	db := openTestDB(t)
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><title>Fetched</title><meta name="description" content="From the page"></head></html>`)
+	}))
+	defer page.Close()
+	link, _ := url.Parse(page.URL + "/a")
+
+	// two bookmarks share the url, only the one that was created is enriched
+	other, err := store.InsertBookmark(db, store.Bookmark{Url: link.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := store.InsertBookmark(db, store.Bookmark{Url: link.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enrichInBackground(db, id, link, false)
+
+	bm, err := store.GetBookmarkById(db, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bm.Title != "Fetched" || bm.Description != "From the page" {
+		t.Errorf("the bookmark was not enriched: %+v", bm)
+	}
+	if bm, _ := store.GetBookmarkById(db, other); bm.Title != "" {
+		t.Errorf("another bookmark with the same url was changed: %+v", bm)
+	}
+
+	// the url was edited while the page was loading
+	edited, err := store.InsertBookmark(db, store.Bookmark{Url: link.String() + "?edited"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enrichInBackground(db, edited, link, false)
+	bm, err = store.GetBookmarkById(db, edited)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bm.Title != "Fetched" || bm.Url != link.String()+"?edited" {
+		t.Errorf("the edited bookmark was not enriched in place: %+v", bm)
+	}
+}
+
+func TestEnrichInBackgroundKeepsDuplicatesApart(t *testing.T) {
+	db := openTestDB(t)
+	t.Setenv("MARK_RULES", "")
+	err := os.WriteFile(filepath.Join(db.StoreLoc, rules.FileName), []byte(`[
+		{"content_type": "text/html", "tags": ["web"]},
+		{"url": "example.com/fresh", "tags": ["fresh"]}
+	]`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><head><title>Fetched</title><link rel="canonical" href="https://example.com%s"></head></html>`, r.URL.Path)
+	}))
+	defer page.Close()
+
+	if _, err := store.InsertBookmark(db, store.Bookmark{Url: "https://example.com/saved", Title: "Saved"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		path, url string
+		tags      []string
+	}{
+		// the canonical url is already saved, the submitted one is kept
+		{"/saved", page.URL + "/saved", []string{"web"}},
+		{"/fresh", "https://example.com/fresh", []string{"web", "fresh"}},
+	} {
+		link, _ := url.Parse(page.URL + test.path)
+		id, err := store.InsertBookmark(db, store.Bookmark{Url: link.String()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		enrichInBackground(db, id, link, false)
+
+		bm, err := store.GetBookmarkById(db, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bm.Title != "Fetched" || bm.Url != test.url || !slices.Equal(bm.Tags, test.tags) {
+			t.Errorf("%s was enriched to %s titled %q tagged %v, want %s tagged %v", test.path, bm.Url, bm.Title, bm.Tags, test.url, test.tags)
+		}
+	}
+}
+
+func TestArchiveIsSandboxed(t *testing.T) {
+	db, mux, secret := testServer(t)
+	id, err := store.InsertBookmark(db, store.Bookmark{Url: "https://example.com", Title: "Example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.SaveArchive(db, store.Archive{BookmarkId: id, Format: metadata.FormatHTML, Content: []byte("<p>archived</p>"), ArchivedAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, prefix := range []string{"/api", "/api/v1"} {
+		w := serve(mux, secret, "GET", fmt.Sprintf("%s/bookmarks/%d/archive", prefix, id), nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s answered %d %s", prefix, w.Code, w.Body)
+		}
+		if csp := w.Header().Get("Content-Security-Policy"); csp != "sandbox" {
+			t.Errorf("%s: Content-Security-Policy %q, want sandbox", prefix, csp)
+		}
+		if nosniff := w.Header().Get("X-Content-Type-Options"); nosniff != "nosniff" {
+			t.Errorf("%s: X-Content-Type-Options %q, want nosniff", prefix, nosniff)
+		}
+	}
+}
+
+func TestLegacyBookmarkRoutesUseApiSchema(t *testing.T) {
+	db, mux, secret := testServer(t)
+
+	for _, body := range []map[string]any{
+		{"url": "javascript:alert(1)"},
+		{"url": "example.com"},
+		{"url": "https://example.com/a", "summary": "Written by the client"},
+		{"url": "https://example.com/a", "original_url": "https://evil.example"},
+		{"url": "https://example.com/a", "created_at": "2001-01-01T00:00:00Z"},
+	} {
+		if w := serve(mux, secret, "POST", "/api/bookmarks", body); w.Code != http.StatusBadRequest {
+			t.Errorf("POST %v answered %d %s", body, w.Code, w.Body)
+		}
+		bulk := map[string]any{"operations": []any{map[string]any{"action": "create", "bookmark": body}}}
+		if w := serve(mux, secret, "POST", "/api/bookmarks/bulk", bulk); w.Code != http.StatusBadRequest {
+			t.Errorf("bulk create of %v answered %d %s", body, w.Code, w.Body)
+		}
+	}
+	if n, err := store.ListBookmarks(db, store.ListOptions{}); err != nil || len(n) != 0 {
+		t.Fatalf("rejected bookmarks were saved: %v %v", n, err)
+	}
+
+	w := serve(mux, secret, "POST", "/api/bookmarks", map[string]any{"url": "https://example.com/a", "title": "A", "tags": []string{"go"}})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST answered %d %s", w.Code, w.Body)
+	}
+	var created struct {
+		Id int64 `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	path := fmt.Sprintf("/api/bookmarks/%d", created.Id)
+
+	// what is read can be sent back as a patch
+	read := map[string]any{}
+	w = serve(mux, secret, "GET", path, nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &read); err != nil {
+		t.Fatalf("GET answered %d %s", w.Code, w.Body)
+	}
+	patch := map[string]any{"url": read["url"], "title": fmt.Sprint(read["title"], " edited"), "description": read["description"], "tags": read["tags"]}
+	w = serve(mux, secret, "PATCH", path, patch)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PATCH with the fields that were read answered %d %s", w.Code, w.Body)
+	}
+	patched := map[string]any{}
+	json.Unmarshal(w.Body.Bytes(), &patched)
+	if patched["title"] != "A edited" {
+		t.Errorf("PATCH answered %s", w.Body)
+	}
+
+	for _, get := range []string{path, "/api/bookmarks?url=" + url.QueryEscape("https://example.com/a")} {
+		w := serve(mux, secret, "GET", get, nil)
+		bookmark := map[string]any{}
+		json.Unmarshal(w.Body.Bytes(), &bookmark)
+		if _, ok := bookmark["url"]; !ok || bookmark["Url"] != nil {
+			t.Errorf("GET %s answered %s", get, w.Body)
+		}
+	}
+	var page struct {
+		Bookmarks []map[string]any `json:"bookmarks"`
+	}
+	w = serve(mux, secret, "GET", "/api/bookmarks", nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil || len(page.Bookmarks) != 1 || page.Bookmarks[0]["title"] != "A edited" {
+		t.Errorf("GET /api/bookmarks answered %s", w.Body)
+	}
+}
+
+// walkPages lists every page of the listing, the titles are returned in the
+// order they were listed along with the number of pages.
+func walkPages(t *testing.T, mux http.Handler, secret, path string) ([]string, int) {
+	t.Helper()
+	titles, pages := []string{}, 0
+	cursor := ""
+	for {
+		w := serve(mux, secret, "GET", path+"&cursor="+url.QueryEscape(cursor), nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s answered %d %s", path, w.Code, w.Body)
+		}
+		var page struct {
+			Bookmarks []struct {
+				Title string `json:"title"`
+			} `json:"bookmarks"`
+			NextCursor *string `json:"next_cursor"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		for _, bm := range page.Bookmarks {
+			titles = append(titles, bm.Title)
+		}
+		if page.NextCursor == nil {
+			return titles, pages
+		}
+		if pages > 10 {
+			t.Fatalf("GET %s did not stop paging", path)
+		}
+		cursor = *page.NextCursor
+	}
+}
+
+func TestListBookmarkPages(t *testing.T) {
+	db, mux, secret := testServer(t)
+	for _, bm := range []store.Bookmark{
+		{Url: "https://example.com/1", Title: "Bravo", Tags: []string{"go"}},
+		{Url: "https://example.com/2", Title: "Alpha"},
+		{Url: "https://example.com/3", Title: "Charlie", Tags: []string{"go", "web"}},
+		{Url: "https://example.com/4", Title: "Alpha", Tags: []string{"golang"}},
+		{Url: "https://example.com/5", Title: "Echo", Tags: []string{"web", "go"}},
+	} {
+		if _, err := store.InsertBookmark(db, bm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query  string
+		titles []string
+		pages  int
+	}{
+		{"limit=2", []string{"Bravo", "Alpha", "Charlie", "Alpha", "Echo"}, 3},
+		{"limit=2&sort=title", []string{"Alpha", "Alpha", "Bravo", "Charlie", "Echo"}, 3},
+		{"limit=3&sort=-title", []string{"Echo", "Charlie", "Bravo", "Alpha", "Alpha"}, 2},
+		{"limit=1&sort=-id&tag=go", []string{"Echo", "Charlie", "Bravo"}, 3},
+		{"limit=5", []string{"Bravo", "Alpha", "Charlie", "Alpha", "Echo"}, 1},
+	}
+	for _, prefix := range []string{"/api", "/api/v1"} {
+		for _, test := range tests {
+			titles, pages := walkPages(t, mux, secret, prefix+"/bookmarks?"+test.query)
+			if strings.Join(titles, ",") != strings.Join(test.titles, ",") || pages != test.pages {
+				t.Errorf("%s?%s listed %v in %d pages, want %v in %d", prefix, test.query, titles, pages, test.titles, test.pages)
+			}
+		}
+
+		for _, query := range []string{"cursor=nonsense", "cursor=eyJ2IjoxfQ", "cursor=W10", "sort=rating", "sort=-", "limit=0", "limit=-1", "limit=1001", "limit=ten"} {
+			if w := serve(mux, secret, "GET", prefix+"/bookmarks?"+query, nil); w.Code != http.StatusBadRequest {
+				t.Errorf("%s?%s answered %d %s", prefix, query, w.Code, w.Body)
+			}
+		}
+		if w := serve(mux, secret, "GET", prefix+"/bookmarks?limit=1000", nil); w.Code != http.StatusOK {
+			t.Errorf("%s?limit=1000 answered %d %s", prefix, w.Code, w.Body)
+		}
+	}
+}
+
+// startServer serves handler on the listener until the test is done.
+func startServer(t *testing.T, listener net.Listener, settings config.Server, handler http.Handler) {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() { done <- serveOn(listener, settings, handler) }()
+	t.Cleanup(func() {
+		listener.Close()
+		if err := <-done; !errors.Is(err, net.ErrClosed) {
+			t.Errorf("serving stopped with %v", err)
+		}
+	})
+}
+
+var hello = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, "hello")
+})
+
+func get(t *testing.T, client *http.Client, u string) string {
+	t.Helper()
+	res, err := client.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestListenOnAddress(t *testing.T) {
+	settings := config.Default().Server
+	if settings.Addr != "127.0.0.1" || settings.Port != 1990 {
+		t.Errorf("listening on %s:%d by default", settings.Addr, settings.Port)
+	}
+
+	// any free port, 1990 may be taken on the machine running the tests
+	settings.Port = 0
+	listener, err := listen(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().(*net.TCPAddr)
+	if !addr.IP.IsLoopback() || addr.Port == 0 {
+		t.Errorf("listening on %s", addr)
+	}
+	startServer(t, listener, settings, hello)
+
+	if body := get(t, http.DefaultClient, "http://"+addr.String()); body != "hello" {
+		t.Errorf("answered %q", body)
+	}
+}
+
+func TestListenOnUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "mark.sock")
+	// left behind by a server that did not shut down cleanly
+	if err := os.WriteFile(socket, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := config.Server{Addr: "127.0.0.1", Port: 1990, Socket: socket}
+	listener, err := listen(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listener.Addr().Network() != "unix" {
+		t.Errorf("listening on %s %s", listener.Addr().Network(), listener.Addr())
+	}
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0o600 {
+		t.Errorf("the socket is %s", info.Mode())
+	}
+	startServer(t, listener, settings, hello)
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	if body := get(t, client, "http://mark/"); body != "hello" {
+		t.Errorf("answered %q", body)
+	}
+
+	if _, err := listen(config.Server{Socket: filepath.Join(t.TempDir(), "missing", "mark.sock")}); err == nil {
+		t.Error("listened in a directory that does not exist")
+	}
+}
+
+func TestServeTLS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "mark test"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	settings := config.Server{Addr: "127.0.0.1", TLSCert: filepath.Join(dir, "cert.pem"), TLSKey: filepath.Join(dir, "key.pem")}
+	if err := os.WriteFile(settings.TLSCert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(settings.TLSKey, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := listen(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	startServer(t, listener, settings, hello)
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	if body := get(t, client, "https://"+listener.Addr().String()); body != "hello" {
+		t.Errorf("answered %q", body)
+	}
+
+	// plain http is not answered
+	res, err := http.Get("http://" + listener.Addr().String())
+	if err == nil {
+		res.Body.Close()
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("plain http answered %s", res.Status)
+		}
+	}
+
+	// a missing key fails instead of serving plain http
+	settings.TLSKey = filepath.Join(dir, "missing.pem")
+	other, err := listen(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if err := serveOn(other, settings, hello); err == nil || errors.Is(err, net.ErrClosed) {
+		t.Errorf("serving without the key: %v", err)
+	}
+}
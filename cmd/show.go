@@ -33,7 +33,7 @@ var showCmd = &cobra.Command{
 
 		searchQuery := strings.Join(args, " ")
 
-		bookmarks, err := store.SearchBookmarks(db, searchQuery)
+		bookmarks, err := searchBookmarks(db, searchQuery)
 		if err != nil {
 			fmt.Println("unable to search bookmarks", err.Error())
 			return
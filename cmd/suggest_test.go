@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lukasmwerner/mark/store"
+)
+
+func TestSuggestTagsFetch(t *testing.T) {
+	t.Setenv("MARK_TAG_MODEL", "none")
+	db, mux, writeKey := testServer(t)
+	readKey := newKey(t, db, store.ScopeRead)
+
+	var requests atomic.Int32
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		fmt.Fprint(w, `<title>Fuzzing in Go</title><meta name="keywords" content="fuzzing">`)
+	}))
+	defer page.Close()
+
+	suggest := func(secret string) []string {
+		t.Helper()
+		w := serve(mux, secret, "POST", "/api/v1/tags/suggest", map[string]any{"url": page.URL})
+		if w.Code != http.StatusOK {
+			t.Fatalf("suggest answered %d %s", w.Code, w.Body)
+		}
+		var suggestions struct {
+			Tags []string `json:"tags"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &suggestions); err != nil {
+			t.Fatal(err)
+		}
+		return suggestions.Tags
+	}
+
+	// the server does not fetch from its own network
+	suggest(writeKey)
+	if n := requests.Load(); n != 0 {
+		t.Errorf("a loopback url was fetched %d times", n)
+	}
+
+	allowLocalFetches(t)
+	if tags := suggest(readKey); slices.Contains(tags, "fuzzing") || requests.Load() != 0 {
+		t.Errorf("the page was fetched for a read key, suggested %v", tags)
+	}
+	if tags := suggest(writeKey); !slices.Contains(tags, "fuzzing") || requests.Load() != 1 {
+		t.Errorf("the page was not fetched for a write key, suggested %v", tags)
+	}
+}
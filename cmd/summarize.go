@@ -0,0 +1,115 @@
+/*
+Copyright © 2025 Lukas Werner <me@lukaswerner.com>
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/lukasmwerner/mark/metadata"
+	"github.com/lukasmwerner/mark/store"
+	"github.com/lukasmwerner/mark/summarizer"
+	"github.com/spf13/cobra"
+)
+
+var summarizeAllMissing bool
+
+// summarizeCmd represents the summarize command
+var summarizeCmd = &cobra.Command{
+	Use:   "summarize [search query]",
+	Short: "Writes short summaries of the bookmarked pages with a local LLM",
+	Long: `Summarizes the bookmarks matching the search query, or with --all-missing every
+bookmark that does not have a summary yet. Summaries are kept separate from
+descriptions, a description is never replaced.
+
+The indexed page text is used (see mark reindex), pages that have not been
+indexed are fetched and indexed first.
+
+Summaries are written by ollama, set OLLAMA_HOST to use a different server and
+MARK_SUMMARY_MODEL to use a different model than gemma:7b.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 && !summarizeAllMissing {
+			return errors.New("requires a search query or --all-missing")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		db, err := store.Open()
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		defer db.Close()
+
+		var bookmarks []store.Bookmark
+		searchQuery := strings.Join(args, " ")
+		if searchQuery == "" {
+			bookmarks, err = store.ListBookmarks(db, store.ListOptions{})
+		} else {
+			bookmarks, err = searchBookmarks(db, searchQuery)
+		}
+		if err != nil {
+			fmt.Println("unable to list bookmarks", err.Error())
+			return
+		}
+
+		provider := summarizer.FromEnv()
+		summarized := 0
+		for _, bookmark := range bookmarks {
+			if summarizeAllMissing && bookmark.Summary != "" {
+				continue
+			}
+
+			fmt.Println("summarizing", bookmark.Url)
+			text, err := pageText(db, bookmark)
+			if err != nil {
+				fmt.Println("\tunable to get page text", err.Error())
+				continue
+			}
+			summary, err := provider.Summarize(summarizer.Input{Url: bookmark.Url, Title: bookmark.Title, Text: text})
+			if err != nil {
+				fmt.Println("\tunable to summarize", err.Error())
+				continue
+			}
+			if err := store.SaveSummary(db, bookmark.Id, summary); err != nil {
+				fmt.Println("\tunable to save summary", err.Error())
+				continue
+			}
+			fmt.Println("\t" + summary)
+			summarized++
+		}
+		fmt.Printf("summarized %d bookmarks\n", summarized)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(summarizeCmd)
+
+	summarizeCmd.Flags().BoolVar(&summarizeAllMissing, "all-missing", false, "Only summarize bookmarks without a summary, all of them when no query is given")
+}
+
+// pageText is the indexed text of the page, the page is fetched and indexed
+// when it has not been yet.
+func pageText(db *store.DB, bookmark store.Bookmark) (string, error) {
+	text, err := store.GetContent(db, bookmark.Id)
+	if err != nil || text != "" {
+		return text, err
+	}
+
+	link, err := url.Parse(bookmark.Url)
+	if err != nil {
+		return "", err
+	}
+	page, err := metadata.DefaultFetcher.Page(link)
+	if err != nil {
+		return "", err
+	}
+	if page.Doc == nil {
+		return "", fmt.Errorf("unable to read %s pages", page.ContentType)
+	}
+	text = metadata.ReadableText(page.Doc)
+	return text, store.SaveContent(db, bookmark.Id, text)
+}
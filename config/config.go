@@ -0,0 +1,100 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/lukasmwerner/mark/store"
+)
+
+// FileName is the config file in the store location.
+const FileName = "config.json"
+
+const (
+	DefaultAddr = "127.0.0.1"
+	DefaultPort = 1990
+)
+
+// Config is read from config.json, anything left out keeps its default.
+type Config struct {
+	Server Server `json:"server"`
+}
+
+// Server is how mark server listens.
+//
+//	{"server": {"addr": "0.0.0.0", "port": 8443, "tls_cert": "cert.pem", "tls_key": "key.pem"}}
+type Server struct {
+	Addr string `json:"addr"`
+	Port int    `json:"port"`
+	// TLSCert and TLSKey are both needed to serve https
+	TLSCert string `json:"tls_cert"`
+	TLSKey  string `json:"tls_key"`
+	// Socket listens on a unix socket instead of addr and port
+	Socket string `json:"socket"`
+	// AllowedOrigins may call the api from a browser, see mark server --help
+	AllowedOrigins []string `json:"allowed_origins"`
+}
+
+// DefaultAllowedOrigins are the browser extensions, which still need a key
+// for every request.
+var DefaultAllowedOrigins = []string{"chrome-extension://*", "moz-extension://*"}
+
+func Default() Config {
+	return Config{
+		Server: Server{Addr: DefaultAddr, Port: DefaultPort, AllowedOrigins: append([]string{}, DefaultAllowedOrigins...)},
+	}
+}
+
+// Path is MARK_CONFIG, or config.json in the store location.
+func Path() (string, error) {
+	if file := os.Getenv("MARK_CONFIG"); file != "" {
+		return file, nil
+	}
+	loc, err := store.Location()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(loc, FileName), nil
+}
+
+// Load reads the config file, the defaults are used when there is none.
+func Load() (Config, error) {
+	c := Default()
+	file, err := Path()
+	if err != nil {
+		return c, err
+	}
+	b, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, fmt.Errorf("unable to read %s: %w", file, err)
+	}
+	return c, nil
+}
+
+// URL is where a browser on this machine reaches the server. A server on a
+// unix socket has none.
+func (s Server) URL() (string, error) {
+	if s.Socket != "" {
+		return "", errors.New("the server listens on a unix socket, browsers can not reach it")
+	}
+	scheme := "http"
+	if s.TLSCert != "" {
+		scheme = "https"
+	}
+	host := s.Addr
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = DefaultAddr
+	}
+	return scheme + "://" + net.JoinHostPort(host, strconv.Itoa(s.Port)), nil
+}
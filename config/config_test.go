@@ -0,0 +1,101 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("MARK_STORE_LOCATION", dir)
+	t.Setenv("MARK_CONFIG", "")
+
+	file, err := Path()
+	if err != nil || file != filepath.Join(dir, FileName) {
+		t.Errorf("config file %q, %v", file, err)
+	}
+
+	// without a config file the server listens on 127.0.0.1:1990
+	c, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Server{Addr: "127.0.0.1", Port: 1990, AllowedOrigins: []string{"chrome-extension://*", "moz-extension://*"}}
+	if !reflect.DeepEqual(c.Server, want) {
+		t.Errorf("default server %+v, want %+v", c.Server, want)
+	}
+
+	// anything left out keeps its default
+	write := func(file, config string) {
+		t.Helper()
+		if err := os.WriteFile(file, []byte(config), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(file, `{"server": {"port": 8443, "tls_cert": "cert.pem", "tls_key": "key.pem"}}`)
+	c, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = Server{Addr: "127.0.0.1", Port: 8443, TLSCert: "cert.pem", TLSKey: "key.pem", AllowedOrigins: DefaultAllowedOrigins}
+	if !reflect.DeepEqual(c.Server, want) {
+		t.Errorf("server %+v, want %+v", c.Server, want)
+	}
+
+	// MARK_CONFIG takes precedence over the store location
+	other := filepath.Join(t.TempDir(), "mark.json")
+	t.Setenv("MARK_CONFIG", other)
+	if file, err := Path(); err != nil || file != other {
+		t.Errorf("config file %q with MARK_CONFIG, %v", file, err)
+	}
+	write(other, `{"server": {"socket": "/run/mark.sock", "allowed_origins": []}}`)
+	c, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = Server{Addr: "127.0.0.1", Port: 1990, Socket: "/run/mark.sock", AllowedOrigins: []string{}}
+	if !reflect.DeepEqual(c.Server, want) {
+		t.Errorf("server %+v, want %+v", c.Server, want)
+	}
+
+	for _, broken := range []string{`{"server": {"port": "8443"}}`, `{"server":`, `[]`} {
+		write(other, broken)
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), other) {
+			t.Errorf("loading %s: %v, want an error naming the file", broken, err)
+		}
+	}
+
+	// the defaults are not shared between configs
+	a, b := Default(), Default()
+	a.Server.AllowedOrigins[0] = "https://example.com"
+	if b.Server.AllowedOrigins[0] != "chrome-extension://*" || DefaultAllowedOrigins[0] != "chrome-extension://*" {
+		t.Error("changing the allowed origins of one config changed the defaults")
+	}
+}
+
+func TestServerURL(t *testing.T) {
+	tests := []struct {
+		server Server
+		want   string
+	}{
+		{Default().Server, "http://127.0.0.1:1990"},
+		{Server{Addr: "0.0.0.0", Port: 8080}, "http://127.0.0.1:8080"},
+		{Server{Addr: "::", Port: 8080}, "http://127.0.0.1:8080"},
+		{Server{Port: 8080}, "http://127.0.0.1:8080"},
+		{Server{Addr: "::1", Port: 8080}, "http://[::1]:8080"},
+		{Server{Addr: "mark.local", Port: 8443, TLSCert: "cert.pem", TLSKey: "key.pem"}, "https://mark.local:8443"},
+	}
+	for _, test := range tests {
+		u, err := test.server.URL()
+		if err != nil || u != test.want {
+			t.Errorf("URL of %+v = %q, %v, want %q", test.server, u, err, test.want)
+		}
+	}
+
+	if _, err := (Server{Socket: "/run/mark.sock", Port: 1990}).URL(); err == nil {
+		t.Error("a server on a unix socket has a url")
+	}
+}
@@ -0,0 +1,61 @@
+package embeddings
+
+import (
+	"math"
+	"os"
+
+	"github.com/lukasmwerner/mark/ollama"
+)
+
+// DefaultModel is a small embedding model that runs fine on a laptop.
+const DefaultModel = "nomic-embed-text"
+
+// Provider turns text into vectors. Vectors from different models are not
+// comparable, so they are stored along with the model's name.
+type Provider interface {
+	Model() string
+	Embed(texts []string) ([][]float32, error)
+}
+
+// Ollama computes embeddings through an ollama compatible api.
+type Ollama struct {
+	Client    *ollama.Client
+	ModelName string
+}
+
+func NewOllama(client *ollama.Client, model string) *Ollama {
+	return &Ollama{Client: client, ModelName: model}
+}
+
+// FromEnv is an ollama provider using OLLAMA_HOST and MARK_EMBED_MODEL.
+func FromEnv() Provider {
+	model := os.Getenv("MARK_EMBED_MODEL")
+	if model == "" {
+		model = DefaultModel
+	}
+	return NewOllama(ollama.FromEnv(), model)
+}
+
+func (o *Ollama) Model() string { return o.ModelName }
+
+func (o *Ollama) Embed(texts []string) ([][]float32, error) {
+	return o.Client.Embed(o.ModelName, texts)
+}
+
+// Cosine is the cosine similarity of two vectors, 0 when their lengths differ
+// or either of them is all zeros.
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,120 @@
+package embeddings
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lukasmwerner/mark/ollama"
+)
+
+// fakeOllama answers /api/embed with the handler, the inputs it was sent are
+// collected.
+func fakeOllama(t *testing.T, respond func(w http.ResponseWriter, input []string)) (*Ollama, *[][]string) {
+	t.Helper()
+	inputs := [][]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/embed" || r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Model != "test-embed" {
+			http.Error(w, `{"error": "bad request"}`, http.StatusBadRequest)
+			return
+		}
+		inputs = append(inputs, req.Input)
+		respond(w, req.Input)
+	}))
+	t.Cleanup(server.Close)
+	return NewOllama(ollama.NewClient(server.URL+"/"), "test-embed"), &inputs
+}
+
+func TestOllamaEmbed(t *testing.T) {
+	o, inputs := fakeOllama(t, func(w http.ResponseWriter, input []string) {
+		vectors := [][]float32{}
+		for i := range input {
+			vectors = append(vectors, []float32{float32(i), 0.5})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"model": "test-embed", "embeddings": vectors})
+	})
+
+	if o.Model() != "test-embed" {
+		t.Errorf("model %q", o.Model())
+	}
+	vectors, err := o.Embed([]string{"first", "second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 2 || vectors[0][0] != 0 || vectors[1][0] != 1 || vectors[1][1] != 0.5 {
+		t.Errorf("vectors %v", vectors)
+	}
+	if len(*inputs) != 1 || strings.Join((*inputs)[0], ",") != "first,second" {
+		t.Errorf("ollama was sent %v", *inputs)
+	}
+}
+
+func TestOllamaEmbedErrors(t *testing.T) {
+	missing, _ := fakeOllama(t, func(w http.ResponseWriter, input []string) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "model \"test-embed\" not found, try pulling it first"}`))
+	})
+	if _, err := missing.Embed([]string{"text"}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("error %v, want ollama's message", err)
+	}
+
+	broken, _ := fakeOllama(t, func(w http.ResponseWriter, input []string) {
+		http.Error(w, "upstream unavailable", http.StatusBadGateway)
+	})
+	if _, err := broken.Embed([]string{"text"}); err == nil || !strings.Contains(err.Error(), "502") {
+		t.Errorf("error %v, want the status", err)
+	}
+
+	short, _ := fakeOllama(t, func(w http.ResponseWriter, input []string) {
+		json.NewEncoder(w).Encode(map[string]any{"embeddings": [][]float32{{1, 0}}})
+	})
+	if _, err := short.Embed([]string{"one", "two"}); err == nil || !strings.Contains(err.Error(), "1 embeddings for 2 inputs") {
+		t.Errorf("error %v, want the count mismatch", err)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("OLLAMA_HOST", "gpu.local:11434")
+	t.Setenv("MARK_EMBED_MODEL", "")
+	provider := FromEnv().(*Ollama)
+	if provider.Model() != DefaultModel || provider.Client.Host != "http://gpu.local:11434" {
+		t.Errorf("provider for %s with %s", provider.Client.Host, provider.Model())
+	}
+
+	t.Setenv("MARK_EMBED_MODEL", "mxbai-embed-large")
+	if model := FromEnv().Model(); model != "mxbai-embed-large" {
+		t.Errorf("MARK_EMBED_MODEL was ignored, model %q", model)
+	}
+}
+
+func TestCosine(t *testing.T) {
+	tests := []struct {
+		a, b []float32
+		want float64
+	}{
+		{[]float32{1, 0}, []float32{1, 0}, 1},
+		{[]float32{1, 0}, []float32{3, 0}, 1},
+		{[]float32{1, 0}, []float32{0, 1}, 0},
+		{[]float32{1, 0}, []float32{-1, 0}, -1},
+		{[]float32{1, 1}, []float32{1, 0}, 1 / math.Sqrt2},
+		{[]float32{1, 0}, []float32{1, 0, 0}, 0},
+		{[]float32{0, 0}, []float32{1, 0}, 0},
+		{nil, nil, 0},
+	}
+	for _, test := range tests {
+		if got := Cosine(test.a, test.b); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("Cosine(%v, %v) = %f, want %f", test.a, test.b, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package linkcheck
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultConcurrency  = 8
+	DefaultHostInterval = 500 * time.Millisecond
+	DefaultTimeout      = 15 * time.Second
+)
+
+// Result is the outcome of probing a single url.
+type Result struct {
+	Url string
+	// Status is the final status code, 0 when the request failed
+	Status int
+	// Location is where the url ended up after following redirects
+	Location string
+	Err      error
+	Checked  time.Time
+}
+
+// Broken is true for links that could not be loaded.
+func (r Result) Broken() bool {
+	return r.Err != nil || r.Status >= 400
+}
+
+// Moved is true for links that redirect somewhere else.
+func (r Result) Moved() bool {
+	return !r.Broken() && r.Location != "" && r.Location != r.Url
+}
+
+// Checker probes urls with a bounded number of requests in flight and a
+// minimum delay between requests to the same host.
+type Checker struct {
+	Client       *http.Client
+	Concurrency  int
+	HostInterval time.Duration
+	UserAgent    string
+
+	mu       sync.Mutex
+	nextSlot map[string]time.Time
+}
+
+func NewChecker(concurrency int, hostInterval, timeout time.Duration, userAgent string) *Checker {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Checker{
+		Client:       &http.Client{Timeout: timeout},
+		Concurrency:  concurrency,
+		HostInterval: hostInterval,
+		UserAgent:    userAgent,
+		nextSlot:     map[string]time.Time{},
+	}
+}
+
+// Check probes every url, results are returned in the same order as urls.
+// progress (if set) is called as each result comes in.
+func (c *Checker) Check(urls []string, progress func(Result)) []Result {
+	results := make([]Result, len(urls))
+	sem := make(chan struct{}, c.Concurrency)
+	var wg sync.WaitGroup
+	var progressMu sync.Mutex
+
+	for i, u := range urls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, u string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			results[i] = c.probe(u, sem)
+			if progress != nil {
+				progressMu.Lock()
+				progress(results[i])
+				progressMu.Unlock()
+			}
+		}(i, u)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// probe requests the url while holding a slot of sem, which is given up while
+// waiting for the host.
+func (c *Checker) probe(rawUrl string, sem chan struct{}) Result {
+	result := Result{Url: rawUrl}
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		result.Err = err
+		result.Checked = time.Now()
+		return result
+	}
+
+	resp, err := c.request(http.MethodHead, u, sem)
+	// plenty of servers do not implement HEAD properly, so retry those with GET
+	if err != nil || resp.StatusCode == http.StatusMethodNotAllowed ||
+		resp.StatusCode == http.StatusNotImplemented || resp.StatusCode == http.StatusForbidden ||
+		resp.StatusCode == http.StatusNotFound {
+		resp, err = c.request(http.MethodGet, u, sem)
+	}
+	result.Checked = time.Now()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	result.Status = resp.StatusCode
+	result.Location = resp.Request.URL.String()
+	return result
+}
+
+func (c *Checker) request(method string, u *url.URL, sem chan struct{}) (*http.Response, error) {
+	c.wait(u.Host, sem)
+
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	// only the status matters, drain a little so the connection can be reused
+	io.CopyN(io.Discard, resp.Body, 64<<10)
+	resp.Body.Close()
+	return resp, nil
+}
+
+// wait blocks until the host can be requested again. The slot of sem is free
+// while sleeping so links to other hosts are not held up by a busy one.
+func (c *Checker) wait(host string, sem chan struct{}) {
+	c.mu.Lock()
+	if c.nextSlot == nil {
+		c.nextSlot = map[string]time.Time{}
+	}
+	now := time.Now()
+	slot := c.nextSlot[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	c.nextSlot[host] = slot.Add(c.HostInterval)
+	c.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return
+	}
+	<-sem
+	time.Sleep(delay)
+	sem <- struct{}{}
+}
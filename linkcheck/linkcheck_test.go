@@ -0,0 +1,78 @@
+package linkcheck
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCheckClassifiesLinks(t *testing.T) {
+	done := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.Handle("/old", http.RedirectHandler("/ok", http.StatusMovedPermanently))
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(5 * time.Second):
+		case <-done:
+		}
+	})
+	// /gone is a 404 from the mux, for HEAD and the GET retry
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	defer close(done)
+
+	checker := NewChecker(DefaultConcurrency, 0, 200*time.Millisecond, "")
+	results := checker.Check([]string{
+		server.URL + "/ok",
+		server.URL + "/old",
+		server.URL + "/gone",
+		server.URL + "/slow",
+	}, nil)
+
+	ok, old, gone, slow := results[0], results[1], results[2], results[3]
+	if ok.Broken() || ok.Moved() || ok.Status != http.StatusOK {
+		t.Errorf("/ok: status %d, broken %t, moved %t", ok.Status, ok.Broken(), ok.Moved())
+	}
+	if !old.Moved() || old.Location != server.URL+"/ok" {
+		t.Errorf("/old: moved %t to %q, want %s/ok", old.Moved(), old.Location, server.URL)
+	}
+	if !gone.Broken() || gone.Moved() || gone.Status != http.StatusNotFound {
+		t.Errorf("/gone: status %d, broken %t, moved %t", gone.Status, gone.Broken(), gone.Moved())
+	}
+	if !slow.Broken() || slow.Err == nil {
+		t.Errorf("/slow: status %d, error %v, want a timeout", slow.Status, slow.Err)
+	}
+}
+
+func TestCheckDoesNotStarveOtherHosts(t *testing.T) {
+	busy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer busy.Close()
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer other.Close()
+
+	urls := []string{}
+	for range 4 {
+		urls = append(urls, busy.URL)
+	}
+	urls = append(urls, other.URL)
+
+	// every request to the busy host after the first waits a second, with
+	// the slots held while waiting the other host would be checked last
+	checker := NewChecker(2, time.Second, DefaultTimeout, "")
+	start := time.Now()
+	var mu sync.Mutex
+	var otherChecked time.Duration
+	checker.Check(urls, func(result Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		if result.Url == other.URL {
+			otherChecked = time.Since(start)
+		}
+	})
+	if otherChecked > 500*time.Millisecond {
+		t.Errorf("the other host was checked after %s", otherChecked)
+	}
+}
@@ -0,0 +1,186 @@
+package metadata
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	// FormatHTML is a single html file with stylesheets and images inlined
+	FormatHTML = "html"
+	// FormatText is the readable text of the page
+	FormatText = "text"
+)
+
+// Snapshot turns a downloaded page into a single self-contained file in the
+// given format. The html format modifies the page's document.
+func (f *Fetcher) Snapshot(page *Page, format string) ([]byte, error) {
+	if page.Doc == nil {
+		return nil, fmt.Errorf("unable to archive %s pages", page.ContentType)
+	}
+
+	switch format {
+	case FormatHTML:
+		return f.inline(page)
+	case FormatText:
+		title := page.Metadata().Title
+		return []byte(title + "\n" + page.URL.String() + "\n\n" + ReadableText(page.Doc)), nil
+	default:
+		return nil, errors.New("unknown archive format: " + format)
+	}
+}
+
+var cssUrl = regexp.MustCompile(`url\(\s*['"]?([^'")]+)['"]?\s*\)`)
+
+// urlAttributes are the attributes that browsers follow as links or load.
+var urlAttributes = map[string]bool{
+	"href": true, "src": true, "action": true, "formaction": true,
+	"data": true, "poster": true, "background": true, "cite": true,
+}
+
+// inline replaces stylesheets and images with inline copies and drops scripts
+// so the page renders the same without the network.
+func (f *Fetcher) inline(page *Page) ([]byte, error) {
+	doc := page.Doc
+	doc.Find("script, noscript, iframe, object, embed, meta[http-equiv], link[rel='preload'], link[rel='prefetch']").Remove()
+	// scripts also hide in event handlers and javascript: links
+	doc.Find("*").Each(func(i int, s *goquery.Selection) {
+		for _, node := range s.Nodes {
+			kept := node.Attr[:0]
+			for _, attr := range node.Attr {
+				key := strings.ToLower(attr.Key)
+				if strings.HasPrefix(key, "on") || (urlAttributes[key] && isJavascriptURL(attr.Val)) {
+					continue
+				}
+				kept = append(kept, attr)
+			}
+			node.Attr = kept
+		}
+	})
+
+	doc.Find("link[rel='stylesheet']").Each(func(i int, s *goquery.Selection) {
+		href, err := page.URL.Parse(s.AttrOr("href", ""))
+		if err != nil {
+			s.Remove()
+			return
+		}
+		css, _, err := f.download(href)
+		if err != nil {
+			s.Remove()
+			return
+		}
+		s.ReplaceWithHtml("<style>" + f.inlineCSS(href, string(css)) + "</style>")
+	})
+
+	doc.Find("style").Each(func(i int, s *goquery.Selection) {
+		// style contents are raw text, SetText would escape them
+		s.SetHtml(f.inlineCSS(page.URL, s.Text()))
+	})
+
+	doc.Find("img[src]").Each(func(i int, s *goquery.Selection) {
+		s.RemoveAttr("srcset")
+		src, err := page.URL.Parse(s.AttrOr("src", ""))
+		if err != nil || src.Scheme == "data" {
+			return
+		}
+		if data, err := f.dataURI(src); err == nil {
+			s.SetAttr("src", data)
+		} else {
+			s.SetAttr("src", src.String())
+		}
+	})
+	doc.Find("picture source").Remove()
+
+	// anything left over (links, ...) should still point at the live site
+	doc.Find("head").PrependHtml(`<meta charset="utf-8"><base href="` + html.EscapeString(page.URL.String()) + `">`)
+
+	out, err := doc.Html()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(out), nil
+}
+
+// isJavascriptURL is true for javascript: urls, browsers ignore whitespace
+// and control characters in them.
+func isJavascriptURL(value string) bool {
+	cleaned := strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, value)
+	return strings.HasPrefix(strings.ToLower(cleaned), "javascript:")
+}
+
+// inlineCSS inlines the url() references (images, fonts) of a stylesheet.
+func (f *Fetcher) inlineCSS(base *url.URL, css string) string {
+	return cssUrl.ReplaceAllStringFunc(css, func(match string) string {
+		ref := cssUrl.FindStringSubmatch(match)[1]
+		u, err := base.Parse(ref)
+		if err != nil || u.Scheme == "data" {
+			return match
+		}
+		data, err := f.dataURI(u)
+		if err != nil {
+			return `url("` + u.String() + `")`
+		}
+		return `url("` + data + `")`
+	})
+}
+
+func (f *Fetcher) dataURI(u *url.URL) (string, error) {
+	b, contentType, err := f.download(u)
+	if err != nil {
+		return "", err
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = http.DetectContentType(b)
+	}
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(b), nil
+}
+
+// ReadableText pulls the main text out of a page, skipping navigation and other
+// boilerplate.
+func ReadableText(doc *goquery.Document) string {
+	doc = goquery.CloneDocument(doc)
+	doc.Find("script, style, noscript, nav, header, footer, aside, form, iframe, svg").Remove()
+
+	root := doc.Find("article").First()
+	if root.Length() == 0 {
+		root = doc.Find("main, [role='main']").First()
+	}
+	if root.Length() == 0 {
+		root = doc.Find("body")
+	}
+
+	blocks := []string{}
+	root.Find("h1, h2, h3, h4, h5, h6, p, li, pre, blockquote").Each(func(i int, s *goquery.Selection) {
+		// nested blocks are picked up by their parent
+		if s.ParentsFiltered("p, li, pre, blockquote").Length() > 0 {
+			return
+		}
+		text := s.Text()
+		if !s.Is("pre") {
+			text = strings.Join(strings.Fields(text), " ")
+		}
+		if text != "" {
+			blocks = append(blocks, text)
+		}
+	})
+	if len(blocks) == 0 {
+		return strings.Join(strings.Fields(root.Text()), " ")
+	}
+
+	return strings.Join(blocks, "\n\n")
+}
@@ -0,0 +1,43 @@
+package metadata
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestSnapshotStripsScripts(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><head>
+<meta http-equiv="refresh" content="0;url=javascript:alert(1)">
+<script>alert(1)</script></head>
+<body onload="alert(1)">
+<a href="javascript:alert(1)">js</a>
+<a href=" JaVa&#09;Script:alert(1)">spaced</a>
+<a href="/about" onClick="alert(1)">about</a>
+<form action="javascript:alert(1)"><button formaction="javascript:alert(1)">go</button></form>
+<img src="data:image/gif;base64,R0lGODlhAQABAAAAACw=" onerror="alert(1)" alt="javascript: the good parts">
+<svg><a xlink:href="javascript:alert(1)"><text>svg</text></a></svg>
+<object data="javascript:alert(1)"></object>
+</body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := &Page{URL: mustParse(t, "https://example.com/post"), ContentType: "text/html", Doc: doc}
+
+	out, err := DefaultFetcher.Snapshot(page, FormatHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archived := strings.ToLower(string(out))
+	for _, script := range []string{"<script", "onload", "onclick", "onerror", "javascript:alert", "script:alert", "http-equiv", "<object"} {
+		if strings.Contains(archived, script) {
+			t.Errorf("the archive still has %q:\n%s", script, out)
+		}
+	}
+	for _, kept := range []string{`href="/about"`, `alt="javascript: the good parts"`, ">about</a>", "data:image/gif"} {
+		if !strings.Contains(string(out), kept) {
+			t.Errorf("the archive lost %q:\n%s", kept, out)
+		}
+	}
+}
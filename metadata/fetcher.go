@@ -0,0 +1,228 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	DefaultTimeout     = 15 * time.Second
+	DefaultMaxBodySize = 5 << 20 // 5MiB
+	DefaultUserAgent   = "mark/1.0 (+https://github.com/lukasmwerner/mark)"
+	MaxRedirects       = 10
+)
+
+// DefaultFetcher is used by Fetch.
+var DefaultFetcher = NewFetcher(DefaultTimeout, DefaultMaxBodySize, DefaultUserAgent)
+
+// Fetcher downloads pages for metadata extraction.
+type Fetcher struct {
+	Client      *http.Client
+	UserAgent   string
+	MaxBodySize int64
+}
+
+func NewFetcher(timeout time.Duration, maxBodySize int64, userAgent string) *Fetcher {
+	return &Fetcher{
+		Client: &http.Client{
+			Timeout: timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= MaxRedirects {
+					return fmt.Errorf("stopped after %d redirects", MaxRedirects)
+				}
+				return nil
+			},
+		},
+		UserAgent:   userAgent,
+		MaxBodySize: maxBodySize,
+	}
+}
+
+// ErrPrivateAddress is returned by the fetchers from NewPublicFetcher for urls
+// on this machine or its network.
+var ErrPrivateAddress = errors.New("refusing to fetch a private address")
+
+// blockedPrefixes are not covered by netip's checks but are not public either.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier grade nat
+}
+
+// NewPublicFetcher is NewFetcher for urls sent by someone else, like api
+// clients. It only connects to public addresses, so it can not be used to
+// reach the machine it runs on or anything on its network. The address is
+// checked when connecting, which covers redirects and dns that changes
+// between lookups.
+func NewPublicFetcher(timeout time.Duration, maxBodySize int64, userAgent string) *Fetcher {
+	f := NewFetcher(timeout, maxBodySize, userAgent)
+	dialer := &net.Dialer{Timeout: timeout, Control: refusePrivate}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would connect on our behalf without the check
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	f.Client.Transport = transport
+	return f
+}
+
+func refusePrivate(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !isPublic(addr) {
+		return fmt.Errorf("%w %s", ErrPrivateAddress, host)
+	}
+	return nil
+}
+
+// isPublic reports whether the address is reachable on the internet, and not
+// on this machine or a private network.
+func isPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// Fetch downloads the page with the DefaultFetcher.
+func Fetch(u *url.URL) (Metadata, error) {
+	return DefaultFetcher.Fetch(u)
+}
+
+// Page is a downloaded page, Doc is only set for html pages.
+type Page struct {
+	URL         *url.URL
+	ContentType string
+	Doc         *goquery.Document
+}
+
+// Fetch downloads the page once and extracts all the metadata from it. Pages
+// that are not html (pdfs, images, ...) are not read, the file name is used as
+// the title instead.
+func (f *Fetcher) Fetch(u *url.URL) (Metadata, error) {
+	page, err := f.Page(u)
+	if err != nil {
+		return Metadata{}, err
+	}
+	return page.Metadata(), nil
+}
+
+// Metadata extracts the metadata from a downloaded page.
+func (p *Page) Metadata() Metadata {
+	if p.Doc == nil {
+		return Metadata{
+			URL:         p.URL.String(),
+			ContentType: p.ContentType,
+			Title:       fileName(p.URL),
+		}
+	}
+	m := Extract(p.Doc, p.URL)
+	m.URL = p.URL.String()
+	m.ContentType = p.ContentType
+	return m
+}
+
+// Page downloads and parses the page.
+func (f *Fetcher) Page(u *url.URL) (*Page, error) {
+	resp, err := f.get(u, "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		// servers that do not send a content type are most likely serving html
+		mediaType = "text/html"
+	}
+
+	page := &Page{URL: resp.Request.URL, ContentType: mediaType}
+	if !isHTML(mediaType) {
+		return page, nil
+	}
+
+	body := io.LimitReader(resp.Body, f.MaxBodySize)
+	decoded, err := charset.NewReader(body, contentType)
+	if err != nil {
+		return nil, errors.Join(errors.New("unable to decode page"), err)
+	}
+
+	page.Doc, err = goquery.NewDocumentFromReader(decoded)
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// download reads a whole resource (stylesheet, image, ...) up to the size limit.
+func (f *Fetcher) download(u *url.URL) ([]byte, string, error) {
+	resp, err := f.get(u, "*/*")
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, f.MaxBodySize))
+	if err != nil {
+		return nil, "", err
+	}
+	return b, resp.Header.Get("Content-Type"), nil
+}
+
+func (f *Fetcher) get(u *url.URL, accept string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.UserAgent)
+	req.Header.Set("Accept", accept)
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return resp, nil
+}
+
+func isHTML(mediaType string) bool {
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+func fileName(u *url.URL) string {
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		return u.Host
+	}
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	return strings.TrimSpace(name)
+}
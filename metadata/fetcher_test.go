@@ -0,0 +1,222 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+// slowServer answers after the delay, or when the test is over.
+func slowServer(t *testing.T, delay time.Duration) *httptest.Server {
+	t.Helper()
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-done:
+		}
+		fmt.Fprint(w, "<title>Slow</title>")
+	}))
+	t.Cleanup(func() {
+		close(done)
+		server.Close()
+	})
+	return server
+}
+
+func TestFetchTimeout(t *testing.T) {
+	server := slowServer(t, 5*time.Second)
+	fetcher := NewFetcher(100*time.Millisecond, DefaultMaxBodySize, DefaultUserAgent)
+
+	start := time.Now()
+	_, err := fetcher.Fetch(mustParse(t, server.URL))
+	if err == nil {
+		t.Fatal("a page slower than the timeout was fetched")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("gave up after %s", elapsed)
+	}
+}
+
+func TestFetchMaxBodySize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><head><title>Big page</title>")
+		fmt.Fprint(w, strings.Repeat("<!-- padding -->", 1000))
+		fmt.Fprint(w, `<meta name="description" content="past the limit"></head></html>`)
+	}))
+	defer server.Close()
+	fetcher := NewFetcher(DefaultTimeout, 1024, DefaultUserAgent)
+
+	m, err := fetcher.Fetch(mustParse(t, server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Title != "Big page" {
+		t.Errorf("title %q, want Big page", m.Title)
+	}
+	if m.Description != "" {
+		t.Errorf("read %q from past the size limit", m.Description)
+	}
+}
+
+func TestFetchNotHTML(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		fmt.Fprint(w, "%PDF-1.4 <title>not read</title>")
+	}))
+	defer server.Close()
+
+	tests := map[string]string{
+		"/files/annual%20report.pdf": "annual report.pdf",
+		"/":                          strings.TrimPrefix(server.URL, "http://"),
+	}
+	for path, title := range tests {
+		m, err := DefaultFetcher.Fetch(mustParse(t, server.URL+path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Title != title || m.ContentType != "application/pdf" {
+			t.Errorf("%s: title %q and content type %q, want %q and application/pdf", path, m.Title, m.ContentType, title)
+		}
+	}
+}
+
+func TestFetchCharset(t *testing.T) {
+	// "Café crème" in latin-1
+	latin1 := "Caf\xe9 cr\xe8me"
+	pages := map[string]struct {
+		contentType string
+		body        string
+	}{
+		"header": {"text/html; charset=iso-8859-1", "<title>" + latin1 + "</title>"},
+		"meta":   {"text/html", `<meta charset="iso-8859-1"><title>` + latin1 + "</title>"},
+		"utf-8":  {"text/html; charset=utf-8", "<title>Café crème</title>"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := pages[strings.TrimPrefix(r.URL.Path, "/")]
+		w.Header().Set("Content-Type", page.contentType)
+		fmt.Fprint(w, page.body)
+	}))
+	defer server.Close()
+
+	for name := range pages {
+		m, err := DefaultFetcher.Fetch(mustParse(t, server.URL+"/"+name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Title != "Café crème" {
+			t.Errorf("%s: title %q", name, m.Title)
+		}
+	}
+}
+
+// redirectServer redirects /n to /n-1 until /0, which is the page. Like
+// net/http, the fetcher makes at most MaxRedirects requests, so /MaxRedirects
+// is one redirect too many.
+func redirectServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		fmt.Sscanf(r.URL.Path, "/%d", &n)
+		if n > 0 {
+			http.Redirect(w, r, fmt.Sprintf("/%d", n-1), http.StatusMovedPermanently)
+			return
+		}
+		fmt.Fprint(w, "<title>Landed</title>")
+	}))
+}
+
+func TestFetchFollowsRedirects(t *testing.T) {
+	server := redirectServer()
+	defer server.Close()
+
+	m, err := DefaultFetcher.Fetch(mustParse(t, fmt.Sprintf("%s/%d", server.URL, MaxRedirects-1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Title != "Landed" || m.URL != server.URL+"/0" {
+		t.Errorf("landed on %s titled %q, want %s/0 titled Landed", m.URL, m.Title, server.URL)
+	}
+}
+
+func TestFetchRedirectLimit(t *testing.T) {
+	server := redirectServer()
+	defer server.Close()
+
+	_, err := DefaultFetcher.Fetch(mustParse(t, fmt.Sprintf("%s/%d", server.URL, MaxRedirects)))
+	if err == nil || !strings.Contains(err.Error(), "redirects") {
+		t.Errorf("followed more than %d redirects, error %v", MaxRedirects, err)
+	}
+}
+
+func TestFetchErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	if _, err := DefaultFetcher.Fetch(mustParse(t, server.URL)); err == nil {
+		t.Error("a 404 page was fetched")
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.215.14":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"127.1.2.3":        false,
+		"::1":              false,
+		"10.0.0.1":         false,
+		"172.16.5.4":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"fe80::1":          false,
+		"fd00::1":          false,
+		"::ffff:127.0.0.1": false,
+		"::ffff:10.0.0.1":  false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"0.1.2.3":          false,
+		"::":               false,
+		"224.0.0.1":        false,
+	}
+	for addr, public := range tests {
+		if got := isPublic(netip.MustParseAddr(addr)); got != public {
+			t.Errorf("isPublic(%s) = %t, want %t", addr, got, public)
+		}
+	}
+}
+
+func TestPublicFetcherRefusesPrivateAddresses(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		fmt.Fprint(w, "<title>Internal</title>")
+	}))
+	defer server.Close()
+	fetcher := NewPublicFetcher(DefaultTimeout, DefaultMaxBodySize, DefaultUserAgent)
+
+	local := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	for _, u := range []string{server.URL, local} {
+		_, err := fetcher.Fetch(mustParse(t, u))
+		if !errors.Is(err, ErrPrivateAddress) {
+			t.Errorf("fetching %s: %v", u, err)
+		}
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("the server got %d requests", n)
+	}
+}
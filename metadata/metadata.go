@@ -0,0 +1,225 @@
+package metadata
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Metadata is everything that could be scraped about a page.
+type Metadata struct {
+	// URL is where the page ended up after following redirects
+	URL         string
+	ContentType string
+	Title       string
+	Description string
+	Canonical   string
+	Favicon     string
+	SiteName    string
+	Author      string
+	Published   string
+	// Keywords are only suggestions for tags, they are not saved directly
+	Keywords []string
+}
+
+// Extract reads the metadata out of an already parsed page. Relative links
+// (canonical, favicon) are resolved against base.
+func Extract(doc *goquery.Document, base *url.URL) Metadata {
+	ld := jsonLD(doc)
+
+	m := Metadata{
+		Title: first(
+			meta(doc, `meta[property='og:title']`),
+			meta(doc, `meta[name='twitter:title']`),
+			ld.text("headline", "name"),
+			doc.Find("title").First().Text(),
+		),
+		Description: first(
+			meta(doc, `meta[property='og:description']`),
+			meta(doc, `meta[name='twitter:description']`),
+			meta(doc, `meta[name='description']`),
+			ld.text("description"),
+		),
+		Canonical: first(
+			attr(doc, `link[rel='canonical']`, "href"),
+			meta(doc, `meta[property='og:url']`),
+		),
+		Favicon: first(
+			attr(doc, `link[rel='icon']`, "href"),
+			attr(doc, `link[rel='shortcut icon']`, "href"),
+			attr(doc, `link[rel='apple-touch-icon']`, "href"),
+			"/favicon.ico",
+		),
+		SiteName: first(
+			meta(doc, `meta[property='og:site_name']`),
+			meta(doc, `meta[name='application-name']`),
+			ld.nested("publisher", "name"),
+		),
+		Author: first(
+			meta(doc, `meta[name='author']`),
+			meta(doc, `meta[property='article:author']`),
+			ld.nested("author", "name"),
+			meta(doc, `meta[name='twitter:creator']`),
+		),
+		Published: first(
+			meta(doc, `meta[property='article:published_time']`),
+			ld.text("datePublished"),
+			meta(doc, `meta[name='date']`),
+			attr(doc, `time[datetime]`, "datetime"),
+		),
+	}
+
+	m.Canonical = resolve(base, m.Canonical)
+	m.Favicon = resolve(base, m.Favicon)
+
+	keywords := strings.Split(meta(doc, `meta[name='keywords']`), ",")
+	doc.Find(`meta[property='article:tag']`).Each(func(i int, s *goquery.Selection) {
+		keywords = append(keywords, s.AttrOr("content", ""))
+	})
+	keywords = append(keywords, ld.list("keywords")...)
+	m.Keywords = dedupe(keywords)
+
+	return m
+}
+
+// Resolved is the url the page should be saved as, the canonical link when the
+// page has one and otherwise the url after redirects.
+func (m Metadata) Resolved() string {
+	for _, candidate := range []string{m.Canonical, m.URL} {
+		u, err := url.Parse(candidate)
+		if err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+			return u.String()
+		}
+	}
+	return ""
+}
+
+func meta(doc *goquery.Document, selector string) string {
+	return attr(doc, selector, "content")
+}
+
+func attr(doc *goquery.Document, selector, name string) string {
+	value, _ := doc.Find(selector).First().Attr(name)
+	return strings.TrimSpace(value)
+}
+
+func first(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func resolve(base *url.URL, ref string) string {
+	if ref == "" || base == nil {
+		return ref
+	}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	return u.String()
+}
+
+func dedupe(values []string) []string {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		key := strings.ToLower(value)
+		if value == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, value)
+	}
+	return out
+}
+
+// linkedData is every object found in the json-ld scripts of a page.
+type linkedData []map[string]any
+
+func jsonLD(doc *goquery.Document) linkedData {
+	ld := linkedData{}
+	doc.Find(`script[type='application/ld+json']`).Each(func(i int, s *goquery.Selection) {
+		var value any
+		if err := json.Unmarshal([]byte(s.Text()), &value); err != nil {
+			return
+		}
+		ld = ld.collect(value)
+	})
+	return ld
+}
+
+func (ld linkedData) collect(value any) linkedData {
+	switch v := value.(type) {
+	case []any:
+		for _, item := range v {
+			ld = ld.collect(item)
+		}
+	case map[string]any:
+		ld = append(ld, v)
+		if graph, ok := v["@graph"]; ok {
+			ld = ld.collect(graph)
+		}
+	}
+	return ld
+}
+
+// text returns the first string value found under any of the keys.
+func (ld linkedData) text(keys ...string) string {
+	for _, obj := range ld {
+		for _, key := range keys {
+			if s, ok := obj[key].(string); ok && strings.TrimSpace(s) != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// nested handles values that can either be a plain string or an object (or a
+// list of objects) such as "author": {"name": "..."}.
+func (ld linkedData) nested(key, field string) string {
+	for _, obj := range ld {
+		switch v := obj[key].(type) {
+		case string:
+			return v
+		case map[string]any:
+			if s, ok := v[field].(string); ok {
+				return s
+			}
+		case []any:
+			for _, item := range v {
+				if m, ok := item.(map[string]any); ok {
+					if s, ok := m[field].(string); ok {
+						return s
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// list handles values that are either a comma seperated string or a list.
+func (ld linkedData) list(key string) []string {
+	out := []string{}
+	for _, obj := range ld {
+		switch v := obj[key].(type) {
+		case string:
+			out = append(out, strings.Split(v, ",")...)
+		case []any:
+			for _, item := range v {
+				if s, ok := item.(string); ok {
+					out = append(out, s)
+				}
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,95 @@
+package metadata
+
+import (
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func extractFile(t *testing.T, name, base string) Metadata {
+	t.Helper()
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Extract(doc, u)
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		file string
+		base string
+		want Metadata
+	}{
+		{"og.html", "https://example.com/news/story?ref=feed", Metadata{
+			Title:       "Open Graph title",
+			Description: "Open Graph description",
+			Canonical:   "https://example.com/news/story",
+			Favicon:     "https://example.com/static/icon.png",
+			SiteName:    "Example News",
+			Author:      "Ada Lovelace",
+			Published:   "2024-05-01T10:00:00Z",
+			Keywords:    []string{"history", "computing", "science"},
+		}},
+		{"twitter.html", "https://example.com/a/b/page", Metadata{
+			Title:       "Twitter title",
+			Description: "Twitter description",
+			Canonical:   "https://example.com/a/canonical/page",
+			Favicon:     "https://cdn.example.com/favicon.svg",
+			SiteName:    "Example App",
+			Author:      "@someone",
+			Keywords:    []string{},
+		}},
+		{"jsonld.html", "https://blog.example.com/post", Metadata{
+			Title:       "Linked data headline",
+			Description: "Linked data description",
+			Favicon:     "https://blog.example.com/favicon.ico",
+			SiteName:    "Example Blog",
+			Author:      "Grace Hopper",
+			Published:   "2023-12-24",
+			Keywords:    []string{"go", "testing"},
+		}},
+		{"plain.html", "http://example.com/dir/", Metadata{
+			Title:     "Only a title",
+			Favicon:   "http://example.com/favicon.ico",
+			Published: "2022-02-02",
+			Keywords:  []string{},
+		}},
+	}
+
+	for _, test := range tests {
+		got := extractFile(t, test.file, test.base)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", test.file, got, test.want)
+		}
+	}
+}
+
+func TestResolved(t *testing.T) {
+	tests := []struct {
+		m    Metadata
+		want string
+	}{
+		{Metadata{URL: "https://example.com/a", Canonical: "https://example.com/b"}, "https://example.com/b"},
+		{Metadata{URL: "https://example.com/a", Canonical: "javascript:alert(1)"}, "https://example.com/a"},
+		{Metadata{URL: "https://example.com/a"}, "https://example.com/a"},
+		{Metadata{}, ""},
+	}
+	for _, test := range tests {
+		if got := test.m.Resolved(); got != test.want {
+			t.Errorf("%+v.Resolved() = %q, want %q", test.m, got, test.want)
+		}
+	}
+}
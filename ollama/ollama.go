@@ -0,0 +1,116 @@
+package ollama
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultHost is where ollama listens unless OLLAMA_HOST says otherwise.
+const DefaultHost = "http://localhost:11434"
+
+const DefaultTimeout = 2 * time.Minute
+
+// Client talks to an ollama (or ollama compatible) http api.
+type Client struct {
+	Host       string
+	HTTPClient *http.Client
+}
+
+func NewClient(host string) *Client {
+	return &Client{
+		Host:       strings.TrimRight(host, "/"),
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+	}
+}
+
+// FromEnv uses the same OLLAMA_HOST variable as the ollama cli, which may be
+// a bare host:port.
+func FromEnv() *Client {
+	host := os.Getenv("OLLAMA_HOST")
+	if host == "" {
+		return NewClient(DefaultHost)
+	}
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	return NewClient(host)
+}
+
+type embedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embedResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+// Embed returns one vector for each of the inputs.
+func (c *Client) Embed(model string, input []string) ([][]float32, error) {
+	var res embedResponse
+	if err := c.post("/api/embed", embedRequest{Model: model, Input: input}, &res); err != nil {
+		return nil, err
+	}
+	if len(res.Embeddings) != len(input) {
+		return nil, fmt.Errorf("ollama returned %d embeddings for %d inputs", len(res.Embeddings), len(input))
+	}
+	return res.Embeddings, nil
+}
+
+type generateRequest struct {
+	Model   string         `json:"model"`
+	Prompt  string         `json:"prompt"`
+	Stream  bool           `json:"stream"`
+	Options map[string]any `json:"options,omitempty"`
+}
+
+type generateResponse struct {
+	Response string `json:"response"`
+}
+
+// Generate returns the model's whole response to the prompt, a low
+// temperature keeps the answers close to the prompt's instructions.
+func (c *Client) Generate(model, prompt string, temperature float64) (string, error) {
+	var res generateResponse
+	req := generateRequest{
+		Model:   model,
+		Prompt:  prompt,
+		Options: map[string]any{"temperature": temperature},
+	}
+	if err := c.post("/api/generate", req, &res); err != nil {
+		return "", err
+	}
+	return res.Response, nil
+}
+
+func (c *Client) post(path string, body, out any) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	res, err := c.HTTPClient.Post(c.Host+path, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		// errors come back as {"error": "..."}
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		if json.Unmarshal(msg, &apiErr) == nil && apiErr.Error != "" {
+			return errors.New("ollama: " + apiErr.Error)
+		}
+		return fmt.Errorf("ollama: %s", res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
@@ -19,7 +19,7 @@ export default function Command() {
 
   const [searchText, setSearchText] = useState("");
   const [showingDetail, setShowDetail] = useState(false);
-  const { isLoading, data } = useFetch(`http://localhost:1990/api/bookmarks/search?q=${searchText}`, {
+  const { isLoading, data } = useFetch(`http://localhost:1990/api/v1/bookmarks/search?q=${searchText}`, {
     headers: {
       Authorization: `Bearer ${apiKey}`,
     },
@@ -41,19 +41,19 @@ export default function Command() {
       ) : (
         (data || []).map((item) => (
           <List.Item
-            key={item.Url}
-            title={item.Title}
-            /*subtitle={showingDetail ? "" : new URL(item.Url).hostname}*/
-            accessories={[{ text: showingDetail ? null : new URL(item.Url).hostname }]}
+            key={item.url}
+            title={item.title}
+            /*subtitle={showingDetail ? "" : new URL(item.url).hostname}*/
+            accessories={[{ text: showingDetail ? null : new URL(item.url).hostname }]}
             detail={
               <List.Item.Detail
-                markdown={item.Description}
+                markdown={item.description}
                 metadata={
                   <List.Item.Detail.Metadata>
-                    <List.Item.Detail.Metadata.Label title="Title" text={item.Title} />
-                    <List.Item.Detail.Metadata.Link title="URL" text={new URL(item.Url).hostname} target={item.Url} />
+                    <List.Item.Detail.Metadata.Label title="Title" text={item.title} />
+                    <List.Item.Detail.Metadata.Link title="URL" text={new URL(item.url).hostname} target={item.url} />
                     <List.Item.Detail.Metadata.TagList title="Tags">
-                      {(item.Tags || []).map((tag) => (
+                      {(item.tags || []).map((tag) => (
                         <List.Item.Detail.Metadata.TagList.Item text={tag} key={tag} />
                       ))}
                     </List.Item.Detail.Metadata.TagList>
@@ -63,8 +63,8 @@ export default function Command() {
             }
             actions={
               <ActionPanel>
-                <Action.OpenInBrowser url={item.Url} />
-                <Action.CopyToClipboard title="Copy URL" content={item.Url} />
+                <Action.OpenInBrowser url={item.url} />
+                <Action.CopyToClipboard title="Copy URL" content={item.url} />
                 <Action title="Toggle Detail" onAction={() => setShowDetail(!showingDetail)} />
               </ActionPanel>
             }
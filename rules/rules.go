@@ -0,0 +1,182 @@
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// FileName is the rules file in the store location.
+const FileName = "rules.json"
+
+// Rule adds its tags to every page matching all of its conditions.
+//
+//	{"url": "github.com/*", "tags": ["code"]}
+//	{"url": "*.arxiv.org", "tags": ["paper"]}
+//	{"title": "(?i)postgres", "tags": ["db"]}
+//	{"content_type": "application/pdf", "tags": ["pdf"]}
+type Rule struct {
+	Name string `json:"name,omitempty"`
+	// Url is a glob, * matches anything (including /) and ? a single
+	// character. Without a / it is matched against the host, otherwise
+	// against the host and path. *.example.com also matches example.com.
+	Url string `json:"url,omitempty"`
+	// Title is a regular expression
+	Title string `json:"title,omitempty"`
+	// ContentType is a prefix of the media type, image/ matches any image
+	ContentType string   `json:"content_type,omitempty"`
+	Tags        []string `json:"tags"`
+
+	url   *regexp.Regexp
+	title *regexp.Regexp
+}
+
+type Rules []Rule
+
+// Page is what the rules are matched against.
+type Page struct {
+	Url         string
+	Title       string
+	ContentType string
+}
+
+// Path is the rules file in the store location unless MARK_RULES is set.
+func Path(storeLoc string) string {
+	if file := os.Getenv("MARK_RULES"); file != "" {
+		return file
+	}
+	return path.Join(storeLoc, FileName)
+}
+
+// Load reads the rules file, there are no rules when it does not exist.
+func Load(file string) (Rules, error) {
+	b, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return Rules{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// Parse reads rules from json, either a list of rules or {"rules": [...]}.
+func Parse(b []byte) (Rules, error) {
+	var rules Rules
+	if err := json.Unmarshal(b, &rules); err != nil {
+		var file struct {
+			Rules *Rules `json:"rules"`
+		}
+		// a single rule is not a rules file, report it as not being a list
+		if json.Unmarshal(b, &file) != nil || file.Rules == nil {
+			return nil, err
+		}
+		rules = *file.Rules
+	}
+
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+	return rules, nil
+}
+
+func (r *Rule) compile() error {
+	if r.Url == "" && r.Title == "" && r.ContentType == "" {
+		return errors.New("needs at least one of url, title or content_type")
+	}
+	if len(r.Tags) == 0 {
+		return errors.New("has no tags")
+	}
+	if r.Url != "" {
+		r.url = glob(strings.ToLower(strings.TrimPrefix(r.Url, "www.")))
+	}
+	if r.Title != "" {
+		title, err := regexp.Compile(r.Title)
+		if err != nil {
+			return err
+		}
+		r.title = title
+	}
+	return nil
+}
+
+// glob turns a url glob into an anchored regular expression.
+func glob(pattern string) *regexp.Regexp {
+	var expr strings.Builder
+	expr.WriteString("^")
+	// *.example.com is meant to cover example.com itself as well
+	if rest, ok := strings.CutPrefix(pattern, "*."); ok {
+		expr.WriteString("(.*\\.)?")
+		pattern = rest
+	}
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String())
+}
+
+// Matches reports whether the page meets every condition of the rule.
+func (r Rule) Matches(p Page) bool {
+	if r.url != nil {
+		u, err := url.Parse(p.Url)
+		if err != nil {
+			return false
+		}
+		target := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+		if strings.Contains(r.Url, "/") {
+			p := u.EscapedPath()
+			if p == "" {
+				p = "/"
+			}
+			target += p
+		}
+		if !r.url.MatchString(target) {
+			return false
+		}
+	}
+	if r.title != nil && !r.title.MatchString(p.Title) {
+		return false
+	}
+	if r.ContentType != "" && !strings.HasPrefix(strings.ToLower(p.ContentType), strings.ToLower(r.ContentType)) {
+		return false
+	}
+	return true
+}
+
+// Tags is every tag of the rules matching the page.
+func (rules Rules) Tags(p Page) []string {
+	tags := []string{}
+	for _, r := range rules {
+		if r.Matches(p) {
+			tags = append(tags, r.Tags...)
+		}
+	}
+	return tags
+}
+
+// Apply adds the tags of the matching rules that are not already in tags.
+func (rules Rules) Apply(tags []string, p Page) []string {
+	out := append([]string{}, tags...)
+	for _, tag := range rules.Tags(p) {
+		if !slices.Contains(out, tag) {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
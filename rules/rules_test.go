@@ -0,0 +1,177 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func mustParse(t *testing.T, s string) Rules {
+	t.Helper()
+	rs, err := Parse([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		rule string
+		page Page
+		want bool
+	}{
+		// url without a / is the host
+		{`{"url": "github.com", "tags": ["t"]}`, Page{Url: "https://github.com/golang/go"}, true},
+		{`{"url": "github.com", "tags": ["t"]}`, Page{Url: "https://gist.github.com/x"}, false},
+		{`{"url": "github.com", "tags": ["t"]}`, Page{Url: "https://github.com.evil.net/"}, false},
+		{`{"url": "GitHub.com", "tags": ["t"]}`, Page{Url: "https://GITHUB.COM:443/"}, true},
+		{`{"url": "github.com", "tags": ["t"]}`, Page{Url: "https://www.github.com/"}, true},
+		{`{"url": "www.github.com", "tags": ["t"]}`, Page{Url: "https://github.com/"}, true},
+		{`{"url": "git?ub.com", "tags": ["t"]}`, Page{Url: "https://gitlub.com/"}, true},
+		{`{"url": "git?ub.com", "tags": ["t"]}`, Page{Url: "https://gitub.com/"}, false},
+		{`{"url": "github.com", "tags": ["t"]}`, Page{Url: "::not a url"}, false},
+
+		// *. covers the domain itself and every subdomain
+		{`{"url": "*.arxiv.org", "tags": ["t"]}`, Page{Url: "https://arxiv.org/abs/1"}, true},
+		{`{"url": "*.arxiv.org", "tags": ["t"]}`, Page{Url: "https://export.arxiv.org/abs/1"}, true},
+		{`{"url": "*.arxiv.org", "tags": ["t"]}`, Page{Url: "https://notarxiv.org/"}, false},
+
+		// url with a / is the host and path
+		{`{"url": "github.com/*", "tags": ["t"]}`, Page{Url: "https://github.com/golang/go"}, true},
+		{`{"url": "github.com/*", "tags": ["t"]}`, Page{Url: "https://github.com"}, true},
+		{`{"url": "github.com/golang/*", "tags": ["t"]}`, Page{Url: "https://github.com/golang/go/issues"}, true},
+		{`{"url": "github.com/golang/*", "tags": ["t"]}`, Page{Url: "https://github.com/rust-lang/rust"}, false},
+		{`{"url": "github.com/golang", "tags": ["t"]}`, Page{Url: "https://github.com/golang/go"}, false},
+		{`{"url": "*/docs/*", "tags": ["t"]}`, Page{Url: "https://go.dev/docs/intro?q=1"}, true},
+		{`{"url": "example.com/a+b", "tags": ["t"]}`, Page{Url: "https://example.com/a+b"}, true},
+		{`{"url": "example.com/a.b", "tags": ["t"]}`, Page{Url: "https://example.com/axb"}, false},
+
+		// title is a regular expression
+		{`{"title": "(?i)postgres", "tags": ["t"]}`, Page{Title: "Tuning PostgreSQL"}, true},
+		{`{"title": "postgres", "tags": ["t"]}`, Page{Title: "Tuning PostgreSQL"}, false},
+		{`{"title": "^Go ", "tags": ["t"]}`, Page{Title: "Go 1.22 is released"}, true},
+		{`{"title": "^Go ", "tags": ["t"]}`, Page{Title: "Let's Go "}, false},
+
+		// content_type is a case insensitive prefix
+		{`{"content_type": "application/pdf", "tags": ["t"]}`, Page{ContentType: "application/pdf"}, true},
+		{`{"content_type": "image/", "tags": ["t"]}`, Page{ContentType: "image/png"}, true},
+		{`{"content_type": "text/html", "tags": ["t"]}`, Page{ContentType: "Text/HTML; charset=utf-8"}, true},
+		{`{"content_type": "image/", "tags": ["t"]}`, Page{ContentType: "text/html"}, false},
+		{`{"content_type": "image/", "tags": ["t"]}`, Page{}, false},
+
+		// every condition has to match
+		{`{"url": "github.com", "title": "(?i)issue", "tags": ["t"]}`, Page{Url: "https://github.com/x", Title: "Issue 1"}, true},
+		{`{"url": "github.com", "title": "(?i)issue", "tags": ["t"]}`, Page{Url: "https://github.com/x", Title: "README"}, false},
+		{`{"url": "github.com", "title": "(?i)issue", "tags": ["t"]}`, Page{Url: "https://gitlab.com/x", Title: "Issue 1"}, false},
+		{`{"url": "*.example.com", "content_type": "application/pdf", "tags": ["t"]}`, Page{Url: "https://docs.example.com/a.pdf", ContentType: "text/html"}, false},
+	}
+	for _, test := range tests {
+		rs := mustParse(t, "["+test.rule+"]")
+		if got := rs[0].Matches(test.page); got != test.want {
+			t.Errorf("%s matches %+v: %t, want %t", test.rule, test.page, got, test.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	list := mustParse(t, `[{"name": "code", "url": "github.com/*", "tags": ["code"]}]`)
+	file := mustParse(t, `{"rules": [{"name": "code", "url": "github.com/*", "tags": ["code"]}]}`)
+	for _, rs := range []Rules{list, file} {
+		if len(rs) != 1 || rs[0].Name != "code" || !rs[0].Matches(Page{Url: "https://github.com/x"}) {
+			t.Errorf("parsed %+v", rs)
+		}
+	}
+	if rs := mustParse(t, `[]`); len(rs) != 0 {
+		t.Errorf("parsed %+v from an empty list", rs)
+	}
+
+	for _, broken := range []struct{ rules, err string }{
+		{`[{"tags": ["t"]}]`, "rule 1: needs at least one of url, title or content_type"},
+		{`[{"url": "a.com", "tags": ["t"]}, {"url": "b.com"}]`, "rule 2: has no tags"},
+		{`[{"url": "b.com", "tags": []}]`, "rule 1: has no tags"},
+		{`[{"title": "(", "tags": ["t"]}]`, "rule 1: error parsing regexp"},
+		{`{"url": "a.com", "tags": ["t"]}`, "cannot unmarshal object"},
+		{`[{"url": 1, "tags": ["t"]}]`, "cannot unmarshal number"},
+		{`not json`, "invalid character"},
+	} {
+		_, err := Parse([]byte(broken.rules))
+		if err == nil || !strings.Contains(err.Error(), broken.err) {
+			t.Errorf("parsing %s: %v, want %q", broken.rules, err, broken.err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("MARK_RULES", "")
+	file := Path(dir)
+	if file != filepath.Join(dir, FileName) {
+		t.Errorf("rules file %q", file)
+	}
+
+	rs, err := Load(file)
+	if err != nil || len(rs) != 0 {
+		t.Errorf("loading a missing file: %+v, %v", rs, err)
+	}
+
+	if err := os.WriteFile(file, []byte(`[{"url": "a.com", "tags": ["a"]}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if rs, err := Load(file); err != nil || len(rs) != 1 {
+		t.Errorf("loading %s: %+v, %v", file, rs, err)
+	}
+
+	if err := os.WriteFile(file, []byte(`[{"url": "a.com"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(file); err == nil {
+		t.Error("loaded a rule without tags")
+	}
+
+	t.Setenv("MARK_RULES", "/etc/mark/rules.json")
+	if file := Path(dir); file != "/etc/mark/rules.json" {
+		t.Errorf("MARK_RULES was ignored, rules file %q", file)
+	}
+}
+
+func TestApply(t *testing.T) {
+	rs := mustParse(t, `[
+		{"url": "github.com", "tags": ["code", "git"]},
+		{"title": "(?i)go", "tags": ["go", "code"]},
+		{"content_type": "application/pdf", "tags": ["pdf"]}
+	]`)
+	page := Page{Url: "https://github.com/golang/go", Title: "The Go programming language"}
+
+	tests := []struct {
+		tags []string
+		want []string
+	}{
+		{nil, []string{"code", "git", "go"}},
+		{[]string{"starred"}, []string{"starred", "code", "git", "go"}},
+		// tags already there keep their place and are not added again
+		{[]string{"go", "starred"}, []string{"go", "starred", "code", "git"}},
+		{[]string{"git", "go", "code"}, []string{"git", "go", "code"}},
+		// Apply only adds, a duplicate of the bookmark's own stays
+		{[]string{"a", "a"}, []string{"a", "a", "code", "git", "go"}},
+	}
+	for _, test := range tests {
+		tags := slices.Clone(test.tags)
+		got := rs.Apply(tags, page)
+		if !slices.Equal(got, test.want) {
+			t.Errorf("applying to %v: %v, want %v", test.tags, got, test.want)
+		}
+		if !slices.Equal(tags, test.tags) {
+			t.Errorf("applying changed the tags to %v", tags)
+		}
+	}
+
+	if got := rs.Apply([]string{"x"}, Page{Url: "https://example.com", Title: "Rust"}); !slices.Equal(got, []string{"x"}) {
+		t.Errorf("no rule matches but the tags are %v", got)
+	}
+	if got := rs.Tags(Page{ContentType: "application/pdf"}); !slices.Equal(got, []string{"pdf"}) {
+		t.Errorf("tags of a pdf %v", got)
+	}
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	BulkCreate = "create"
+	BulkUpdate = "update"
+	BulkDelete = "delete"
+)
+
+// BulkOperation is one change in a Bulk call.
+type BulkOperation struct {
+	// Action is BulkCreate, BulkUpdate or BulkDelete
+	Action string
+	// Id is the bookmark to update or delete
+	Id BookmarkId
+	// Bookmark is the bookmark to create
+	Bookmark Bookmark
+	// Modify changes the bookmark being updated, it is given the bookmark as
+	// it is inside the transaction
+	Modify func(*Bookmark) error
+}
+
+// BulkError is the operation a Bulk call failed on.
+type BulkError struct {
+	Index int
+	Err   error
+}
+
+func (e *BulkError) Error() string {
+	return fmt.Sprintf("operation %d: %s", e.Index, e.Err.Error())
+}
+
+func (e *BulkError) Unwrap() error { return e.Err }
+
+// ModifyBookmark updates a bookmark with modify in a transaction, so it is
+// not changed by anything else in between, and returns it as saved.
+func ModifyBookmark(db *DB, id BookmarkId, modify func(*Bookmark) error) (Bookmark, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return Bookmark{}, err
+	}
+	defer tx.Rollback()
+
+	bookmark, err := modifyBookmark(tx, id, modify)
+	if err != nil {
+		return bookmark, err
+	}
+	return bookmark, tx.Commit()
+}
+
+func modifyBookmark(db querier, id BookmarkId, modify func(*Bookmark) error) (Bookmark, error) {
+	bookmark, err := getBookmarkById(db, id)
+	if err != nil {
+		return bookmark, err
+	}
+	if err := modify(&bookmark); err != nil {
+		return bookmark, err
+	}
+	bookmark.Id = id
+	return bookmark, updateBookmarkById(db, id, bookmark)
+}
+
+// Bulk runs the operations in one transaction, if any of them fails none are
+// applied and the error is a *BulkError. It returns the id of the bookmark
+// each operation was on.
+func Bulk(db *DB, ops []BulkOperation) ([]BookmarkId, error) {
+	ids := make([]BookmarkId, len(ops))
+
+	tx, err := db.Begin()
+	if err != nil {
+		return ids, err
+	}
+	defer tx.Rollback()
+
+	for i, op := range ops {
+		var err error
+		switch op.Action {
+		case BulkCreate:
+			ids[i], err = insertBookmark(tx, op.Bookmark)
+		case BulkUpdate:
+			if op.Modify == nil {
+				err = errors.New("nothing to update")
+				break
+			}
+			ids[i] = op.Id
+			_, err = modifyBookmark(tx, op.Id, op.Modify)
+		case BulkDelete:
+			ids[i] = op.Id
+			err = deleteBookmark(tx, op.Id)
+		default:
+			err = fmt.Errorf("unknown action %q, use %s, %s or %s", op.Action, BulkCreate, BulkUpdate, BulkDelete)
+		}
+		if err != nil {
+			return ids, &BulkError{Index: i, Err: err}
+		}
+	}
+	return ids, tx.Commit()
+}
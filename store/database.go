@@ -1,12 +1,20 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mattn/go-sqlite3"
 )
@@ -61,23 +69,134 @@ BEGIN
 END;`,
 	},
 	{
-		name: "Server_Keys",
-		definition: `CREATE TABLE IF NOT EXISTS Server_Keys (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    key TEXT NOT NULL UNIQUE
+		name: "Bookmarks_content_fts",
+		definition: `CREATE VIRTUAL TABLE IF NOT EXISTS Bookmarks_content_fts USING fts5(
+    content
+);`,
+	},
+	{
+		name: "Bookmark_Content_Sync",
+		definition: `CREATE TRIGGER IF NOT EXISTS Bookmarks_content_delete AFTER DELETE ON Bookmarks
+BEGIN
+    DELETE FROM Bookmarks_content_fts WHERE rowid = old.id;
+END;`,
+	},
+	{
+		// archives are keyed by the url of the page, bookmark ids are local
+		// to each device and can not be synchronized
+		name: "Bookmark_Archives",
+		definition: `CREATE TABLE IF NOT EXISTS Bookmark_Archives (
+    url TEXT PRIMARY KEY NOT NULL,
+    format TEXT NOT NULL DEFAULT '',
+    content BLOB,
+    archived_at TEXT NOT NULL DEFAULT ''
+);`,
+	},
+	{
+		// bookmarks can share a url, the archive is kept until the last of
+		// them is moved or deleted
+		name: "Bookmark_Archives_Sync_1",
+		definition: `CREATE TRIGGER IF NOT EXISTS Bookmark_Archives_move AFTER UPDATE OF url ON Bookmarks
+WHEN old.url IS NOT new.url
+BEGIN
+    INSERT OR IGNORE INTO Bookmark_Archives (url, format, content, archived_at)
+    SELECT new.url, format, content, archived_at FROM Bookmark_Archives WHERE url = old.url;
+    DELETE FROM Bookmark_Archives WHERE url = old.url AND NOT EXISTS (SELECT 1 FROM Bookmarks WHERE url = old.url);
+END;`,
+	},
+	{
+		name: "Bookmark_Archives_Sync_2",
+		definition: `CREATE TRIGGER IF NOT EXISTS Bookmark_Archives_delete AFTER DELETE ON Bookmarks
+BEGIN
+    DELETE FROM Bookmark_Archives WHERE url = old.url AND NOT EXISTS (SELECT 1 FROM Bookmarks WHERE url = old.url);
+END;`,
+	},
+	{
+		// older versions kept archives here by bookmark id, the table stays
+		// so their changes files still apply and is emptied into
+		// Bookmark_Archives when the database is opened
+		name: "Archives",
+		definition: `CREATE TABLE IF NOT EXISTS Archives (
+    bookmark_id INTEGER PRIMARY KEY NOT NULL,
+    format TEXT NOT NULL DEFAULT '',
+    content BLOB,
+    archived_at TEXT NOT NULL DEFAULT ''
+);`,
+	},
+	{
+		// embeddings depend on the model used on this device, so like the
+		// page text they are kept local and recomputed with mark embed
+		name: "Bookmark_Embeddings",
+		definition: `CREATE TABLE IF NOT EXISTS Bookmark_Embeddings (
+    bookmark_id INTEGER NOT NULL,
+    model TEXT NOT NULL,
+    hash TEXT NOT NULL DEFAULT '',
+    vector BLOB NOT NULL,
+    embedded_at TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (bookmark_id, model)
+);`,
+	},
+	{
+		name: "Bookmark_Embeddings_Sync",
+		definition: `CREATE TRIGGER IF NOT EXISTS Bookmark_Embeddings_delete AFTER DELETE ON Bookmarks
+BEGIN
+    DELETE FROM Bookmark_Embeddings WHERE bookmark_id = old.id;
+END;`,
+	},
+	{
+		// the name is the primary key so the same saved search made on two
+		// devices is merged instead of duplicated
+		name: "Saved_Searches",
+		definition: `CREATE TABLE IF NOT EXISTS Saved_Searches (
+    name TEXT PRIMARY KEY NOT NULL,
+    query TEXT NOT NULL DEFAULT '',
+    created_at TEXT NOT NULL DEFAULT ''
 );`,
 	},
 }
 
-func Open() (*DB, error) {
+type column struct {
+	table      string
+	name       string
+	definition string
+	// crr columns have to be added through cr-sqlite so the change
+	// tracking triggers are rebuilt
+	crr bool
+}
+
+var Columns = []column{
+	{table: "Bookmarks", name: "canonical", definition: "TEXT NOT NULL DEFAULT ''", crr: true},
+	{table: "Bookmarks", name: "favicon", definition: "TEXT NOT NULL DEFAULT ''", crr: true},
+	{table: "Bookmarks", name: "site_name", definition: "TEXT NOT NULL DEFAULT ''", crr: true},
+	{table: "Bookmarks", name: "author", definition: "TEXT NOT NULL DEFAULT ''", crr: true},
+	{table: "Bookmarks", name: "published", definition: "TEXT NOT NULL DEFAULT ''", crr: true},
+	{table: "Bookmarks", name: "original_url", definition: "TEXT NOT NULL DEFAULT ''", crr: true},
+	{table: "Bookmarks", name: "last_status", definition: "INTEGER NOT NULL DEFAULT 0", crr: true},
+	{table: "Bookmarks", name: "last_checked", definition: "TEXT NOT NULL DEFAULT ''", crr: true},
+	{table: "Bookmarks", name: "redirect_url", definition: "TEXT NOT NULL DEFAULT ''", crr: true},
+	{table: "Bookmarks", name: "created_at", definition: "TEXT NOT NULL DEFAULT ''", crr: true},
+	{table: "Bookmarks", name: "summary", definition: "TEXT NOT NULL DEFAULT ''", crr: true},
+}
+
+// Location is the directory the database and changes are kept in,
+// MARK_STORE_LOCATION or ~/.config/mark.
+func Location() (string, error) {
 	markStoreLocation := os.Getenv("MARK_STORE_LOCATION")
 	if markStoreLocation == "" {
 		homedir, err := os.UserHomeDir()
 		if err != nil {
-			return nil, errors.Join(errors.New("unable to get homedir"), err)
+			return "", errors.Join(errors.New("unable to get homedir"), err)
 		}
 		markStoreLocation = path.Join(homedir, ".config", "mark")
 	}
+	return markStoreLocation, nil
+}
+
+func Open() (*DB, error) {
+	markStoreLocation, err := Location()
+	if err != nil {
+		return nil, err
+	}
 
 	if err := EnsureDirExists(markStoreLocation); err != nil {
 		return nil, errors.Join(errors.New("unable to make mark store location in: "+markStoreLocation), err)
@@ -89,6 +208,9 @@ func Open() (*DB, error) {
 
 	sql.Register("cr-sqlite", &sqlite3.SQLiteDriver{
 		Extensions: []string{"crsqlite"},
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("url_host", urlHost, true)
+		},
 	})
 
 	sqlDB, err := sql.Open("cr-sqlite", path.Join(markStoreLocation, "data.db"))
@@ -118,6 +240,34 @@ func Open() (*DB, error) {
 		return nil, errors.Join(errors.New("unable to setup crdts"), err)
 	}
 
+	_, err = db.Exec("select crsql_as_crr('Saved_Searches');")
+	if err != nil {
+		return nil, errors.Join(errors.New("unable to setup crdts for saved searches"), err)
+	}
+
+	// archives can be large so they are kept out of the changes files unless
+	// asked for, once enabled they stay synchronized
+	if os.Getenv("MARK_SYNC_ARCHIVES") == "true" {
+		_, err = db.Exec("select crsql_as_crr('Archives');")
+		if err != nil {
+			return nil, errors.Join(errors.New("unable to setup crdts for archives"), err)
+		}
+		_, err = db.Exec("select crsql_as_crr('Bookmark_Archives');")
+		if err != nil {
+			return nil, errors.Join(errors.New("unable to setup crdts for archives"), err)
+		}
+	}
+
+	err = migrateArchives(db)
+	if err != nil {
+		return nil, errors.Join(errors.New("unable to migrate archives"), err)
+	}
+
+	err = EnsureColumns(db, Columns...)
+	if err != nil {
+		return nil, errors.Join(errors.New("unable to migrate tables"), err)
+	}
+
 	err = syncronizeFromHostsToDB(db, hostname, changesPath)
 	if err != nil {
 		return nil, errors.Join(errors.New("unable to sync fs -> db"), err)
@@ -132,9 +282,17 @@ type DB struct {
 	StoreLoc        string
 	ChangesStoreLoc string
 	Hostname        string
+
+	// unsynced is set by OpenUnsynced, which is only built for tests
+	unsynced bool
+
+	fuzzy fuzzyCache
 }
 
 func (db *DB) Close() error {
+	if db.unsynced {
+		return db.DB.Close()
+	}
 	err := syncronizeLocalChangesToDisk(db, path.Join(db.ChangesStoreLoc, db.Hostname))
 	if err != nil {
 		return err
@@ -160,10 +318,109 @@ func EnsureTables(db *DB, tables ...requirement) error {
 	return nil
 }
 
+func EnsureColumns(db *DB, columns ...column) error {
+	ctx := context.Background()
+	// begin/commit alter have to happen on the same connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for _, c := range columns {
+		exists, err := hasColumn(ctx, conn, c.table, c.name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		if c.crr {
+			if _, err := conn.ExecContext(ctx, "SELECT crsql_begin_alter(?);", c.table); err != nil {
+				return err
+			}
+		}
+		_, err = conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", c.table, c.name, c.definition))
+		if err != nil {
+			log.Print(c.table, ".", c.name, err.Error())
+			return err
+		}
+		if c.crr {
+			if _, err := conn.ExecContext(ctx, "SELECT crsql_commit_alter(?);", c.table); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func hasColumn(ctx context.Context, conn *sql.Conn, table, name string) (bool, error) {
+	var count int
+	err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, name).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// bookmarkColumns is the column list read by scanBookmark, it is qualified so
+// it can be used in queries joining against Bookmarks_fts.
+const bookmarkColumns = `Bookmarks.id, Bookmarks.url, Bookmarks.title, Bookmarks.description, Bookmarks.tags,
+	Bookmarks.canonical, Bookmarks.favicon, Bookmarks.site_name, Bookmarks.author, Bookmarks.published,
+	Bookmarks.original_url, Bookmarks.last_status, Bookmarks.last_checked, Bookmarks.redirect_url,
+	Bookmarks.created_at, Bookmarks.summary`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// querier is a DB or a transaction.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// scanBookmark reads the bookmarkColumns, extra is scanned into anything
+// selected after them.
+func scanBookmark(row scanner, extra ...any) (Bookmark, error) {
+	var b Bookmark
+	var tags string
+	dest := []any{&b.Id, &b.Url, &b.Title, &b.Description, &tags,
+		&b.Canonical, &b.Favicon, &b.SiteName, &b.Author, &b.Published,
+		&b.OriginalUrl, &b.LastStatus, &b.LastChecked, &b.RedirectUrl,
+		&b.CreatedAt, &b.Summary}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return b, err
+	}
+	b.Tags = splitTags(tags)
+	return b, nil
+}
+
+func splitTags(tags string) []string {
+	if tags == "" {
+		return []string{}
+	}
+	return strings.Split(tags, ", ")
+}
+
 func InsertBookmark(db *DB, bookmark Bookmark) (BookmarkId, error) {
+	return insertBookmark(db, bookmark)
+}
+
+func insertBookmark(db querier, bookmark Bookmark) (BookmarkId, error) {
 	tags := strings.Join(bookmark.Tags, ", ")
-	result, err := db.Exec("INSERT INTO Bookmarks (url, title, description, tags) VALUES (?, ?, ?, ?)",
-		bookmark.Url, bookmark.Title, bookmark.Description, tags)
+	if bookmark.CreatedAt == "" {
+		bookmark.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	result, err := db.Exec(`INSERT INTO Bookmarks (url, title, description, tags, canonical, favicon, site_name, author, published, original_url, created_at, summary)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		bookmark.Url, bookmark.Title, bookmark.Description, tags,
+		bookmark.Canonical, bookmark.Favicon, bookmark.SiteName, bookmark.Author, bookmark.Published,
+		bookmark.OriginalUrl, bookmark.CreatedAt, bookmark.Summary)
 	if err != nil {
 		return 0, err
 	}
@@ -172,97 +429,642 @@ func InsertBookmark(db *DB, bookmark Bookmark) (BookmarkId, error) {
 }
 
 func GetBookmark(db *DB, url string) (Bookmark, error) {
-	var b Bookmark
-	var tags string
-	err := db.QueryRow("SELECT url, title, description, tags FROM Bookmarks WHERE url = ?", url).Scan(&b.Url, &b.Title, &b.Description, &tags)
+	return scanBookmark(db.QueryRow("SELECT "+bookmarkColumns+" FROM Bookmarks WHERE url = ?", url))
+}
+
+func GetBookmarkById(db *DB, id BookmarkId) (Bookmark, error) {
+	return getBookmarkById(db, id)
+}
+
+func getBookmarkById(db querier, id BookmarkId) (Bookmark, error) {
+	return scanBookmark(db.QueryRow("SELECT "+bookmarkColumns+" FROM Bookmarks WHERE id = ?", id))
+}
+
+// ListBookmarks lists the bookmarks in the order of opts.Sort, with a limit
+// only one page of them. See ListPage for the cursor of the next page.
+func ListBookmarks(db *DB, opts ListOptions) ([]Bookmark, error) {
+	bookmarks, _, err := ListPage(db, opts)
+	return bookmarks, err
+}
+
+// ListPage lists a page of opts.Limit bookmarks starting after opts.Cursor,
+// along with the cursor for the next page, which is empty on the last page.
+func ListPage(db *DB, opts ListOptions) ([]Bookmark, string, error) {
+	bookmarks := []Bookmark{}
+	column, desc, err := sortColumn(opts.Sort)
 	if err != nil {
-		return b, err
+		return bookmarks, "", err
 	}
-	b.Tags = strings.Split(tags, ", ")
-	return b, nil
+
+	conditions := []string{}
+	args := []any{}
+	if opts.Tag != "" {
+		// tags are stored as a ", " seperated list
+		conditions = append(conditions, "(', ' || tags || ', ') LIKE ('%, ' || ? || ', %')")
+		args = append(args, opts.Tag)
+	}
+	if opts.Cursor != "" {
+		c, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return bookmarks, "", err
+		}
+		// bookmarks sharing a value are kept in order by their id
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		conditions = append(conditions, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", column, op))
+		args = append(args, c.Value, c.Value, c.Id)
+	}
+
+	query := "SELECT " + bookmarkColumns + ", " + column + " FROM Bookmarks"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+	query += fmt.Sprintf(" ORDER BY %[1]s %[2]s, id %[2]s", column, direction)
+	if opts.Limit > 0 {
+		// one more than asked for tells whether there is a next page
+		query += " LIMIT " + strconv.Itoa(opts.Limit+1)
+	}
+
+	rows, err := db.Query(query+";", args...)
+	if err != nil {
+		return bookmarks, "", err
+	}
+	defer rows.Close()
+
+	values := []any{}
+	for rows.Next() {
+		var value any
+		b, err := scanBookmark(rows, &value)
+		if err != nil {
+			return bookmarks, "", err
+		}
+		bookmarks = append(bookmarks, b)
+		values = append(values, value)
+	}
+	if err := rows.Err(); err != nil {
+		return bookmarks, "", err
+	}
+
+	if opts.Limit <= 0 || len(bookmarks) <= opts.Limit {
+		return bookmarks, "", nil
+	}
+	bookmarks = bookmarks[:opts.Limit]
+	last := bookmarks[len(bookmarks)-1]
+	next, err := encodeCursor(cursor{Value: values[opts.Limit-1], Id: last.Id})
+	return bookmarks, next, err
+}
+
+// sortColumns are the orders bookmarks can be listed in, a leading - sorts
+// descending.
+var sortColumns = map[string]string{
+	"id":      "id",
+	"created": "created_at",
+	"title":   "COALESCE(title, '')",
+	"url":     "COALESCE(url, '')",
+}
+
+func sortColumn(sort string) (string, bool, error) {
+	if sort == "" {
+		return "id", false, nil
+	}
+	name, desc := strings.CutPrefix(sort, "-")
+	column, ok := sortColumns[name]
+	if !ok {
+		return "", false, fmt.Errorf("%w %q, use id, created, title or url", ErrInvalidSort, sort)
+	}
+	return column, desc, nil
+}
+
+// cursor is where a page left off, the sort value and id of its last
+// bookmark.
+type cursor struct {
+	Value any        `json:"v"`
+	Id    BookmarkId `json:"id"`
+}
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("unknown sort")
+)
+
+func encodeCursor(c cursor) (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	// every page ends at a bookmark, so a cursor without one was not made here
+	if err := json.Unmarshal(b, &c); err != nil || c.Id <= 0 || c.Value == nil {
+		return c, ErrInvalidCursor
+	}
+	// json numbers come back as float64, ids are the only numbers sorted on
+	if f, ok := c.Value.(float64); ok {
+		c.Value = int64(f)
+	}
+	return c, nil
+}
+
+// ListTags returns every tag in use, the most used first.
+func ListTags(db *DB) ([]TagCount, error) {
+	tags := []TagCount{}
+	rows, err := db.Query("SELECT tags FROM Bookmarks WHERE tags != '';")
+	if err != nil {
+		return tags, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var list string
+		if err := rows.Scan(&list); err != nil {
+			return tags, err
+		}
+		for _, tag := range splitTags(list) {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				counts[tag]++
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return tags, err
+	}
+
+	for name, count := range counts {
+		tags = append(tags, TagCount{Name: name, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	return tags, nil
+}
+
+// TagNames is every tag in use, the most used first.
+func TagNames(db *DB) ([]string, error) {
+	names := []string{}
+	tags, err := ListTags(db)
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return names, err
 }
 
-func SearchBookmarks(db *DB, query string) ([]Bookmark, error) {
+func HasBookmark(db *DB, url string) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM Bookmarks WHERE url = ?", url).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// FindDuplicate looks for a bookmark saved under any of the urls, either as its
+// url, its canonical url or the url it was originally submitted as. The urls
+// match over http and https and with or without a trailing slash.
+func FindDuplicate(db *DB, urls ...string) (Bookmark, bool, error) {
+	return findDuplicate(db, 0, urls)
+}
+
+// FindOtherDuplicate is FindDuplicate ignoring the bookmark with the id, for
+// checking a bookmark's new url against everything else.
+func FindOtherDuplicate(db *DB, id BookmarkId, urls ...string) (Bookmark, bool, error) {
+	return findDuplicate(db, id, urls)
+}
+
+func findDuplicate(db *DB, exclude BookmarkId, urls []string) (Bookmark, bool, error) {
+	placeholders := []string{}
+	args := []any{}
+	seen := map[string]bool{}
+	for _, u := range urls {
+		for _, variant := range urlVariants(u) {
+			if seen[variant] {
+				continue
+			}
+			seen[variant] = true
+			placeholders = append(placeholders, "?")
+			args = append(args, variant)
+		}
+	}
+	if len(args) == 0 {
+		return Bookmark{}, false, nil
+	}
+	in := "(" + strings.Join(placeholders, ", ") + ")"
+	query := "SELECT " + bookmarkColumns + " FROM Bookmarks WHERE (url IN " + in +
+		" OR canonical IN " + in + " OR original_url IN " + in + ") AND id != ? LIMIT 1"
+
+	b, err := scanBookmark(db.QueryRow(query, append(append(append(args, args...), args...), exclude)...))
+	if err == sql.ErrNoRows {
+		return b, false, nil
+	}
+	if err != nil {
+		return b, false, err
+	}
+	return b, true, nil
+}
+
+// urlVariants are the ways the same page is commonly saved, the url itself
+// first.
+func urlVariants(u string) []string {
+	if u == "" {
+		return nil
+	}
+	parsed, err := url.Parse(u)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return []string{u}
+	}
+
+	paths := []string{parsed.Path}
+	if parsed.Path == "" || parsed.Path == "/" {
+		paths = []string{"", "/"}
+	} else if trimmed, ok := strings.CutSuffix(parsed.Path, "/"); ok {
+		paths = append(paths, trimmed)
+	} else {
+		paths = append(paths, parsed.Path+"/")
+	}
+
+	variants := []string{u}
+	for _, scheme := range []string{parsed.Scheme, map[string]string{"http": "https", "https": "http"}[parsed.Scheme]} {
+		for _, p := range paths {
+			variant := *parsed
+			variant.Scheme, variant.Path, variant.RawPath = scheme, p, ""
+			variants = append(variants, variant.String())
+		}
+	}
+	return variants
+}
+
+// SearchBookmarks is Search without the scores and snippets.
+func SearchBookmarks(db *DB, query string, opts SearchOptions) ([]Bookmark, error) {
 	bookmarks := []Bookmark{}
-	rows, err := db.Query(`SELECT url, title, description, tags FROM Bookmarks_fts WHERE Bookmarks_fts MATCH ?;`, query)
+	results, err := Search(db, query, opts)
 	if err != nil {
 		return bookmarks, err
 	}
+	for _, result := range results {
+		bookmarks = append(bookmarks, result.Bookmark)
+	}
+	return bookmarks, nil
+}
+
+// Search looks for bookmarks matching the query ordered by how well they match,
+// with opts.Content the text of the pages is searched as well and a snippet of
+// the match is returned.
+func Search(db *DB, query string, opts SearchOptions) ([]SearchResult, error) {
+	results := []SearchResult{}
+
+	var match, contentMatch string
+	var parsed *Query
+	filters, args := "1", []any{}
+	if raw, ok := strings.CutPrefix(query, RawPrefix); ok || opts.Raw {
+		if !ok {
+			raw = query
+		}
+		match = strings.TrimSpace(raw)
+		contentMatch = match
+	} else {
+		expanded, err := ExpandSavedSearches(db, query)
+		if err != nil {
+			return results, err
+		}
+		q := ParseQuery(expanded)
+		match = q.Match(opts.Prefix)
+		// title filters only apply to the bookmark, not the page text
+		contentMatch = q.Text(opts.Prefix)
+		filters, args = q.filters()
+		parsed = &q
+	}
+
+	weights := DefaultWeights
+	if opts.Weights != nil {
+		weights = *opts.Weights
+	}
+	// a negative limit is no limit to sqlite
+	limit := -1
+	if opts.Limit > 0 {
+		limit = opts.Limit
+	}
+	args = append(args,
+		sql.Named("query", match),
+		sql.Named("content_query", contentMatch),
+		sql.Named("url", weights.Url),
+		sql.Named("title", weights.Title),
+		sql.Named("description", weights.Description),
+		sql.Named("tags", weights.Tags),
+		sql.Named("content", weights.Content),
+		sql.Named("start", opts.HighlightStart),
+		sql.Named("end", opts.HighlightEnd),
+		sql.Named("limit", limit),
+	)
+
+	// bm25 scores are negative with the best match being the lowest
+	matches := `WITH matches AS (
+		SELECT rowid,
+			-bm25(Bookmarks_fts, :url, :title, :description, :tags) AS score,
+			highlight(Bookmarks_fts, 1, :start, :end) AS title_highlight,
+			snippet(Bookmarks_fts, 2, :start, :end, '...', 16) AS description_highlight
+		FROM Bookmarks_fts WHERE Bookmarks_fts MATCH :query
+	)`
+
+	var rows *sql.Rows
+	var err error
+	switch {
+	case match == "":
+		// only filters (or nothing at all) to search for
+		rows, err = db.Query(`SELECT `+bookmarkColumns+`, 0, '', '', '' FROM Bookmarks
+		WHERE `+filters+`
+		ORDER BY Bookmarks.id
+		LIMIT :limit;`, args...)
+	case opts.Content && contentMatch != "":
+		rows, err = db.Query(matches+`, content AS (
+			SELECT rowid,
+				-bm25(Bookmarks_content_fts) * :content AS score,
+				snippet(Bookmarks_content_fts, 0, :start, :end, '...', 12) AS snippet
+			FROM Bookmarks_content_fts WHERE Bookmarks_content_fts MATCH :content_query
+		)
+		SELECT `+bookmarkColumns+`,
+			COALESCE(matches.score, 0) + COALESCE(content.score, 0) AS score,
+			COALESCE(matches.title_highlight, ''),
+			COALESCE(matches.description_highlight, ''),
+			COALESCE(content.snippet, '')
+		FROM Bookmarks
+		LEFT JOIN matches ON matches.rowid = Bookmarks.id
+		LEFT JOIN content ON content.rowid = Bookmarks.id
+		WHERE (matches.rowid IS NOT NULL OR content.rowid IS NOT NULL) AND `+filters+`
+		ORDER BY score DESC
+		LIMIT :limit;`, args...)
+	default:
+		rows, err = db.Query(matches+`
+		SELECT `+bookmarkColumns+`, matches.score, matches.title_highlight, matches.description_highlight, ''
+		FROM matches JOIN Bookmarks ON Bookmarks.id = matches.rowid
+		WHERE `+filters+`
+		ORDER BY matches.score DESC
+		LIMIT :limit;`, args...)
+	}
+	if err != nil {
+		return results, err
+	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var b Bookmark
-		var tags string
-		err := rows.Scan(&b.Url, &b.Title, &b.Description, &tags)
+		var r SearchResult
+		b, err := scanBookmark(rows, &r.Score, &r.TitleHighlight, &r.DescriptionHighlight, &r.Snippet)
 		if err != nil {
-			return bookmarks, err
+			return results, err
 		}
-		b.Tags = strings.Split(tags, ", ")
-		bookmarks = append(bookmarks, b)
+		r.Bookmark = b
+		if opts.HighlightStart == "" && opts.HighlightEnd == "" {
+			r.TitleHighlight, r.DescriptionHighlight = "", ""
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return results, err
 	}
 
-	return bookmarks, nil
+	// fuzzy matches are only needed when the exact ones come up short
+	if opts.Fuzzy && parsed != nil && match != "" && len(results) < fuzzyLimit && (limit < 0 || len(results) < limit) {
+		exclude := map[BookmarkId]bool{}
+		for _, r := range results {
+			exclude[r.Id] = true
+		}
+		fuzzyResults, err := fuzzySearch(db, *parsed, exclude)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, fuzzyResults...)
+		if limit > 0 && len(results) > limit {
+			results = results[:limit]
+		}
+	}
+
+	return results, nil
+}
+
+// ParseWeights reads weights in the form "title=10,tags=5", columns that are
+// left out keep their default weight.
+func ParseWeights(s string) (Weights, error) {
+	weights := DefaultWeights
+	for _, part := range strings.Split(s, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return weights, fmt.Errorf("invalid weight: %s", part)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return weights, errors.Join(fmt.Errorf("invalid weight: %s", part), err)
+		}
+		switch strings.TrimSpace(name) {
+		case "url":
+			weights.Url = weight
+		case "title":
+			weights.Title = weight
+		case "description":
+			weights.Description = weight
+		case "tags":
+			weights.Tags = weight
+		case "content":
+			weights.Content = weight
+		default:
+			return weights, fmt.Errorf("unknown column: %s", name)
+		}
+	}
+	return weights, nil
+}
+
+// SaveContent stores the readable text of the bookmarked page so it can be
+// searched, it is kept locally and not synchronized.
+func SaveContent(db *DB, id BookmarkId, content string) error {
+	_, err := db.Exec("DELETE FROM Bookmarks_content_fts WHERE rowid = ?", id)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("INSERT INTO Bookmarks_content_fts (rowid, content) VALUES (?, ?)", id, content)
+	return err
+}
+
+// GetContent is the indexed text of the page, empty if it was never indexed.
+func GetContent(db *DB, id BookmarkId) (string, error) {
+	var content string
+	err := db.QueryRow("SELECT content FROM Bookmarks_content_fts WHERE rowid = ?", id).Scan(&content)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return content, err
+}
+
+func HasContent(db *DB, id BookmarkId) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM Bookmarks_content_fts WHERE rowid = ?", id).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
 }
 
 func UpdateBookmark(db *DB, original Bookmark, updated Bookmark) error {
-	_, err := db.Exec(`UPDATE Bookmarks SET
+	_, err := updateBookmark(db, "url = ?", original.Url, updated)
+	return err
+}
+
+// UpdateBookmarkById is UpdateBookmark for the bookmark with the id, it
+// returns sql.ErrNoRows when there is no such bookmark.
+func UpdateBookmarkById(db *DB, id BookmarkId, updated Bookmark) error {
+	return updateBookmarkById(db, id, updated)
+}
+
+func updateBookmarkById(db querier, id BookmarkId, updated Bookmark) error {
+	result, err := updateBookmark(db, "id = ?", id, updated)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func updateBookmark(db querier, where string, arg any, updated Bookmark) (sql.Result, error) {
+	return db.Exec(`UPDATE Bookmarks SET
 		url = ?,
 		title = ?,
 		description = ?,
-		tags = ?
+		tags = ?,
+		canonical = ?,
+		favicon = ?,
+		site_name = ?,
+		author = ?,
+		published = ?,
+		original_url = ?
 	WHERE
-		url = ?;`,
+		`+where+`;`,
 		updated.Url,
 		updated.Title,
 		updated.Description,
 		strings.Join(updated.Tags, ", "),
-		original.Url,
+		updated.Canonical,
+		updated.Favicon,
+		updated.SiteName,
+		updated.Author,
+		updated.Published,
+		updated.OriginalUrl,
+		arg,
 	)
+}
 
-	return err
+// DeleteBookmark deletes a bookmark, its archive (unless another bookmark has
+// the same url), page text and embeddings go with it through triggers. It
+// returns sql.ErrNoRows when there is no such bookmark.
+func DeleteBookmark(db *DB, id BookmarkId) error {
+	return deleteBookmark(db, id)
 }
 
-func AddKey(db *DB, key string) error {
-	_, err := db.Exec("INSERT INTO Server_Keys (key) VALUES (?)", key)
+func deleteBookmark(db querier, id BookmarkId) error {
+	result, err := db.Exec("DELETE FROM Bookmarks WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
 	return nil
 }
 
-func HasKey(db *DB, key string) (bool, error) {
-	var value string
-	err := db.QueryRow("SELECT key FROM Server_Keys WHERE key = ?", key).Scan(&value)
-	if err != nil || value == "" {
-		return false, err
-	}
-	return true, nil
+// SaveSummary stores a generated summary, UpdateBookmark leaves it alone.
+func SaveSummary(db *DB, id BookmarkId, summary string) error {
+	_, err := db.Exec("UPDATE Bookmarks SET summary = ? WHERE id = ?", summary, id)
+	return err
 }
 
-func GetKeys(db *DB) ([]string, error) {
-	keys := []string{}
-	rows, err := db.Query("SELECT key FROM Server_Keys")
+// RecordLinkStatus stores the result of the last link check, redirect is the
+// url the link ended up at when it differs from the bookmarked url.
+func RecordLinkStatus(db *DB, id BookmarkId, status int, redirect string, checked time.Time) error {
+	_, err := db.Exec("UPDATE Bookmarks SET last_status = ?, redirect_url = ?, last_checked = ? WHERE id = ?",
+		status, redirect, checked.UTC().Format(time.RFC3339), id)
+	return err
+}
+
+// SaveArchive stores the archive under the url of its bookmark, replacing the
+// previous one. It returns sql.ErrNoRows when there is no such bookmark.
+func SaveArchive(db *DB, archive Archive) error {
+	result, err := db.Exec(`INSERT INTO Bookmark_Archives (url, format, content, archived_at)
+	SELECT url, ?, ?, ? FROM Bookmarks WHERE id = ?
+	ON CONFLICT (url) DO UPDATE SET
+		format = excluded.format,
+		content = excluded.content,
+		archived_at = excluded.archived_at;`,
+		archive.Format, archive.Content, archive.ArchivedAt.UTC().Format(time.RFC3339), archive.BookmarkId)
 	if err != nil {
-		return keys, err
+		return err
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var key string
-		err := rows.Scan(&key)
-		if err != nil {
-			return keys, err
-		}
-		keys = append(keys, key)
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
 	}
+	return nil
+}
 
-	return keys, nil
+func GetArchive(db *DB, id BookmarkId) (Archive, error) {
+	var a Archive
+	var archivedAt string
+	err := db.QueryRow(`SELECT Bookmarks.id, Bookmark_Archives.format, Bookmark_Archives.content, Bookmark_Archives.archived_at
+	FROM Bookmarks JOIN Bookmark_Archives ON Bookmark_Archives.url = Bookmarks.url
+	WHERE Bookmarks.id = ?`, id).
+		Scan(&a.BookmarkId, &a.Format, &a.Content, &archivedAt)
+	if err != nil {
+		return a, err
+	}
+	a.ArchivedAt, _ = time.Parse(time.RFC3339, archivedAt)
+	return a, nil
 }
 
-func DeleteKey(db *DB, key string) error {
-	_, err := db.Exec("DELETE FROM Server_Keys WHERE key = ?", key)
+// migrateArchives moves the archives older versions kept by bookmark id to
+// the url of the bookmark, an archive already saved for the url is kept.
+func migrateArchives(db *DB) error {
+	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
-	return nil
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT OR IGNORE INTO Bookmark_Archives (url, format, content, archived_at)
+	SELECT Bookmarks.url, Archives.format, Archives.content, Archives.archived_at
+	FROM Archives JOIN Bookmarks ON Bookmarks.id = Archives.bookmark_id
+	ORDER BY Archives.archived_at DESC;`)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM Archives"); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
@@ -0,0 +1,192 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUrlVariants(t *testing.T) {
+	tests := map[string][]string{
+		"https://x.com":        {"https://x.com", "https://x.com/", "http://x.com", "http://x.com/"},
+		"http://x.com/a/":      {"http://x.com/a/", "http://x.com/a", "https://x.com/a/", "https://x.com/a"},
+		"https://x.com/a?b=c":  {"https://x.com/a?b=c", "https://x.com/a/?b=c", "http://x.com/a?b=c", "http://x.com/a/?b=c"},
+		"ftp://x.com/file.txt": {"ftp://x.com/file.txt"},
+	}
+	for u, want := range tests {
+		got := map[string]bool{}
+		for _, variant := range urlVariants(u) {
+			got[variant] = true
+		}
+		wanted := map[string]bool{}
+		for _, variant := range want {
+			wanted[variant] = true
+		}
+		if !reflect.DeepEqual(got, wanted) {
+			t.Errorf("urlVariants(%q) = %v, want %v", u, urlVariants(u), want)
+		}
+	}
+}
+
+func TestFindDuplicateMatchesVariants(t *testing.T) {
+	db := openTestDB(t)
+	id, err := InsertBookmark(db, Bookmark{Url: "https://example.com", OriginalUrl: "http://example.com/start/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []string{"http://example.com/", "https://example.com/", "https://example.com/start"} {
+		existing, found, err := FindDuplicate(db, u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !found || existing.Id != id {
+			t.Errorf("FindDuplicate(%q) did not find the bookmark", u)
+		}
+	}
+	if _, found, _ := FindDuplicate(db, "https://example.com/other"); found {
+		t.Error("FindDuplicate matched another page")
+	}
+}
+
+func TestArchivesAreKeptByUrl(t *testing.T) {
+	db := openTestDB(t)
+	insert := func(u string) BookmarkId {
+		t.Helper()
+		id, err := InsertBookmark(db, Bookmark{Url: u})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	archived := func(id BookmarkId) string {
+		t.Helper()
+		a, err := GetArchive(db, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ""
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.BookmarkId != id {
+			t.Errorf("the archive of %d is for %d", id, a.BookmarkId)
+		}
+		return string(a.Content)
+	}
+	first := insert("https://example.com/a")
+	shared := insert("https://example.com/a")
+	other := insert("https://example.com/b")
+
+	archivedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := SaveArchive(db, Archive{BookmarkId: first, Format: "text", Content: []byte("a"), ArchivedAt: archivedAt}); err != nil {
+		t.Fatal(err)
+	}
+	a, err := GetArchive(db, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Format != "text" || string(a.Content) != "a" || !a.ArchivedAt.Equal(archivedAt) {
+		t.Errorf("archive %+v", a)
+	}
+	if got := archived(shared); got != "a" {
+		t.Errorf("a bookmark with the same url has the archive %q", got)
+	}
+	if got := archived(other); got != "" {
+		t.Errorf("another bookmark has the archive %q", got)
+	}
+
+	// saving again replaces the archive
+	if err := SaveArchive(db, Archive{BookmarkId: shared, Format: "text", Content: []byte("a2"), ArchivedAt: archivedAt}); err != nil {
+		t.Fatal(err)
+	}
+	if got := archived(first); got != "a2" {
+		t.Errorf("the archive was not replaced: %q", got)
+	}
+	if err := SaveArchive(db, Archive{BookmarkId: 999, Format: "text"}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("archiving a missing bookmark: %v", err)
+	}
+
+	// the archive is copied along when one of the bookmarks moves, and moved
+	// with the last one
+	if err := UpdateBookmarkById(db, first, Bookmark{Url: "https://example.com/c"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, still := archived(first), archived(shared); got != "a2" || still != "a2" {
+		t.Errorf("after moving one bookmark the archives are %q and %q", got, still)
+	}
+	if err := UpdateBookmarkById(db, shared, Bookmark{Url: "https://example.com/b"}); err != nil {
+		t.Fatal(err)
+	}
+	// the archive is of the page at the url, so the bookmark already there
+	// has it too
+	if got := archived(other); got != "a2" {
+		t.Errorf("moving onto a url without an archive gave %q", got)
+	}
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM Bookmark_Archives WHERE url = 'https://example.com/a'").Scan(&count); err != nil || count != 0 {
+		t.Errorf("%d archives are left for a url without bookmarks, %v", count, err)
+	}
+	// editing anything but the url leaves the archive alone
+	if err := UpdateBookmarkById(db, first, Bookmark{Url: "https://example.com/c", Title: "C"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := archived(first); got != "a2" {
+		t.Errorf("editing the title lost the archive: %q", got)
+	}
+
+	if err := DeleteBookmark(db, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM Bookmark_Archives WHERE url = 'https://example.com/c'").Scan(&count); err != nil || count != 0 {
+		t.Errorf("%d archives are left after deleting the bookmark, %v", count, err)
+	}
+	// nothing is left behind for a new bookmark
+	if id := insert("https://example.com/d"); archived(id) != "" {
+		t.Errorf("a new bookmark got an archive")
+	}
+}
+
+func TestMigrateArchives(t *testing.T) {
+	db := openTestDB(t)
+	old, err := InsertBookmark(db, Bookmark{Url: "https://example.com/old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := InsertBookmark(db, Bookmark{Url: "https://example.com/current"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveArchive(db, Archive{BookmarkId: current, Format: "html", Content: []byte("new")}); err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range []struct {
+		id      BookmarkId
+		content string
+	}{{old, "old"}, {current, "stale"}, {999, "orphan"}} {
+		_, err := db.Exec("INSERT INTO Archives (bookmark_id, format, content, archived_at) VALUES (?, 'text', ?, '2024-01-01T00:00:00Z')", a.id, a.content)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := migrateArchives(db); err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[BookmarkId]string{old: "old", current: "new"} {
+		a, err := GetArchive(db, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(a.Content) != want {
+			t.Errorf("the archive of %d is %q, want %q", id, a.Content, want)
+		}
+	}
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM Archives").Scan(&count); err != nil || count != 0 {
+		t.Errorf("%d archives are left by id, %v", count, err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM Bookmark_Archives").Scan(&count); err != nil || count != 2 {
+		t.Errorf("%d archives by url, %v", count, err)
+	}
+}
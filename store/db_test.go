@@ -0,0 +1,22 @@
+//go:build testdb
+
+package store
+
+import (
+	"strings"
+	"testing"
+)
+
+// openTestDB opens an empty store in a temporary directory, without cr-sqlite.
+func openTestDB(tb testing.TB) *DB {
+	tb.Helper()
+	db, err := OpenUnsynced(tb.TempDir())
+	if err != nil && strings.Contains(err.Error(), "no such module: fts5") {
+		tb.Skip(`sqlite was built without fts5, run the tests with -tags "fts5 testdb"`)
+	}
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	return db
+}
@@ -0,0 +1,173 @@
+package store
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lukasmwerner/mark/embeddings"
+)
+
+// semanticLimit caps how many bookmarks a semantic search returns, every
+// bookmark has some similarity so there is no natural cut off.
+const semanticLimit = 50
+
+// embeddingContentLength keeps the page text within what small embedding
+// models read anyway.
+const embeddingContentLength = 4000
+
+// rrfK dampens how much the top ranks dominate when blending results.
+const rrfK = 60
+
+// EmbeddingInput is the text that is embedded for a bookmark, the page text is
+// included when it has been indexed.
+func EmbeddingInput(db *DB, bm Bookmark) (string, error) {
+	content, err := GetContent(db, bm.Id)
+	if err != nil {
+		return "", err
+	}
+	if runes := []rune(content); len(runes) > embeddingContentLength {
+		content = string(runes[:embeddingContentLength])
+	}
+	parts := []string{bm.Title, bm.Description, strings.Join(bm.Tags, ", "), bm.Url}
+	if bm.Summary != "" {
+		parts = append(parts, bm.Summary)
+	}
+	parts = append(parts, content)
+	return strings.TrimSpace(strings.Join(parts, "\n")), nil
+}
+
+// EmbeddingHash identifies the input an embedding was made from, so only
+// bookmarks that changed are embedded again.
+func EmbeddingHash(input string) string {
+	sum := sha256.Sum256([]byte(input))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetEmbeddingHash is the hash of the input of the saved embedding, empty if
+// there is none for the model.
+func GetEmbeddingHash(db *DB, id BookmarkId, model string) (string, error) {
+	var hash string
+	err := db.QueryRow("SELECT hash FROM Bookmark_Embeddings WHERE bookmark_id = ? AND model = ?", id, model).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return hash, err
+}
+
+func SaveEmbedding(db *DB, id BookmarkId, model, hash string, vector []float32) error {
+	_, err := db.Exec(`INSERT INTO Bookmark_Embeddings (bookmark_id, model, hash, vector, embedded_at) VALUES (?, ?, ?, ?, ?)
+	ON CONFLICT (bookmark_id, model) DO UPDATE SET hash = excluded.hash, vector = excluded.vector, embedded_at = excluded.embedded_at;`,
+		id, model, hash, encodeVector(vector), time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+// SemanticSearch ranks the bookmarks embedded with the provider's model by how
+// close they are in meaning to the free text of the query, filters (tag:,
+// site:, ...) still apply. Queries without any free text fall back to Search.
+func SemanticSearch(db *DB, provider embeddings.Provider, query string, opts SearchOptions) ([]SearchResult, error) {
+	results := []SearchResult{}
+
+	expanded, err := ExpandSavedSearches(db, query)
+	if err != nil {
+		return results, err
+	}
+	q := ParseQuery(expanded)
+	text := q.Plain()
+	if strings.TrimSpace(text) == "" {
+		return Search(db, query, opts)
+	}
+
+	vectors, err := provider.Embed([]string{text})
+	if err != nil {
+		return results, err
+	}
+	target := vectors[0]
+
+	filters, args := q.filters()
+	args = append(args, sql.Named("model", provider.Model()))
+	rows, err := db.Query(`SELECT Bookmarks.id, Bookmark_Embeddings.vector
+	FROM Bookmarks JOIN Bookmark_Embeddings ON Bookmark_Embeddings.bookmark_id = Bookmarks.id
+	WHERE Bookmark_Embeddings.model = :model AND `+filters+`;`, args...)
+	if err != nil {
+		return results, err
+	}
+	defer rows.Close()
+
+	type scored struct {
+		id    BookmarkId
+		score float64
+	}
+	ranked := []scored{}
+	for rows.Next() {
+		var id BookmarkId
+		var blob []byte
+		if err := rows.Scan(&id, &blob); err != nil {
+			return results, err
+		}
+		ranked = append(ranked, scored{id, embeddings.Cosine(target, decodeVector(blob))})
+	}
+	if err := rows.Err(); err != nil {
+		return results, err
+	}
+	rows.Close()
+
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].score > ranked[j].score })
+	if len(ranked) > semanticLimit {
+		ranked = ranked[:semanticLimit]
+	}
+	for _, r := range ranked {
+		b, err := GetBookmarkById(db, r.id)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, SearchResult{Bookmark: b, Score: r.score})
+	}
+	return results, nil
+}
+
+// BlendResults merges keyword and semantic results with reciprocal rank
+// fusion, as bm25 scores and cosine similarity are not on the same scale. The
+// highlights and snippets of the keyword results are kept.
+func BlendResults(keyword, semantic []SearchResult) []SearchResult {
+	blended := []SearchResult{}
+	scores := map[BookmarkId]float64{}
+	index := map[BookmarkId]int{}
+
+	for _, list := range [][]SearchResult{keyword, semantic} {
+		for rank, result := range list {
+			if _, ok := index[result.Id]; !ok {
+				index[result.Id] = len(blended)
+				blended = append(blended, result)
+			}
+			scores[result.Id] += 1 / float64(rrfK+rank+1)
+		}
+	}
+
+	for i := range blended {
+		blended[i].Score = scores[blended[i].Id]
+	}
+	sort.SliceStable(blended, func(i, j int) bool { return blended[i].Score > blended[j].Score })
+	return blended
+}
+
+func encodeVector(vector []float32) []byte {
+	b := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(b[i*4:], math.Float32bits(v))
+	}
+	return b
+}
+
+func decodeVector(b []byte) []float32 {
+	vector := make([]float32, len(b)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
+	}
+	return vector
+}
@@ -0,0 +1,212 @@
+package store
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+// stubProvider embeds the texts it knows with fixed vectors.
+type stubProvider struct {
+	model   string
+	vectors map[string][]float32
+	calls   int
+}
+
+func (p *stubProvider) Model() string { return p.model }
+
+func (p *stubProvider) Embed(texts []string) ([][]float32, error) {
+	p.calls++
+	vectors := [][]float32{}
+	for _, text := range texts {
+		vector, ok := p.vectors[text]
+		if !ok {
+			return nil, errors.New("no vector for " + text)
+		}
+		vectors = append(vectors, vector)
+	}
+	return vectors, nil
+}
+
+func urlsOf(results []SearchResult) []string {
+	urls := []string{}
+	for _, r := range results {
+		urls = append(urls, r.Url)
+	}
+	return urls
+}
+
+func TestSemanticSearch(t *testing.T) {
+	db := openTestDB(t)
+	provider := &stubProvider{model: "stub", vectors: map[string][]float32{
+		"systems": {1, 0, 0},
+	}}
+	embed := map[string][]float32{
+		"https://a.example.com/": {2, 0, 0},
+		"https://b.example.com/": {1, 1, 0},
+		"https://c.example.com/": {0, 1, 0},
+		"https://d.example.com/": nil,
+	}
+	tags := map[string][]string{
+		"https://a.example.com/": {"go"},
+		"https://b.example.com/": {"rust"},
+		"https://c.example.com/": {"go"},
+		"https://d.example.com/": {"go"},
+	}
+	for _, u := range []string{"https://a.example.com/", "https://b.example.com/", "https://c.example.com/", "https://d.example.com/"} {
+		id, err := InsertBookmark(db, Bookmark{Url: u, Title: "Page", Tags: tags[u]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if embed[u] != nil {
+			if err := SaveEmbedding(db, id, "stub", "hash", embed[u]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// vectors of another model are never compared
+		if err := SaveEmbedding(db, id, "other", "hash", []float32{0, 0, 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := SemanticSearch(db, provider, "systems", SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"https://a.example.com/", "https://b.example.com/", "https://c.example.com/"}
+	if urls := urlsOf(results); !slices.Equal(urls, want) {
+		t.Errorf("ranked %v, want %v", urls, want)
+	}
+	for i, score := range []float64{1, 1 / math.Sqrt2, 0} {
+		if i < len(results) && math.Abs(results[i].Score-score) > 1e-6 {
+			t.Errorf("%s scored %f, want %f", results[i].Url, results[i].Score, score)
+		}
+	}
+
+	results, err = SemanticSearch(db, provider, "systems tag:go", SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if urls := urlsOf(results); !slices.Equal(urls, []string{"https://a.example.com/", "https://c.example.com/"}) {
+		t.Errorf("filtered by tag:go to %v", urls)
+	}
+
+	// the vectors are replaced when the bookmarks are embedded again
+	if err := SaveEmbedding(db, results[0].Id, "stub", "changed", []float32{0, 1, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveEmbedding(db, results[1].Id, "stub", "changed", []float32{1, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if hash, err := GetEmbeddingHash(db, results[1].Id, "stub"); err != nil || hash != "changed" {
+		t.Errorf("saved hash %q, %v", hash, err)
+	}
+	results, err = SemanticSearch(db, provider, "systems tag:go", SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if urls := urlsOf(results); !slices.Equal(urls, []string{"https://c.example.com/", "https://a.example.com/"}) {
+		t.Errorf("ranked %v after embedding c again", urls)
+	}
+
+	// without free text there is nothing to embed, the filters still apply
+	calls := provider.calls
+	results, err = SemanticSearch(db, provider, "tag:go", SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if urls := urlsOf(results); !slices.Equal(urls, []string{"https://a.example.com/", "https://c.example.com/", "https://d.example.com/"}) {
+		t.Errorf("tag:go found %v", urls)
+	}
+	if provider.calls != calls {
+		t.Error("a query without text was embedded")
+	}
+
+	if _, err := SemanticSearch(db, provider, "unknown", SearchOptions{}); err == nil {
+		t.Error("the provider's error was lost")
+	}
+}
+
+func TestBlendResults(t *testing.T) {
+	result := func(id BookmarkId, highlight string) SearchResult {
+		return SearchResult{Bookmark: Bookmark{Id: id}, Score: 100, TitleHighlight: highlight}
+	}
+	keyword := []SearchResult{result(1, "<b>one</b>"), result(2, "<b>two</b>"), result(3, "<b>three</b>")}
+	semantic := []SearchResult{result(3, ""), result(1, ""), result(4, "")}
+
+	blended := BlendResults(keyword, semantic)
+
+	// each list adds 1 / (60 + rank) with ranks counted from 1
+	want := []struct {
+		id    BookmarkId
+		score float64
+	}{
+		{1, 1.0/61 + 1.0/62},
+		{3, 1.0/63 + 1.0/61},
+		{2, 1.0 / 62},
+		{4, 1.0 / 63},
+	}
+	if len(blended) != len(want) {
+		t.Fatalf("blended %d results, want %d", len(blended), len(want))
+	}
+	for i, w := range want {
+		if blended[i].Id != w.id || math.Abs(blended[i].Score-w.score) > 1e-12 {
+			t.Errorf("result %d is %d scored %f, want %d scored %f", i, blended[i].Id, blended[i].Score, w.id, w.score)
+		}
+	}
+	if blended[0].TitleHighlight != "<b>one</b>" || blended[1].TitleHighlight != "<b>three</b>" {
+		t.Errorf("the keyword highlights were lost: %q, %q", blended[0].TitleHighlight, blended[1].TitleHighlight)
+	}
+
+	// ties keep the keyword order
+	blended = BlendResults([]SearchResult{result(1, "")}, []SearchResult{result(2, "")})
+	if len(blended) != 2 || blended[0].Id != 1 || blended[1].Id != 2 {
+		t.Errorf("blended a tie into %+v", blended)
+	}
+	if blended := BlendResults(nil, nil); len(blended) != 0 {
+		t.Errorf("blended nothing into %+v", blended)
+	}
+	if blended := BlendResults(nil, semantic); len(blended) != 3 || blended[0].Id != 3 {
+		t.Errorf("blended only semantic results into %+v", blended)
+	}
+}
+
+func TestEmbeddingInput(t *testing.T) {
+	db := openTestDB(t)
+	bm := Bookmark{Url: "https://go.dev/", Title: "Go", Description: "A language", Tags: []string{"go", "lang"}}
+	id, err := InsertBookmark(db, bm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bm.Id = id
+
+	input, err := EmbeddingInput(db, bm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if input != "Go\nA language\ngo, lang\nhttps://go.dev/" {
+		t.Errorf("input %q", input)
+	}
+	hash := EmbeddingHash(input)
+
+	if err := SaveContent(db, id, "The Go programming language."); err != nil {
+		t.Fatal(err)
+	}
+	withContent, err := EmbeddingInput(db, bm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if withContent != input+"\nThe Go programming language." || EmbeddingHash(withContent) == hash {
+		t.Errorf("the page text is not embedded: %q", withContent)
+	}
+
+	bm.Tags = []string{"go"}
+	retagged, err := EmbeddingInput(db, bm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if EmbeddingHash(retagged) == EmbeddingHash(withContent) {
+		t.Error("changing the tags did not change the hash")
+	}
+}
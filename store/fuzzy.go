@@ -0,0 +1,131 @@
+package store
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/sahilm/fuzzy"
+)
+
+// fuzzyLimit caps how many fuzzy matches are added below the exact ones.
+const fuzzyLimit = 50
+
+type fuzzyCandidate struct {
+	id   BookmarkId
+	text string
+}
+
+type fuzzyCandidates []fuzzyCandidate
+
+func (c fuzzyCandidates) String(i int) string { return c[i].text }
+func (c fuzzyCandidates) Len() int            { return len(c) }
+
+// fuzzySearch finds bookmarks where every word of the query appears in order,
+// but not necessarily next to each other, in the title, tags or url. This
+// catches typos like kubernets that FTS5 can not match.
+func fuzzySearch(db *DB, q Query, exclude map[BookmarkId]bool) ([]SearchResult, error) {
+	results := []SearchResult{}
+	if len(q.Terms) == 0 {
+		return results, nil
+	}
+
+	filters, args := q.filters()
+	var candidates fuzzyCandidates
+	var err error
+	if filters == "1" {
+		candidates, err = db.fuzzy.load(db)
+	} else {
+		candidates, err = loadFuzzyCandidates(db, filters, args)
+	}
+	if err != nil {
+		return results, err
+	}
+
+	matched := map[int]int{}
+	scores := map[int]int{}
+	for _, term := range q.Terms {
+		for _, match := range fuzzy.FindFrom(term.Text, candidates) {
+			matched[match.Index]++
+			scores[match.Index] += match.Score
+		}
+	}
+
+	best := []int{}
+	for index, count := range matched {
+		if count == len(q.Terms) && !exclude[candidates[index].id] {
+			best = append(best, index)
+		}
+	}
+	sort.Slice(best, func(i, j int) bool {
+		if scores[best[i]] == scores[best[j]] {
+			return best[i] < best[j]
+		}
+		return scores[best[i]] > scores[best[j]]
+	})
+	if len(best) > fuzzyLimit {
+		best = best[:fuzzyLimit]
+	}
+
+	for _, index := range best {
+		b, err := GetBookmarkById(db, candidates[index].id)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, SearchResult{Bookmark: b, Fuzzy: true})
+	}
+	return results, nil
+}
+
+func loadFuzzyCandidates(db *DB, filters string, args []any) (fuzzyCandidates, error) {
+	candidates := fuzzyCandidates{}
+	rows, err := db.Query(`SELECT Bookmarks.id, Bookmarks.title || ' ' || Bookmarks.tags || ' ' || Bookmarks.url
+	FROM Bookmarks WHERE `+filters+`;`, args...)
+	if err != nil {
+		return candidates, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c fuzzyCandidate
+		if err := rows.Scan(&c.id, &c.text); err != nil {
+			return candidates, err
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+// fuzzyCache keeps every bookmark's text around between searches, as loading
+// them all is the slow part of searching as you type. It is thrown away when
+// cr-sqlite reports a new db version.
+type fuzzyCache struct {
+	mu         sync.Mutex
+	version    int64
+	candidates fuzzyCandidates
+}
+
+// dbVersion changes whenever anything in the database changes.
+var dbVersion = func(db *DB) (int64, error) {
+	var version int64
+	err := db.QueryRow("SELECT crsql_db_version();").Scan(&version)
+	return version, err
+}
+
+func (c *fuzzyCache) load(db *DB) (fuzzyCandidates, error) {
+	version, err := dbVersion(db)
+	if err != nil {
+		return loadFuzzyCandidates(db, "1", nil)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.candidates != nil && c.version == version {
+		return c.candidates, nil
+	}
+	candidates, err := loadFuzzyCandidates(db, "1", nil)
+	if err != nil {
+		return candidates, err
+	}
+	c.version, c.candidates = version, candidates
+	return candidates, nil
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+)
+
+var fuzzyWords = []string{
+	"kubernetes", "postgres", "golang", "rust", "typescript", "terraform", "sqlite",
+	"docker", "nginx", "react", "linux", "python", "haskell", "kotlin", "swift",
+}
+
+// seedBookmarks adds n bookmarks in one transaction.
+func seedBookmarks(tb testing.TB, db *DB, n int) {
+	tb.Helper()
+	tx, err := db.Begin()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer tx.Rollback()
+	for i := 0; i < n; i++ {
+		a, b := fuzzyWords[i%len(fuzzyWords)], fuzzyWords[(i/len(fuzzyWords))%len(fuzzyWords)]
+		_, err := insertBookmark(tx, Bookmark{
+			Url:   fmt.Sprintf("https://example%d.com/%s/%s", i, a, b),
+			Title: fmt.Sprintf("Notes on %s and %s, part %d", a, b, i),
+			Tags:  []string{a, b},
+		})
+		if err != nil {
+			tb.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// stableVersion stands in for cr-sqlite's db version, which plain sqlite does
+// not have, so the cache is used.
+func stableVersion(tb testing.TB, version *int64) {
+	previous := dbVersion
+	dbVersion = func(*DB) (int64, error) { return *version, nil }
+	tb.Cleanup(func() { dbVersion = previous })
+}
+
+func TestFuzzyCacheReloadsOnNewVersion(t *testing.T) {
+	db := openTestDB(t)
+	version := int64(1)
+	stableVersion(t, &version)
+	for _, title := range []string{"Kubernetes in action", "Golang notes", "The rust book"} {
+		if _, err := insertBookmark(db, Bookmark{Url: "https://example.com/" + title, Title: title}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q := ParseQuery("kbernets")
+
+	results, err := fuzzySearch(db, q, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("found %d bookmarks, want 1", len(results))
+	}
+
+	if _, err := insertBookmark(db, Bookmark{Url: "https://k8s.io", Title: "Kubernetes docs"}); err != nil {
+		t.Fatal(err)
+	}
+	if results, _ := fuzzySearch(db, q, nil); len(results) != 1 {
+		t.Errorf("found %d bookmarks with the same version, want the cached 1", len(results))
+	}
+	version++
+	if results, _ := fuzzySearch(db, q, nil); len(results) != 2 {
+		t.Errorf("found %d bookmarks after the version changed, want 2", len(results))
+	}
+}
+
+func benchmarkFuzzySearch(b *testing.B, cached bool) {
+	db := openTestDB(b)
+	version := int64(1)
+	stableVersion(b, &version)
+	seedBookmarks(b, db, 50_000)
+	q := ParseQuery("kubernets postgrs")
+	if _, err := fuzzySearch(db, q, nil); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !cached {
+			version++
+		}
+		if _, err := fuzzySearch(db, q, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkFuzzySearchCold loads every bookmark before matching, like the
+// first search after a change.
+func BenchmarkFuzzySearchCold(b *testing.B) { benchmarkFuzzySearch(b, false) }
+
+// BenchmarkFuzzySearchCached matches against the bookmarks kept from the last
+// search, like searching as you type.
+func BenchmarkFuzzySearchCached(b *testing.B) { benchmarkFuzzySearch(b, true) }
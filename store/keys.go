@@ -0,0 +1,441 @@
+package store
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"time"
+)
+
+// Keys are kept in their own database next to data.db instead of in it.
+// keys.db is opened without cr-sqlite so nothing in it can become a CRR and
+// end up in the changes directory, and it is only readable by the owner.
+// Point MARK_KEYS_LOCATION at a shared file to use the same keys on every
+// device, keys made with a device only work on it.
+
+const (
+	// ScopeRead keys can only search and read bookmarks
+	ScopeRead = "read"
+	// ScopeWrite keys can also add, change and delete them
+	ScopeWrite = "write"
+)
+
+// keyPrefix makes keys easy to recognize, for people and secret scanners.
+const keyPrefix = "mark_"
+
+// keyIdLength is how much of a key is kept in the clear to tell keys apart.
+const keyIdLength = len(keyPrefix) + 6
+
+var (
+	ErrInvalidKey = errors.New("invalid api key")
+	ErrKeyExpired = errors.New("api key expired")
+	ErrKeyScope   = errors.New("api key is not allowed to do this")
+	ErrKeyDevice  = errors.New("api key belongs to another device")
+)
+
+const keysTable = `CREATE TABLE IF NOT EXISTS Api_Keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    hash TEXT NOT NULL UNIQUE,
+    prefix TEXT NOT NULL DEFAULT '',
+    label TEXT NOT NULL DEFAULT '',
+    scope TEXT NOT NULL DEFAULT 'write',
+    device TEXT NOT NULL DEFAULT '',
+    created_at TEXT NOT NULL DEFAULT '',
+    last_used TEXT NOT NULL DEFAULT '',
+    expires_at TEXT NOT NULL DEFAULT ''
+);`
+
+// KeyStore is the device local database holding the api keys.
+type KeyStore struct {
+	*sql.DB
+
+	Path     string
+	Hostname string
+}
+
+// KeysPath is MARK_KEYS_LOCATION or keys.db in the store location.
+func KeysPath(storeLoc string) string {
+	if p := os.Getenv("MARK_KEYS_LOCATION"); p != "" {
+		return p
+	}
+	return path.Join(storeLoc, "keys.db")
+}
+
+// OpenKeys opens the key store for db, moving over any keys still kept in
+// data.db.
+func OpenKeys(db *DB) (*KeyStore, error) {
+	keysPath := KeysPath(db.StoreLoc)
+	if err := EnsureDirExists(path.Dir(keysPath)); err != nil {
+		return nil, err
+	}
+
+	// sqlite creates the file with the default permissions, so it is made
+	// first and tightened in case it was made by an older version
+	f, err := os.OpenFile(keysPath, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.Join(errors.New("unable to open key store "+keysPath), err)
+	}
+	f.Close()
+	if err := os.Chmod(keysPath, 0600); err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := sql.Open("sqlite3", keysPath)
+	if err != nil {
+		return nil, errors.Join(errors.New("unable to open key store "+keysPath), err)
+	}
+	keys := &KeyStore{DB: sqlDB, Path: keysPath, Hostname: db.Hostname}
+
+	if _, err := keys.Exec(keysTable); err != nil {
+		keys.Close()
+		return nil, err
+	}
+	if err := migrateKeys(db, keys); err != nil {
+		keys.Close()
+		return nil, errors.Join(errors.New("unable to move api keys out of data.db"), err)
+	}
+	return keys, nil
+}
+
+// ApiKey is everything stored about a key, the key itself is only known when
+// it is created or rotated.
+type ApiKey struct {
+	Id     int64  `json:"id"`
+	Prefix string `json:"prefix"`
+	Label  string `json:"label"`
+	Scope  string `json:"scope"`
+	// Device is the hostname the key works on, empty for any device
+	Device string `json:"device"`
+	// CreatedAt, LastUsed and ExpiresAt are RFC 3339, empty when unset
+	CreatedAt string `json:"created_at"`
+	LastUsed  string `json:"last_used"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// Allows reports whether the key may be used for the scope, write keys can
+// also read.
+func (k ApiKey) Allows(scope string) bool {
+	return k.Scope == ScopeWrite || k.Scope == scope
+}
+
+// Expired reports whether the key has an expiry that has passed.
+func (k ApiKey) Expired(now time.Time) bool {
+	if k.ExpiresAt == "" {
+		return false
+	}
+	expires, err := time.Parse(time.RFC3339, k.ExpiresAt)
+	return err != nil || !now.Before(expires)
+}
+
+func hashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return keyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func formatExpiry(expiresAt time.Time) string {
+	if expiresAt.IsZero() {
+		return ""
+	}
+	return expiresAt.UTC().Format(time.RFC3339)
+}
+
+// NewKey creates a key and returns its secret, which is not stored and can
+// not be shown again. A zero expiresAt never expires, with thisDevice the key
+// only works on this device.
+func NewKey(keys *KeyStore, label, scope string, expiresAt time.Time, thisDevice bool) (string, ApiKey, error) {
+	if scope != ScopeRead && scope != ScopeWrite {
+		return "", ApiKey{}, fmt.Errorf("unknown scope %q, use %s or %s", scope, ScopeRead, ScopeWrite)
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return "", ApiKey{}, err
+	}
+
+	key := ApiKey{
+		Prefix:    secret[:keyIdLength],
+		Label:     label,
+		Scope:     scope,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		ExpiresAt: formatExpiry(expiresAt),
+	}
+	if thisDevice {
+		key.Device = keys.Hostname
+	}
+
+	result, err := keys.Exec(`INSERT INTO Api_Keys (hash, prefix, label, scope, device, created_at, expires_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`, hashKey(secret), key.Prefix, key.Label, key.Scope, key.Device, key.CreatedAt, key.ExpiresAt)
+	if err != nil {
+		return "", key, err
+	}
+	key.Id, err = result.LastInsertId()
+	return secret, key, err
+}
+
+// RotateKey replaces the secret of a key, the old one stops working right
+// away. The label, scope and device are kept, as is the expiry unless a new
+// one is given.
+func RotateKey(keys *KeyStore, idOrLabel string, expiresAt time.Time) (string, ApiKey, error) {
+	id, err := findKey(keys, idOrLabel)
+	if err != nil {
+		return "", ApiKey{}, err
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return "", ApiKey{}, err
+	}
+
+	query := "UPDATE Api_Keys SET hash = ?, prefix = ?, last_used = ''"
+	args := []any{hashKey(secret), secret[:keyIdLength]}
+	if !expiresAt.IsZero() {
+		query += ", expires_at = ?"
+		args = append(args, formatExpiry(expiresAt))
+	}
+	if _, err := keys.Exec(query+" WHERE id = ?", append(args, id)...); err != nil {
+		return "", ApiKey{}, err
+	}
+
+	key, err := scanKey(keys.QueryRow("SELECT "+keyColumns+" FROM Api_Keys WHERE id = ?", id))
+	return secret, key, err
+}
+
+const keyColumns = "id, prefix, label, scope, device, created_at, last_used, expires_at"
+
+func scanKey(row scanner) (ApiKey, error) {
+	var k ApiKey
+	err := row.Scan(&k.Id, &k.Prefix, &k.Label, &k.Scope, &k.Device, &k.CreatedAt, &k.LastUsed, &k.ExpiresAt)
+	return k, err
+}
+
+// lastUsedInterval is how stale last_used may get, so that not every request
+// has to write to keys.db.
+const lastUsedInterval = time.Minute
+
+// Authenticate looks up the key for a secret and records that it was used. A
+// use is only written once the last one is older than lastUsedInterval, and
+// failing to write it does not fail the authentication.
+func Authenticate(keys *KeyStore, secret string) (ApiKey, error) {
+	key, err := LookupKey(keys, secret)
+	if err != nil {
+		return key, err
+	}
+	now := time.Now().UTC()
+	if lastUsed, err := time.Parse(time.RFC3339, key.LastUsed); err == nil && now.Sub(lastUsed) < lastUsedInterval {
+		return key, nil
+	}
+	_, err = keys.Exec("UPDATE Api_Keys SET last_used = ? WHERE id = ?", now.Format(time.RFC3339), key.Id)
+	if err != nil {
+		log.Println("unable to record api key use:", err.Error())
+		return key, nil
+	}
+	key.LastUsed = now.Format(time.RFC3339)
+	return key, nil
+}
+
+// LookupKey is the key for a secret if it would be accepted on this device,
+// without recording a use.
+func LookupKey(keys *KeyStore, secret string) (ApiKey, error) {
+	key, err := scanKey(keys.QueryRow("SELECT "+keyColumns+" FROM Api_Keys WHERE hash = ?", hashKey(secret)))
+	if err == sql.ErrNoRows {
+		return key, ErrInvalidKey
+	}
+	if err != nil {
+		return key, err
+	}
+
+	if key.Device != "" && key.Device != keys.Hostname {
+		return key, ErrKeyDevice
+	}
+	if key.Expired(time.Now().UTC()) {
+		return key, ErrKeyExpired
+	}
+	return key, nil
+}
+
+func ListKeys(keys *KeyStore) ([]ApiKey, error) {
+	list := []ApiKey{}
+	rows, err := keys.Query("SELECT " + keyColumns + " FROM Api_Keys ORDER BY id")
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		key, err := scanKey(rows)
+		if err != nil {
+			return list, err
+		}
+		list = append(list, key)
+	}
+	return list, rows.Err()
+}
+
+// findKey looks up the id of a key by its id or its label, a label has to
+// be unique.
+func findKey(keys *KeyStore, idOrLabel string) (int64, error) {
+	query, arg := "SELECT id FROM Api_Keys WHERE label = ?", any(idOrLabel)
+	if id, err := strconv.ParseInt(idOrLabel, 10, 64); err == nil {
+		query, arg = "SELECT id FROM Api_Keys WHERE id = ?", id
+	}
+
+	rows, err := keys.Query(query, arg)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	switch len(ids) {
+	case 0:
+		return 0, errors.New("no such key")
+	case 1:
+		return ids[0], nil
+	default:
+		return 0, fmt.Errorf("%d keys are labeled %s, use the id instead", len(ids), idOrLabel)
+	}
+}
+
+// DeleteKey deletes a key by its id or its label.
+func DeleteKey(keys *KeyStore, idOrLabel string) error {
+	id, err := findKey(keys, idOrLabel)
+	if err != nil {
+		return err
+	}
+	_, err = keys.Exec("DELETE FROM Api_Keys WHERE id = ?", id)
+	return err
+}
+
+func tableExists(db *DB, name string) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&count)
+	return count > 0, err
+}
+
+// migrateKeys moves keys out of data.db into the key store. Older versions
+// kept them there, in Server_Keys as they are and later in Api_Keys as
+// hashes.
+func migrateKeys(db *DB, keys *KeyStore) error {
+	legacy, err := tableExists(db, "Server_Keys")
+	if err != nil {
+		return err
+	}
+	hashed, err := tableExists(db, "Api_Keys")
+	if err != nil {
+		return err
+	}
+	if !legacy && !hashed {
+		return nil
+	}
+
+	tx, err := keys.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if legacy {
+		if err := copyServerKeys(db, tx); err != nil {
+			return err
+		}
+	}
+	if hashed {
+		if err := copyApiKeys(db, tx); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// the keys are only dropped once they are safe in the key store, if
+	// this fails they are copied again next time and ignored as duplicates
+	if legacy {
+		if _, err := db.Exec("DROP TABLE Server_Keys"); err != nil {
+			return err
+		}
+	}
+	if hashed {
+		if _, err := db.Exec("DROP TABLE Api_Keys"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyServerKeys hashes the keys from Server_Keys, which stored them as they
+// are.
+func copyServerKeys(db *DB, tx *sql.Tx) error {
+	rows, err := db.Query("SELECT key FROM Server_Keys")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for rows.Next() {
+		var secret string
+		if err := rows.Scan(&secret); err != nil {
+			return err
+		}
+		prefix := secret
+		if len(prefix) > 6 {
+			prefix = prefix[:6]
+		}
+		_, err := tx.Exec(`INSERT OR IGNORE INTO Api_Keys (hash, prefix, label, scope, created_at)
+		VALUES (?, ?, 'migrated', ?, ?)`, hashKey(secret), prefix, ScopeWrite, now)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func copyApiKeys(db *DB, tx *sql.Tx) error {
+	rows, err := db.Query("SELECT hash, prefix, label, scope, created_at, last_used, expires_at FROM Api_Keys ORDER BY id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash string
+		var k ApiKey
+		if err := rows.Scan(&hash, &k.Prefix, &k.Label, &k.Scope, &k.CreatedAt, &k.LastUsed, &k.ExpiresAt); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`INSERT OR IGNORE INTO Api_Keys (hash, prefix, label, scope, created_at, last_used, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`, hash, k.Prefix, k.Label, k.Scope, k.CreatedAt, k.LastUsed, k.ExpiresAt)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,194 @@
+package store
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func openTestKeys(t *testing.T) *KeyStore {
+	t.Helper()
+	t.Setenv("MARK_KEYS_LOCATION", "")
+	keys, err := OpenKeys(openTestDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { keys.Close() })
+	return keys
+}
+
+func TestAuthenticateRecordsUseOncePerInterval(t *testing.T) {
+	keys := openTestKeys(t)
+	secret, key, err := NewKey(keys, "test", ScopeRead, time.Time{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastUsed := func() string {
+		t.Helper()
+		var value string
+		if err := keys.QueryRow("SELECT last_used FROM Api_Keys WHERE id = ?", key.Id).Scan(&value); err != nil {
+			t.Fatal(err)
+		}
+		return value
+	}
+	setLastUsed := func(at time.Time) string {
+		t.Helper()
+		value := at.UTC().Format(time.RFC3339)
+		if _, err := keys.Exec("UPDATE Api_Keys SET last_used = ? WHERE id = ?", value, key.Id); err != nil {
+			t.Fatal(err)
+		}
+		return value
+	}
+
+	if _, err := Authenticate(keys, secret); err != nil {
+		t.Fatal(err)
+	}
+	if lastUsed() == "" {
+		t.Error("the first use was not recorded")
+	}
+
+	recent := setLastUsed(time.Now().Add(-10 * time.Second))
+	if _, err := Authenticate(keys, secret); err != nil {
+		t.Fatal(err)
+	}
+	if lastUsed() != recent {
+		t.Error("a use within the interval was written")
+	}
+
+	stale := setLastUsed(time.Now().Add(-2 * lastUsedInterval))
+	if _, err := Authenticate(keys, secret); err != nil {
+		t.Fatal(err)
+	}
+	if lastUsed() == stale {
+		t.Error("a use after the interval was not written")
+	}
+}
+
+func TestAuthenticateIgnoresFailedWrites(t *testing.T) {
+	keys := openTestKeys(t)
+	secret, _, err := NewKey(keys, "test", ScopeRead, time.Time{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = keys.Exec(`CREATE TRIGGER fail_updates BEFORE UPDATE ON Api_Keys
+	BEGIN SELECT RAISE(ABORT, 'database is locked'); END;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := Authenticate(keys, secret)
+	if err != nil {
+		t.Fatalf("a failed write failed the authentication: %v", err)
+	}
+	if key.Label != "test" {
+		t.Errorf("authenticated as %q", key.Label)
+	}
+	if _, err := Authenticate(keys, "mark_wrong"); err != ErrInvalidKey {
+		t.Errorf("a wrong key: %v", err)
+	}
+}
+
+func TestOpenKeysMovesKeysOutOfDataDB(t *testing.T) {
+	t.Setenv("MARK_KEYS_LOCATION", "")
+	db := openTestDB(t)
+	expiresAt := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+	for _, stmt := range []string{
+		`CREATE TABLE Server_Keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    key TEXT NOT NULL UNIQUE
+);`,
+		`INSERT INTO Server_Keys (key) VALUES ('legacy-secret'), ('shared-secret')`,
+		`CREATE TABLE Api_Keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    hash TEXT NOT NULL UNIQUE,
+    prefix TEXT NOT NULL DEFAULT '',
+    label TEXT NOT NULL DEFAULT '',
+    scope TEXT NOT NULL DEFAULT 'write',
+    created_at TEXT NOT NULL DEFAULT '',
+    last_used TEXT NOT NULL DEFAULT '',
+    expires_at TEXT NOT NULL DEFAULT ''
+);`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the same key in both tables is only moved once
+	_, err := db.Exec(`INSERT INTO Api_Keys (hash, prefix, label, scope, created_at, last_used, expires_at)
+	VALUES (?, 'mark_phone', 'phone', 'read', '2025-01-01T00:00:00Z', '2025-02-01T00:00:00Z', ?), (?, 'shared', 'shared', 'write', '', '', '')`,
+		hashKey("mark_phonesecret"), expiresAt, hashKey("shared-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := OpenKeys(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer keys.Close()
+
+	for _, table := range []string{"Server_Keys", "Api_Keys"} {
+		if exists, err := tableExists(db, table); err != nil || exists {
+			t.Errorf("%s is still in data.db: %t, %v", table, exists, err)
+		}
+	}
+
+	want := map[string]ApiKey{
+		"legacy-secret":    {Prefix: "legacy", Label: "migrated", Scope: ScopeWrite},
+		"shared-secret":    {Prefix: "shared", Label: "migrated", Scope: ScopeWrite},
+		"mark_phonesecret": {Prefix: "mark_phone", Label: "phone", Scope: ScopeRead, CreatedAt: "2025-01-01T00:00:00Z", ExpiresAt: expiresAt},
+	}
+	ids := map[string]int64{}
+	for secret, w := range want {
+		key, err := LookupKey(keys, secret)
+		if err != nil {
+			t.Fatalf("%s does not authenticate after moving: %v", secret, err)
+		}
+		if key.Prefix != w.Prefix || key.Label != w.Label || key.Scope != w.Scope || key.ExpiresAt != w.ExpiresAt ||
+			(w.CreatedAt != "" && key.CreatedAt != w.CreatedAt) || key.Device != "" {
+			t.Errorf("%s was moved as %+v, want %+v", secret, key, w)
+		}
+		ids[secret] = key.Id
+	}
+	if key, err := LookupKey(keys, "mark_phonesecret"); err != nil || key.LastUsed != "2025-02-01T00:00:00Z" {
+		t.Errorf("the last use was lost: %+v, %v", key, err)
+	}
+	if list, err := ListKeys(keys); err != nil || len(list) != len(want) {
+		t.Errorf("moved %d keys, want %d: %v", len(list), len(want), err)
+	}
+
+	// opening again has nothing left to move
+	again, err := OpenKeys(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list, err := ListKeys(again); err != nil || len(list) != len(want) {
+		t.Errorf("opening again left %d keys, want %d: %v", len(list), len(want), err)
+	}
+	again.Close()
+
+	// rotating replaces the moved secret
+	for _, idOrLabel := range []string{"phone", strconv.FormatInt(ids["legacy-secret"], 10)} {
+		secret, key, err := RotateKey(keys, idOrLabel, time.Time{})
+		if err != nil {
+			t.Fatalf("rotating %s: %v", idOrLabel, err)
+		}
+		if _, err := Authenticate(keys, secret); err != nil {
+			t.Errorf("the rotated secret of %s: %v", idOrLabel, err)
+		}
+		for old, id := range ids {
+			if id != key.Id {
+				continue
+			}
+			if _, err := Authenticate(keys, old); err != ErrInvalidKey {
+				t.Errorf("%s still works after rotating: %v", old, err)
+			}
+			if key.Label != want[old].Label || key.Scope != want[old].Scope || key.ExpiresAt != want[old].ExpiresAt {
+				t.Errorf("rotating %s changed it to %+v", old, key)
+			}
+		}
+	}
+	if _, err := Authenticate(keys, "shared-secret"); err != nil {
+		t.Errorf("rotating changed another key: %v", err)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !testdb
+
+package store
+
+import "testing"
+
+// openTestDB skips the test, a database can only be opened without cr-sqlite
+// when the tests are built with the testdb tag.
+func openTestDB(tb testing.TB) *DB {
+	tb.Helper()
+	tb.Skip(`the tests need a database, run them with -tags "fts5 testdb"`)
+	return nil
+}
@@ -0,0 +1,261 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// RawPrefix marks a query that is passed to FTS5 as is.
+const RawPrefix = "fts:"
+
+// Query is a parsed search, free text is matched with FTS5 and the filters
+// narrow down the results.
+//
+//	tag:rust -tag:read site:github.com "exact phrase" after:2025-01
+type Query struct {
+	Terms []Term
+
+	Tags    []string
+	NotTags []string
+	// Sites match the host of the url and any of its subdomains
+	Sites  []string
+	Titles []string
+	// Before and After compare against when the bookmark was added
+	Before time.Time
+	After  time.Time
+	// Unread bookmarks are the ones not tagged read
+	Unread bool
+
+	endsInSpace bool
+}
+
+// Term is a word or a quoted phrase from the free text of the query.
+type Term struct {
+	Text   string
+	Phrase bool
+}
+
+// ParseQuery splits the query into free text and filters. Anything that is not
+// a known filter is treated as text, so it never fails.
+func ParseQuery(input string) Query {
+	q := Query{endsInSpace: strings.TrimRightFunc(input, unicode.IsSpace) != input}
+
+	for _, token := range tokenize(input) {
+		if token.Phrase {
+			q.Terms = append(q.Terms, token)
+			continue
+		}
+
+		key, value, ok := strings.Cut(token.Text, ":")
+		value = strings.Trim(value, `"`)
+		if !ok || value == "" {
+			q.Terms = append(q.Terms, token)
+			continue
+		}
+
+		switch strings.ToLower(key) {
+		case "tag":
+			q.Tags = append(q.Tags, value)
+		case "-tag":
+			q.NotTags = append(q.NotTags, value)
+		case "site", "domain":
+			q.Sites = append(q.Sites, strings.ToLower(strings.TrimPrefix(value, "www.")))
+		case "title":
+			q.Titles = append(q.Titles, value)
+		case "before":
+			if t, err := parseDate(value); err == nil {
+				q.Before = t
+			} else {
+				q.Terms = append(q.Terms, token)
+			}
+		case "after":
+			if t, err := parseDate(value); err == nil {
+				q.After = t
+			} else {
+				q.Terms = append(q.Terms, token)
+			}
+		case "is":
+			if strings.ToLower(value) == "unread" {
+				q.Unread = true
+			} else {
+				q.Terms = append(q.Terms, token)
+			}
+		default:
+			q.Terms = append(q.Terms, token)
+		}
+	}
+
+	return q
+}
+
+// Text is the FTS5 expression for the free text only.
+func (q Query) Text(prefix bool) string {
+	terms := []string{}
+	lastIsWord := false
+	for _, term := range q.Terms {
+		// only the word being typed is a prefix, not one before a skipped term
+		lastIsWord = false
+		// terms without any letters or numbers have no tokens to match
+		if !strings.ContainsFunc(term.Text, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }) {
+			continue
+		}
+		terms = append(terms, quoteTerm(term.Text))
+		lastIsWord = !term.Phrase
+	}
+
+	if prefix && lastIsWord && !q.endsInSpace {
+		terms[len(terms)-1] += "*"
+	}
+
+	return strings.Join(terms, " ")
+}
+
+// Match is the FTS5 expression for the free text and title filters.
+func (q Query) Match(prefix bool) string {
+	match := q.Text(prefix)
+	for _, title := range q.Titles {
+		match = strings.TrimSpace(match + " title : " + quoteTerm(title))
+	}
+	return match
+}
+
+// Plain is the free text and title filters without any FTS5 syntax, for
+// searching by meaning.
+func (q Query) Plain() string {
+	words := []string{}
+	for _, term := range q.Terms {
+		words = append(words, term.Text)
+	}
+	return strings.Join(append(words, q.Titles...), " ")
+}
+
+// filters is the sql condition for everything that is not matched by FTS5,
+// the arguments are named so they can be mixed with the search's own.
+func (q Query) filters() (string, []any) {
+	conditions := []string{}
+	args := []any{}
+	arg := func(value any) string {
+		name := fmt.Sprintf("filter%d", len(args))
+		args = append(args, sql.Named(name, value))
+		return ":" + name
+	}
+
+	// tags are stored as a ", " seperated list
+	hasTag := func(tag string) string {
+		return "(', ' || lower(Bookmarks.tags) || ', ') LIKE ('%, ' || lower(" + arg(tag) + ") || ', %')"
+	}
+	for _, tag := range q.Tags {
+		conditions = append(conditions, hasTag(tag))
+	}
+	for _, tag := range q.NotTags {
+		conditions = append(conditions, "NOT "+hasTag(tag))
+	}
+	if q.Unread {
+		conditions = append(conditions, "NOT "+hasTag("read"))
+	}
+
+	if len(q.Sites) > 0 {
+		sites := []string{}
+		for _, site := range q.Sites {
+			name := arg(site)
+			sites = append(sites, "(url_host(Bookmarks.url) = "+name+" OR url_host(Bookmarks.url) LIKE ('%.' || "+name+"))")
+		}
+		conditions = append(conditions, "("+strings.Join(sites, " OR ")+")")
+	}
+
+	// bookmarks saved before created_at was recorded never match dates
+	if !q.Before.IsZero() {
+		conditions = append(conditions, "(Bookmarks.created_at != '' AND Bookmarks.created_at < "+arg(q.Before.UTC().Format(time.RFC3339))+")")
+	}
+	if !q.After.IsZero() {
+		conditions = append(conditions, "(Bookmarks.created_at != '' AND Bookmarks.created_at >= "+arg(q.After.UTC().Format(time.RFC3339))+")")
+	}
+
+	if len(conditions) == 0 {
+		return "1", args
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// MatchExpression turns plain user input into an FTS5 expression that can not
+// fail to parse. Every word is quoted so punctuation (c++, node.js, urls) is
+// treated as text, quoted phrases are kept together and unbalanced quotes are
+// ignored. With prefix the last word also matches longer words, for searching
+// as you type.
+func MatchExpression(input string, prefix bool) string {
+	q := Query{
+		Terms:       tokenize(input),
+		endsInSpace: strings.TrimRightFunc(input, unicode.IsSpace) != input,
+	}
+	return q.Text(prefix)
+}
+
+// tokenize splits on whitespace outside of quotes. A token that is entirely
+// quoted is a phrase, quotes inside a token (tag:"two words") are kept for the
+// caller to strip and an unbalanced quote is dropped.
+func tokenize(input string) []Term {
+	terms := []Term{}
+	var current strings.Builder
+	inQuote := false
+	quoteAt := -1
+
+	flush := func() {
+		text := current.String()
+		current.Reset()
+		if text == "" {
+			return
+		}
+		if len(text) >= 2 && text[0] == '"' && text[len(text)-1] == '"' && strings.Count(text, `"`) == 2 {
+			terms = append(terms, Term{Text: text[1 : len(text)-1], Phrase: true})
+			return
+		}
+		terms = append(terms, Term{Text: text})
+	}
+
+	for i, r := range input {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			quoteAt = i
+			current.WriteRune(r)
+		case unicode.IsSpace(r) && !inQuote:
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+
+	if inQuote {
+		// the last quote was never closed, treat it as if it was not there
+		return tokenize(input[:quoteAt] + input[quoteAt+1:])
+	}
+	flush()
+
+	return terms
+}
+
+func quoteTerm(term string) string {
+	return `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+}
+
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "2006-01", "2006"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date: %s", value)
+}
+
+// urlHost is registered as the url_host sql function.
+func urlHost(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
@@ -0,0 +1,252 @@
+package store
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := map[string][]Term{
+		"":                     {},
+		"  spaced   out\t":     {{Text: "spaced"}, {Text: "out"}},
+		"c++ node.js":          {{Text: "c++"}, {Text: "node.js"}},
+		"https://go.dev/a?b=c": {{Text: "https://go.dev/a?b=c"}},
+		`"exact phrase" go`:    {{Text: "exact phrase", Phrase: true}, {Text: "go"}},
+		`tag:"two words" go`:   {{Text: `tag:"two words"`}, {Text: "go"}},
+		`say "hi"there`:        {{Text: "say"}, {Text: `"hi"there`}},
+		`""`:                   {{Text: "", Phrase: true}},
+		// unbalanced quotes are dropped
+		`"unbalanced go`:  {{Text: "unbalanced"}, {Text: "go"}},
+		`a "b c" "d`:      {{Text: "a"}, {Text: "b c", Phrase: true}, {Text: "d"}},
+		`it's 5" long`:    {{Text: "it's"}, {Text: "5"}, {Text: "long"}},
+		`"a" "b" "c d`:    {{Text: "a", Phrase: true}, {Text: "b", Phrase: true}, {Text: "c"}, {Text: "d"}},
+		"AND OR NOT":      {{Text: "AND"}, {Text: "OR"}, {Text: "NOT"}},
+		`NEAR(a b) col:x`: {{Text: "NEAR(a"}, {Text: "b)"}, {Text: "col:x"}},
+	}
+	for input, want := range tests {
+		if got := tokenize(input); !slices.Equal(got, want) {
+			t.Errorf("tokenize(%q) = %+v, want %+v", input, got, want)
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	tests := []struct {
+		input  string
+		prefix bool
+		want   string
+	}{
+		{"c++", false, `"c++"`},
+		{"c++", true, `"c++"*`},
+		{"node.js streams", false, `"node.js" "streams"`},
+		{"node.js", true, `"node.js"*`},
+		{"https://go.dev/doc?x=1", false, `"https://go.dev/doc?x=1"`},
+		{`say "hi"there`, false, `"say" """hi""there"`},
+		{`"unbalanced go`, false, `"unbalanced" "go"`},
+		{`"unbalanced go`, true, `"unbalanced" "go"*`},
+		{`go "half`, true, `"go" "half"*`},
+		{"AND OR NOT", false, `"AND" "OR" "NOT"`},
+		{"AND OR NOT", true, `"AND" "OR" "NOT"*`},
+		{"NEAR(a b)", false, `"NEAR(a" "b)"`},
+
+		// searching as you type
+		{"go", true, `"go"*`},
+		{"go", false, `"go"`},
+		{"go ", true, `"go"`},
+		{"go\t", true, `"go"`},
+		{"go ++", true, `"go"`},
+		{"go ++ ", true, `"go"`},
+		{"++ go", true, `"go"*`},
+		{`"exact phrase"`, true, `"exact phrase"`},
+		{`go "exact phrase"`, true, `"go" "exact phrase"`},
+		{`"exact phrase" go`, true, `"exact phrase" "go"*`},
+
+		// nothing to match
+		{"", true, ""},
+		{"   ", true, ""},
+		{"+++ ---", true, ""},
+		{`""`, true, ""},
+	}
+	for _, test := range tests {
+		if got := MatchExpression(test.input, test.prefix); got != test.want {
+			t.Errorf("MatchExpression(%q, %t) = %s, want %s", test.input, test.prefix, got, test.want)
+		}
+	}
+}
+
+func TestMatchExpressionIsValidFTS(t *testing.T) {
+	db := openTestDB(t)
+	for _, bm := range []Bookmark{
+		{Url: "https://isocpp.org/", Title: "C++ templates"},
+		{Url: "https://nodejs.org/api/stream.html", Title: "Node.js streams"},
+		{Url: "https://go.dev/", Title: "Go and Rust"},
+	} {
+		if _, err := InsertBookmark(db, bm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		input  string
+		prefix bool
+		want   []string
+	}{
+		{"c++", false, []string{"https://isocpp.org/"}},
+		{"C++ temp", true, []string{"https://isocpp.org/"}},
+		{"node.js", false, []string{"https://nodejs.org/api/stream.html"}},
+		{"nodejs.org/api", false, []string{"https://nodejs.org/api/stream.html"}},
+		{`"go and`, true, []string{"https://go.dev/"}},
+		{`"go and rust"`, false, []string{"https://go.dev/"}},
+		{"go AND", false, []string{"https://go.dev/"}},
+		{"AND OR NOT", true, []string{}},
+		{"NEAR(go rust)", false, []string{}},
+		{"go ++", true, []string{"https://go.dev/"}},
+		{`title:"x`, false, []string{}},
+	}
+	for _, test := range tests {
+		match := MatchExpression(test.input, test.prefix)
+		results, err := Search(db, RawPrefix+match, SearchOptions{})
+		if err != nil {
+			t.Errorf("searching %q as %s: %v", test.input, match, err)
+			continue
+		}
+		urls := []string{}
+		for _, r := range results {
+			urls = append(urls, r.Url)
+		}
+		if !slices.Equal(urls, test.want) {
+			t.Errorf("searching %q as %s found %v, want %v", test.input, match, urls, test.want)
+		}
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+	}
+	tests := []struct {
+		input string
+		want  Query
+	}{
+		{"", Query{}},
+		{"go generics", Query{Terms: []Term{{Text: "go"}, {Text: "generics"}}}},
+		{"go ", Query{Terms: []Term{{Text: "go"}}, endsInSpace: true}},
+
+		{"tag:rust", Query{Tags: []string{"rust"}}},
+		{"TAG:Rust tag:go", Query{Tags: []string{"Rust", "go"}}},
+		{`tag:"two words"`, Query{Tags: []string{"two words"}}},
+		{"-tag:read", Query{NotTags: []string{"read"}}},
+		{"-TAG:read tag:read", Query{Tags: []string{"read"}, NotTags: []string{"read"}}},
+		{"site:www.GitHub.com", Query{Sites: []string{"github.com"}}},
+		{"domain:go.dev site:x.com", Query{Sites: []string{"go.dev", "x.com"}}},
+		{"title:postgres", Query{Titles: []string{"postgres"}}},
+		{`title:"go 1.22" release`, Query{Titles: []string{"go 1.22"}, Terms: []Term{{Text: "release"}}}},
+		{"is:unread", Query{Unread: true}},
+		{"IS:UNREAD", Query{Unread: true}},
+
+		{"before:2025-01-15", Query{Before: date(2025, time.January, 15)}},
+		{"after:2025-03", Query{After: date(2025, time.March, 1)}},
+		{"after:2024 before:2025", Query{After: date(2024, time.January, 1), Before: date(2025, time.January, 1)}},
+		{"before:2025 before:2024-06", Query{Before: date(2024, time.June, 1)}},
+		{`after:"2025-02-03"`, Query{After: date(2025, time.February, 3)}},
+
+		// malformed filters are searched for as text
+		{"before:2025-13", Query{Terms: []Term{{Text: "before:2025-13"}}}},
+		{"after:2025-02-30", Query{Terms: []Term{{Text: "after:2025-02-30"}}}},
+		{"before:yesterday", Query{Terms: []Term{{Text: "before:yesterday"}}}},
+		{"after:25-01-01", Query{Terms: []Term{{Text: "after:25-01-01"}}}},
+		{"is:read", Query{Terms: []Term{{Text: "is:read"}}}},
+		{"tag:", Query{Terms: []Term{{Text: "tag:"}}}},
+		{`tag:""`, Query{Terms: []Term{{Text: `tag:""`}}}},
+		{"tags:go", Query{Terms: []Term{{Text: "tags:go"}}}},
+		{"https://go.dev", Query{Terms: []Term{{Text: "https://go.dev"}}}},
+		{"-rust", Query{Terms: []Term{{Text: "-rust"}}}},
+		{`"tag:rust"`, Query{Terms: []Term{{Text: "tag:rust", Phrase: true}}}},
+		{`tag:"unbalanced`, Query{Tags: []string{"unbalanced"}}},
+
+		{`go tag:rust "exact phrase" -tag:read site:github.com after:2025-01 is:unread`, Query{
+			Terms:   []Term{{Text: "go"}, {Text: "exact phrase", Phrase: true}},
+			Tags:    []string{"rust"},
+			NotTags: []string{"read"},
+			Sites:   []string{"github.com"},
+			After:   date(2025, time.January, 1),
+			Unread:  true,
+		}},
+	}
+	for _, test := range tests {
+		got := ParseQuery(test.input)
+		if !got.Before.Equal(test.want.Before) || !got.After.Equal(test.want.After) {
+			t.Errorf("ParseQuery(%q) is before %v and after %v, want %v and %v", test.input, got.Before, got.After, test.want.Before, test.want.After)
+		}
+		got.Before, got.After = time.Time{}, time.Time{}
+		test.want.Before, test.want.After = time.Time{}, time.Time{}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseQuery(%q) = %+v, want %+v", test.input, got, test.want)
+		}
+	}
+}
+
+func TestSearchFilters(t *testing.T) {
+	db := openTestDB(t)
+	for _, bm := range []Bookmark{
+		{Url: "https://github.com/golang/go", Title: "The Go repository", Tags: []string{"go", "code"}, CreatedAt: "2024-06-15T12:00:00Z"},
+		{Url: "https://gist.github.com/x/1", Title: "A Go gist", Tags: []string{"go", "read"}, CreatedAt: "2025-01-15T12:00:00Z"},
+		{Url: "https://www.postgresql.org/docs/", Title: "PostgreSQL documentation", Tags: []string{"db", "Read Later"}, CreatedAt: "2025-03-15T12:00:00Z"},
+		{Url: "https://notgithub.com/", Title: "Go elsewhere", Tags: []string{"gopher"}, CreatedAt: "2025-05-15T12:00:00Z"},
+	} {
+		if _, err := InsertBookmark(db, bm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// saved before created_at was recorded
+	if _, err := InsertBookmark(db, Bookmark{Url: "https://old.example.com/", Title: "Old Go notes", Tags: []string{"go"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("UPDATE Bookmarks SET created_at = '' WHERE url = 'https://old.example.com/'"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string][]string{
+		"tag:go":                             {"https://github.com/golang/go", "https://gist.github.com/x/1", "https://old.example.com/"},
+		"tag:GO tag:code":                    {"https://github.com/golang/go"},
+		`tag:"read later"`:                   {"https://www.postgresql.org/docs/"},
+		"tag:read":                           {"https://gist.github.com/x/1"},
+		"tag:go -tag:read":                   {"https://github.com/golang/go", "https://old.example.com/"},
+		"-tag:go":                            {"https://www.postgresql.org/docs/", "https://notgithub.com/"},
+		"site:github.com":                    {"https://github.com/golang/go", "https://gist.github.com/x/1"},
+		"site:www.postgresql.org":            {"https://www.postgresql.org/docs/"},
+		"site:gist.github.com":               {"https://gist.github.com/x/1"},
+		"site:hub.com":                       {},
+		"title:gist":                         {"https://gist.github.com/x/1"},
+		"title:go site:github.com":           {"https://github.com/golang/go", "https://gist.github.com/x/1"},
+		`title:"repository go"`:              {},
+		`title:"the go"`:                     {"https://github.com/golang/go"},
+		"repository title:gist":              {},
+		"before:2025":                        {"https://github.com/golang/go"},
+		"after:2025-02":                      {"https://www.postgresql.org/docs/", "https://notgithub.com/"},
+		"after:2025 before:2025-04":          {"https://gist.github.com/x/1", "https://www.postgresql.org/docs/"},
+		"after:2025-01-15 before:2025-01-16": {"https://gist.github.com/x/1"},
+		"is:unread tag:go":                   {"https://github.com/golang/go", "https://old.example.com/"},
+		"is:unread":                          {"https://github.com/golang/go", "https://www.postgresql.org/docs/", "https://notgithub.com/", "https://old.example.com/"},
+		"before:2025-13":                     {},
+	}
+	for query, want := range tests {
+		results, err := Search(db, query, SearchOptions{})
+		if err != nil {
+			t.Errorf("Search(%q): %v", query, err)
+			continue
+		}
+		urls := []string{}
+		for _, r := range results {
+			urls = append(urls, r.Url)
+		}
+		slices.Sort(urls)
+		want = slices.Clone(want)
+		slices.Sort(want)
+		if !slices.Equal(urls, want) {
+			t.Errorf("Search(%q) found %v, want %v", query, urls, want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SavedPrefix marks a saved search in a query, @oncall is replaced with the
+// query saved as oncall.
+const SavedPrefix = "@"
+
+var ErrSavedSearchNotFound = errors.New("no saved search named")
+
+var savedSearchName = regexp.MustCompile(`^[\p{L}\p{N}_-]+$`)
+
+// ValidSavedSearchName reports whether name can be used after an @ in a query.
+func ValidSavedSearchName(name string) bool {
+	return savedSearchName.MatchString(name)
+}
+
+// SaveSearch adds a saved search or replaces the query of an existing one.
+func SaveSearch(db *DB, name, query string) error {
+	if !ValidSavedSearchName(name) {
+		return fmt.Errorf("invalid saved search name %q, use letters, numbers, - and _", name)
+	}
+	if strings.TrimSpace(query) == "" {
+		return errors.New("saved search query is empty")
+	}
+	_, err := db.Exec(`INSERT INTO Saved_Searches (name, query, created_at) VALUES (?, ?, ?)
+	ON CONFLICT (name) DO UPDATE SET query = excluded.query;`, name, query, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+func GetSavedSearch(db *DB, name string) (SavedSearch, error) {
+	var s SavedSearch
+	err := db.QueryRow("SELECT name, query, created_at FROM Saved_Searches WHERE name = ?", name).
+		Scan(&s.Name, &s.Query, &s.CreatedAt)
+	if err == sql.ErrNoRows {
+		return s, fmt.Errorf("%w %s", ErrSavedSearchNotFound, name)
+	}
+	return s, err
+}
+
+func ListSavedSearches(db *DB) ([]SavedSearch, error) {
+	searches := []SavedSearch{}
+	rows, err := db.Query("SELECT name, query, created_at FROM Saved_Searches ORDER BY name")
+	if err != nil {
+		return searches, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s SavedSearch
+		if err := rows.Scan(&s.Name, &s.Query, &s.CreatedAt); err != nil {
+			return searches, err
+		}
+		searches = append(searches, s)
+	}
+	return searches, rows.Err()
+}
+
+func DeleteSavedSearch(db *DB, name string) error {
+	res, err := db.Exec("DELETE FROM Saved_Searches WHERE name = ?", name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w %s", ErrSavedSearchNotFound, name)
+	}
+	return nil
+}
+
+// ExpandSavedSearches replaces every @name outside of quotes with the saved
+// query, so @review tag:go narrows a saved search down further. Saved queries
+// are expanded once, an @name inside of one is left as text. So are names
+// that are not saved searches, like a handle such as @golang.
+func ExpandSavedSearches(db *DB, query string) (string, error) {
+	if !strings.Contains(query, SavedPrefix) {
+		return query, nil
+	}
+
+	var out strings.Builder
+	inQuote := false
+	runes := []rune(query)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		atStart := i == 0 || unicode.IsSpace(runes[i-1])
+		if r == '"' {
+			inQuote = !inQuote
+		}
+		if r != '@' || inQuote || !atStart {
+			out.WriteRune(r)
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && !unicode.IsSpace(runes[end]) {
+			end++
+		}
+		name := string(runes[i+1 : end])
+		if !ValidSavedSearchName(name) {
+			out.WriteRune(r)
+			continue
+		}
+		saved, err := GetSavedSearch(db, name)
+		if errors.Is(err, ErrSavedSearchNotFound) {
+			out.WriteRune(r)
+			continue
+		}
+		if err != nil {
+			return query, err
+		}
+		out.WriteString(saved.Query)
+		i = end - 1
+	}
+	return out.String(), nil
+}
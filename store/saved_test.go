@@ -0,0 +1,55 @@
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExpandSavedSearches(t *testing.T) {
+	db := openTestDB(t)
+	if err := SaveSearch(db, "review", "tag:review is:unread"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"@review":                  "tag:review is:unread",
+		"@review tag:go":           "tag:review is:unread tag:go",
+		"talks by @golang":         "talks by @golang",
+		"@rev":                     "@rev",
+		`"@review" site:x.com`:     `"@review" site:x.com`,
+		"me@review.com":            "me@review.com",
+		"@golang @review":          "@golang tag:review is:unread",
+		"no saved searches at all": "no saved searches at all",
+	}
+	for query, want := range tests {
+		expanded, err := ExpandSavedSearches(db, query)
+		if err != nil {
+			t.Errorf("ExpandSavedSearches(%q): %v", query, err)
+			continue
+		}
+		if expanded != want {
+			t.Errorf("ExpandSavedSearches(%q) = %q, want %q", query, expanded, want)
+		}
+	}
+}
+
+func TestSearchUnknownSavedSearchIsText(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := InsertBookmark(db, Bookmark{Url: "https://x.com/golang", Title: "@golang on X"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{"@golang", "@gol"} {
+		results, err := Search(db, query, SearchOptions{Prefix: true})
+		if err != nil {
+			t.Fatalf("Search(%q): %v", query, err)
+		}
+		if len(results) != 1 {
+			t.Errorf("Search(%q) found %d bookmarks, want 1", query, len(results))
+		}
+	}
+
+	if _, err := GetSavedSearch(db, "golang"); !errors.Is(err, ErrSavedSearchNotFound) {
+		t.Errorf("GetSavedSearch of an unknown name: %v", err)
+	}
+}
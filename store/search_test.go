@@ -0,0 +1,252 @@
+package store
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestContentSearch(t *testing.T) {
+	db := openTestDB(t)
+	insert := func(bm Bookmark) BookmarkId {
+		t.Helper()
+		id, err := InsertBookmark(db, bm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	story := insert(Bookmark{Url: "https://example.com/story", Title: "A short story", Tags: []string{"story"}})
+	long := insert(Bookmark{Url: "https://example.com/long", Title: "A long page", Tags: []string{"essay"}})
+	insert(Bookmark{Url: "https://example.com/empty", Title: "Nothing indexed"})
+
+	if has, err := HasContent(db, story); err != nil || has {
+		t.Errorf("HasContent before indexing: %t, %v", has, err)
+	}
+	if content, err := GetContent(db, story); err != nil || content != "" {
+		t.Errorf("GetContent before indexing: %q, %v", content, err)
+	}
+
+	if err := SaveContent(db, story, "The quick brown fox jumps over the lazy dog."); err != nil {
+		t.Fatal(err)
+	}
+	words := []string{}
+	for i := range 40 {
+		words = append(words, "filler"+string(rune('a'+i%26)))
+	}
+	words[20] = "fox"
+	if err := SaveContent(db, long, strings.Join(words, " ")); err != nil {
+		t.Fatal(err)
+	}
+
+	if has, err := HasContent(db, story); err != nil || !has {
+		t.Errorf("HasContent after indexing: %t, %v", has, err)
+	}
+	if content, err := GetContent(db, story); err != nil || content != "The quick brown fox jumps over the lazy dog." {
+		t.Errorf("GetContent after indexing: %q, %v", content, err)
+	}
+
+	// the page text is only searched when asked for
+	if results, err := Search(db, "fox", SearchOptions{}); err != nil || len(results) != 0 {
+		t.Errorf("searching without content found %d, %v", len(results), err)
+	}
+
+	opts := SearchOptions{Content: true, HighlightStart: "[", HighlightEnd: "]"}
+	results, err := Search(db, "fox", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snippets := map[string]string{}
+	for _, r := range results {
+		snippets[r.Url] = r.Snippet
+		if r.Score <= 0 {
+			t.Errorf("%s scored %f", r.Url, r.Score)
+		}
+	}
+	want := map[string]string{
+		"https://example.com/story": "The quick brown [fox] jumps over the lazy dog.",
+		"https://example.com/long":  "...fillerp fillerq fillerr fillers fillert [fox] fillerv fillerw fillerx fillery fillerz fillera...",
+	}
+	if len(snippets) != len(want) {
+		t.Errorf("found %v", snippets)
+	}
+	for u, snippet := range want {
+		if snippets[u] != snippet {
+			t.Errorf("snippet of %s is %q, want %q", u, snippets[u], snippet)
+		}
+	}
+
+	// without markers the snippet is still there, the matches are not marked
+	results, err = Search(db, "lazy", SearchOptions{Content: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Snippet != "The quick brown fox jumps over the lazy dog." {
+		t.Errorf("searching without markers found %+v", results)
+	}
+
+	// filters apply to the page text matches too
+	for query, want := range map[string][]string{
+		"fox tag:story":  {"https://example.com/story"},
+		"fox -tag:story": {"https://example.com/long"},
+		"fox site:x.com": {},
+		`"brown fox"`:    {"https://example.com/story"},
+		"jum":            {},
+	} {
+		results, err := Search(db, query, opts)
+		if err != nil {
+			t.Fatalf("Search(%q): %v", query, err)
+		}
+		if urls := urlsOf(results); !slices.Equal(urls, want) {
+			t.Errorf("Search(%q) with content found %v, want %v", query, urls, want)
+		}
+	}
+	results, err = Search(db, "jum", SearchOptions{Content: true, Prefix: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if urls := urlsOf(results); !slices.Equal(urls, []string{"https://example.com/story"}) {
+		t.Errorf("searching the content by prefix found %v", urls)
+	}
+
+	// indexing again replaces the text
+	if err := SaveContent(db, story, "A tortoise wins the race."); err != nil {
+		t.Fatal(err)
+	}
+	results, err = Search(db, "fox", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if urls := urlsOf(results); !slices.Equal(urls, []string{"https://example.com/long"}) {
+		t.Errorf("found %v after indexing again", urls)
+	}
+	results, err = Search(db, "tortoise", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Snippet != "A [tortoise] wins the race." {
+		t.Errorf("found %+v after indexing again", results)
+	}
+
+	// the text goes with the bookmark
+	if err := DeleteBookmark(db, story); err != nil {
+		t.Fatal(err)
+	}
+	if has, err := HasContent(db, story); err != nil || has {
+		t.Errorf("HasContent after deleting: %t, %v", has, err)
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	db := openTestDB(t)
+	insert := func(bm Bookmark, content string) {
+		t.Helper()
+		id, err := InsertBookmark(db, bm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if content != "" {
+			if err := SaveContent(db, id, content); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// inserted from the lowest to the highest ranked so the order is not
+	// just the order they were saved in
+	insert(Bookmark{Url: "https://example.com/c", Title: "Database notes"}, "Tuning postgres for small machines.")
+	insert(Bookmark{Url: "https://example.com/u/postgres", Title: "Slides"}, "")
+	insert(Bookmark{Url: "https://example.com/d", Title: "Tuning notes", Description: "How to make postgres fast"}, "")
+	insert(Bookmark{Url: "https://example.com/g", Title: "Database tips", Tags: []string{"postgres"}}, "")
+	insert(Bookmark{Url: "https://example.com/t", Title: "Postgres tuning"}, "")
+
+	opts := SearchOptions{Content: true, HighlightStart: "<mark>", HighlightEnd: "</mark>"}
+	results, err := Search(db, "postgres", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// title (10), tags (5), description (2), url (1) then the page text (0.5)
+	want := []string{"https://example.com/t", "https://example.com/g", "https://example.com/d", "https://example.com/u/postgres", "https://example.com/c"}
+	if urls := urlsOf(results); !slices.Equal(urls, want) {
+		t.Fatalf("ranked %v, want %v", urls, want)
+	}
+	for i := range results {
+		if results[i].Score <= 0 || (i > 0 && results[i].Score >= results[i-1].Score) {
+			t.Errorf("%s scored %f after %f", results[i].Url, results[i].Score, results[max(i-1, 0)].Score)
+		}
+	}
+
+	highlights := []struct{ title, description, snippet string }{
+		{"<mark>Postgres</mark> tuning", "", ""},
+		{"Database tips", "", ""},
+		{"Tuning notes", "How to make <mark>postgres</mark> fast", ""},
+		{"Slides", "", ""},
+		{"", "", "Tuning <mark>postgres</mark> for small machines."},
+	}
+	for i, want := range highlights {
+		r := results[i]
+		if r.TitleHighlight != want.title || r.DescriptionHighlight != want.description || r.Snippet != want.snippet {
+			t.Errorf("%s is highlighted %q, %q, %q, want %q, %q, %q", r.Url,
+				r.TitleHighlight, r.DescriptionHighlight, r.Snippet, want.title, want.description, want.snippet)
+		}
+	}
+
+	// the weights decide the order
+	weights := Weights{Url: 1, Title: 1, Description: 50, Tags: 1, Content: 100}
+	results, err = Search(db, "postgres", SearchOptions{Content: true, Weights: &weights})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if urls := urlsOf(results); len(urls) != 5 || urls[0] != "https://example.com/c" || urls[1] != "https://example.com/d" {
+		t.Errorf("ranked %v with the description and content weighted up", urls)
+	}
+
+	// highlights are only returned with markers
+	results, err = Search(db, "tuning", SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if urls := urlsOf(results); !slices.Equal(urls, []string{"https://example.com/t", "https://example.com/d"}) {
+		t.Errorf("tuning found %v", urls)
+	}
+	for _, r := range results {
+		if r.TitleHighlight != "" || r.DescriptionHighlight != "" || r.Snippet != "" {
+			t.Errorf("%s is highlighted without markers: %+v", r.Url, r)
+		}
+	}
+	results, err = Search(db, "tuning notes", SearchOptions{HighlightStart: "*", HighlightEnd: "*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].TitleHighlight != "*Tuning* *notes*" {
+		t.Errorf("tuning notes found %+v", results)
+	}
+
+	// only filters, nothing is ranked
+	results, err = Search(db, "tag:postgres", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Score != 0 || results[0].TitleHighlight != "" {
+		t.Errorf("tag:postgres found %+v", results)
+	}
+}
+
+func TestParseWeights(t *testing.T) {
+	tests := map[string]Weights{
+		"":                       DefaultWeights,
+		"title=3":                {Url: 1, Title: 3, Description: 2, Tags: 5, Content: 0.5},
+		" tags = 1 , content=2":  {Url: 1, Title: 10, Description: 2, Tags: 1, Content: 2},
+		"url=0.5,description=4,": {Url: 0.5, Title: 10, Description: 4, Tags: 5, Content: 0.5},
+	}
+	for s, want := range tests {
+		weights, err := ParseWeights(s)
+		if err != nil || weights != want {
+			t.Errorf("ParseWeights(%q) = %+v, %v, want %+v", s, weights, err, want)
+		}
+	}
+	for _, s := range []string{"title", "title=high", "body=2"} {
+		if _, err := ParseWeights(s); err == nil {
+			t.Errorf("ParseWeights(%q) did not fail", s)
+		}
+	}
+}
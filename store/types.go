@@ -1,12 +1,123 @@
 package store
 
+import "time"
+
 type Bookmark struct {
+	Id          BookmarkId
 	Url         string
 	Tags        []string
 	Title       string
 	Description string
+
+	Canonical string
+	Favicon   string
+	SiteName  string
+	Author    string
+	Published string
+	// OriginalUrl is the url as it was submitted, before following redirects
+	// and canonical links
+	OriginalUrl string
+
+	// LastStatus is the http status from the last mark check, 0 if the
+	// link could not be loaded at all
+	LastStatus  int
+	LastChecked string
+	RedirectUrl string
+
+	// Summary is generated from the page text, it is kept apart from the
+	// description so a written description is never replaced
+	Summary string
+
+	// CreatedAt is when the bookmark was saved (RFC 3339), empty for
+	// bookmarks saved before it was recorded
+	CreatedAt string
+}
+
+type ListOptions struct {
+	// Tag only lists bookmarks with this tag
+	Tag string
+	// Sort is id (the default), created, title or url, a leading - sorts
+	// descending
+	Sort string
+	// Limit is the most bookmarks listed, 0 lists all of them
+	Limit int
+	// Cursor continues the listing after a previous page, see ListPage
+	Cursor string
 }
 
 func (b Bookmark) FilterValue() string { return b.Url }
 
 type BookmarkId int64
+
+type SearchOptions struct {
+	// Raw passes the query to FTS5 unchanged instead of escaping it, queries
+	// starting with fts: are always raw
+	Raw bool
+	// Prefix matches the last word of the query as a prefix
+	Prefix bool
+	// Fuzzy adds typo tolerant matches below the exact matches
+	Fuzzy bool
+	// Limit is the most results returned, 0 returns all of them
+	Limit int
+	// Content also searches the text of the pages
+	Content bool
+	// Weights for ranking matches in each column, DefaultWeights when nil
+	Weights *Weights
+	// HighlightStart and HighlightEnd are placed around matched terms in
+	// snippets and highlights
+	HighlightStart string
+	HighlightEnd   string
+}
+
+// Weights are the bm25 column weights, a match in a column with a higher
+// weight ranks higher.
+type Weights struct {
+	Url         float64
+	Title       float64
+	Description float64
+	Tags        float64
+	// Content is applied to matches in the page text
+	Content float64
+}
+
+var DefaultWeights = Weights{
+	Url:         1,
+	Title:       10,
+	Description: 2,
+	Tags:        5,
+	Content:     0.5,
+}
+
+type SearchResult struct {
+	Bookmark
+	// Score is higher for better matches
+	Score float64 `json:"score"`
+	// Snippet is the part of the page content that matched
+	Snippet string `json:"snippet,omitempty"`
+	// TitleHighlight and DescriptionHighlight have the matches marked
+	TitleHighlight       string `json:"title_highlight,omitempty"`
+	DescriptionHighlight string `json:"description_highlight,omitempty"`
+	// Fuzzy is set on results that only matched with SearchOptions.Fuzzy
+	Fuzzy bool `json:"fuzzy,omitempty"`
+}
+
+// Archive is an offline copy of a bookmarked page.
+type Archive struct {
+	BookmarkId BookmarkId
+	// Format is either html (a single file snapshot) or text
+	Format     string
+	Content    []byte
+	ArchivedAt time.Time
+}
+
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// SavedSearch is a named query, used as @name in any search.
+type SavedSearch struct {
+	Name      string `json:"name"`
+	Query     string `json:"query"`
+	CreatedAt string `json:"created_at"`
+}
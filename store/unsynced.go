@@ -0,0 +1,52 @@
+//go:build testdb
+
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"path"
+	"sync"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+var registerUnsynced sync.Once
+
+// OpenUnsynced opens the database in dir with plain sqlite, without cr-sqlite
+// nothing is tracked or synchronized between devices. It is only built with
+// the testdb tag, for the tests of this and other packages.
+func OpenUnsynced(dir string) (*DB, error) {
+	registerUnsynced.Do(func() {
+		sql.Register("sqlite3-unsynced", &sqlite3.SQLiteDriver{
+			ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+				return conn.RegisterFunc("url_host", urlHost, true)
+			},
+		})
+	})
+
+	sqlDB, err := sql.Open("sqlite3-unsynced", path.Join(dir, "data.db"))
+	if err != nil {
+		return nil, errors.Join(errors.New("unable to open database"), err)
+	}
+	db := &DB{DB: sqlDB, StoreLoc: dir, unsynced: true}
+	if err := EnsureTables(db, Tables...); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	if err := migrateArchives(db); err != nil {
+		sqlDB.Close()
+		return nil, errors.Join(errors.New("unable to migrate archives"), err)
+	}
+
+	columns := []column{}
+	for _, c := range Columns {
+		c.crr = false
+		columns = append(columns, c)
+	}
+	if err := EnsureColumns(db, columns...); err != nil {
+		sqlDB.Close()
+		return nil, errors.Join(errors.New("unable to migrate tables"), err)
+	}
+	return db, nil
+}
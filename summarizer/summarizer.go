@@ -0,0 +1,91 @@
+package summarizer
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/lukasmwerner/mark/ollama"
+)
+
+// DefaultModel is the same model used for suggesting tags.
+const DefaultModel = "gemma:7b"
+
+// DefaultPrompt asks for a summary that reads well in a list of bookmarks,
+// {{title}}, {{url}} and {{text}} are filled in.
+const DefaultPrompt = `Summarize this web page in 2-3 sentences for someone deciding whether to read it. Reply with only the summary, no introduction.
+
+Title: {{title}}
+URL: {{url}}
+
+{{text}}`
+
+// maxTextLength keeps the page text within what small models handle well.
+const maxTextLength = 8000
+
+// Input is the page to summarize.
+type Input struct {
+	Url   string
+	Title string
+	// Text is the readable text of the page
+	Text string
+}
+
+// Provider writes a short summary of a page.
+type Provider interface {
+	Summarize(in Input) (string, error)
+}
+
+// Ollama summarizes with a local LLM through an ollama compatible api.
+type Ollama struct {
+	Client *ollama.Client
+	Model  string
+	Prompt string
+}
+
+func NewOllama(client *ollama.Client, model string) *Ollama {
+	return &Ollama{Client: client, Model: model, Prompt: DefaultPrompt}
+}
+
+// FromEnv uses ollama at OLLAMA_HOST with MARK_SUMMARY_MODEL.
+func FromEnv() Provider {
+	model := os.Getenv("MARK_SUMMARY_MODEL")
+	if model == "" {
+		model = DefaultModel
+	}
+	return NewOllama(ollama.FromEnv(), model)
+}
+
+func (o *Ollama) Summarize(in Input) (string, error) {
+	text := strings.TrimSpace(in.Text)
+	if text == "" {
+		return "", errors.New("no page text to summarize")
+	}
+	if runes := []rune(text); len(runes) > maxTextLength {
+		text = string(runes[:maxTextLength])
+	}
+
+	prompt := strings.NewReplacer(
+		"{{title}}", in.Title,
+		"{{url}}", in.Url,
+		"{{text}}", text,
+	).Replace(o.Prompt)
+
+	response, err := o.Client.Generate(o.Model, prompt, 0.2)
+	if err != nil {
+		return "", err
+	}
+	return clean(response), nil
+}
+
+// clean removes the preamble and formatting models add despite being asked
+// not to.
+func clean(response string) string {
+	response = strings.TrimSpace(response)
+	for _, prefix := range []string{"Summary:", "**Summary:**", "Here is a summary:", "Here's a summary:"} {
+		if rest, ok := strings.CutPrefix(response, prefix); ok {
+			response = rest
+		}
+	}
+	return strings.Join(strings.Fields(response), " ")
+}
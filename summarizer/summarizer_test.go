@@ -0,0 +1,95 @@
+package summarizer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lukasmwerner/mark/ollama"
+)
+
+// fakeOllama answers /api/generate with the handler, the prompts it was sent
+// are collected.
+func fakeOllama(t *testing.T, respond func(w http.ResponseWriter)) (*Ollama, *[]string) {
+	t.Helper()
+	prompts := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/generate" {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			Model  string `json:"model"`
+			Prompt string `json:"prompt"`
+			Stream bool   `json:"stream"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Model != "test-model" || req.Stream {
+			http.Error(w, `{"error": "bad request"}`, http.StatusBadRequest)
+			return
+		}
+		prompts = append(prompts, req.Prompt)
+		respond(w)
+	}))
+	t.Cleanup(server.Close)
+	return NewOllama(ollama.NewClient(server.URL), "test-model"), &prompts
+}
+
+func TestSummarize(t *testing.T) {
+	o, prompts := fakeOllama(t, func(w http.ResponseWriter) {
+		json.NewEncoder(w).Encode(map[string]any{"response": "**Summary:** Go 1.22 ships\nrange over integers. "})
+	})
+
+	summary, err := o.Summarize(Input{Url: "https://go.dev/blog/go1.22", Title: "Go 1.22 is released", Text: "Range over integers."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary != "Go 1.22 ships range over integers." {
+		t.Errorf("summary %q", summary)
+	}
+	prompt := (*prompts)[0]
+	for _, want := range []string{"Go 1.22 is released", "https://go.dev/blog/go1.22", "Range over integers."} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("the prompt is missing %q:\n%s", want, prompt)
+		}
+	}
+}
+
+func TestSummarizeServerError(t *testing.T) {
+	o, _ := fakeOllama(t, func(w http.ResponseWriter) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "model \"test-model\" not found, try pulling it first"}`))
+	})
+
+	_, err := o.Summarize(Input{Title: "Page", Text: "Some text."})
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("error %v, want ollama's message", err)
+	}
+}
+
+func TestSummarizeTruncatesText(t *testing.T) {
+	o, prompts := fakeOllama(t, func(w http.ResponseWriter) {
+		json.NewEncoder(w).Encode(map[string]any{"response": "Long."})
+	})
+
+	// multi byte runes so cutting bytes instead of runes would be noticed
+	text := strings.Repeat("é", maxTextLength) + "the end"
+	if _, err := o.Summarize(Input{Title: "Long page", Text: text}); err != nil {
+		t.Fatal(err)
+	}
+	prompt := (*prompts)[0]
+	if strings.Contains(prompt, "the end") {
+		t.Error("the text was not truncated")
+	}
+	if n := strings.Count(prompt, "é"); n != maxTextLength {
+		t.Errorf("the prompt has %d runes of the text, want %d", n, maxTextLength)
+	}
+
+	if _, err := o.Summarize(Input{Title: "Empty page", Text: "  \n"}); err == nil {
+		t.Error("a page without text was summarized")
+	}
+	if len(*prompts) != 1 {
+		t.Errorf("ollama was asked %d times", len(*prompts))
+	}
+}
@@ -0,0 +1,160 @@
+package tagger
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// DomainTags are tags for well known sites, a rule for example.com also
+// applies to its subdomains.
+var DomainTags = map[string][]string{
+	"github.com":             {"github", "code"},
+	"gitlab.com":             {"gitlab", "code"},
+	"codeberg.org":           {"code"},
+	"stackoverflow.com":      {"stackoverflow"},
+	"stackexchange.com":      {"stackexchange"},
+	"youtube.com":            {"video"},
+	"youtu.be":               {"video"},
+	"vimeo.com":              {"video"},
+	"arxiv.org":              {"paper"},
+	"wikipedia.org":          {"wikipedia"},
+	"news.ycombinator.com":   {"hn"},
+	"reddit.com":             {"reddit"},
+	"medium.com":             {"blog"},
+	"substack.com":           {"newsletter"},
+	"pkg.go.dev":             {"go", "docs"},
+	"docs.rs":                {"rust", "docs"},
+	"crates.io":              {"rust"},
+	"npmjs.com":              {"javascript"},
+	"pypi.org":               {"python"},
+	"developer.mozilla.org":  {"web", "docs"},
+	"amazon.com":             {"shopping"},
+	"maps.google.com":        {"maps"},
+	"openstreetmap.org":      {"maps"},
+	"figma.com":              {"design"},
+	"dribbble.com":           {"design"},
+	"spotify.com":            {"music"},
+	"bandcamp.com":           {"music"},
+	"soundcloud.com":         {"music"},
+	"twitter.com":            {"twitter"},
+	"x.com":                  {"twitter"},
+	"mastodon.social":        {"mastodon"},
+	"bsky.app":               {"bluesky"},
+	"linkedin.com":           {"linkedin"},
+	"huggingface.co":         {"ml"},
+	"kaggle.com":             {"ml", "data"},
+	"coursera.org":           {"course"},
+	"udemy.com":              {"course"},
+	"docs.google.com":        {"docs"},
+	"drive.google.com":       {"drive"},
+	"notion.so":              {"notion"},
+	"scholar.google.com":     {"paper"},
+	"researchgate.net":       {"paper"},
+	"dev.to":                 {"blog"},
+	"hashnode.dev":           {"blog"},
+	"lobste.rs":              {"lobsters"},
+	"archive.org":            {"archive"},
+	"web.archive.org":        {"archive"},
+	"play.google.com":        {"app"},
+	"apps.apple.com":         {"app"},
+	"store.steampowered.com": {"games"},
+}
+
+// Heuristic suggests tags without a model: tags already in use that appear in
+// the page's title, description or url, rules for well known sites and the
+// page's own keywords.
+type Heuristic struct {
+	Domains map[string][]string
+}
+
+func NewHeuristic() *Heuristic {
+	return &Heuristic{Domains: DomainTags}
+}
+
+// scores of where a suggestion came from, a tag from more than one source
+// adds them up.
+const (
+	vocabularyScore = 3
+	domainScore     = 2
+	keywordScore    = 1
+)
+
+// Suggest returns the best suggestions first, ties are sorted by name so the
+// same page always gets the same suggestions in the same order.
+func (h *Heuristic) Suggest(in Input, vocabulary []string) ([]string, error) {
+	tags := []string{}
+	scores := map[string]int{}
+	// the first spelling of a tag is kept, normalize picks the final one
+	add := func(tag string, score int) {
+		key := strings.ToLower(tag)
+		if _, ok := scores[key]; !ok {
+			tags = append(tags, tag)
+		}
+		scores[key] += score
+	}
+
+	host := ""
+	if u, err := url.Parse(in.Url); err == nil {
+		host = strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	}
+
+	// reusing tags keeps the collection tidy, so they score the highest
+	words := wordSet(in.Title + " " + in.Description + " " + in.Url + " " + strings.Join(in.Keywords, " "))
+	for _, tag := range vocabulary {
+		if containsWords(words, tag) {
+			add(tag, vocabularyScore)
+		}
+	}
+
+	for domain, domainTags := range h.Domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			for _, tag := range domainTags {
+				add(tag, domainScore)
+			}
+		}
+	}
+
+	for _, keyword := range in.Keywords {
+		add(keyword, keywordScore)
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		a, b := strings.ToLower(tags[i]), strings.ToLower(tags[j])
+		if scores[a] != scores[b] {
+			return scores[a] > scores[b]
+		}
+		return a < b
+	})
+	return tags, nil
+}
+
+func wordSet(text string) map[string]bool {
+	words := map[string]bool{}
+	for _, word := range splitWords(text) {
+		words[word] = true
+	}
+	return words
+}
+
+// containsWords reports whether every word of a (possibly multi word) tag is
+// in the set.
+func containsWords(words map[string]bool, tag string) bool {
+	parts := splitWords(tag)
+	if len(parts) == 0 {
+		return false
+	}
+	for _, part := range parts {
+		if !words[part] {
+			return false
+		}
+	}
+	return true
+}
+
+func splitWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '+' && r != '#'
+	})
+}
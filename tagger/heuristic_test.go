@@ -0,0 +1,33 @@
+package tagger
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHeuristicSuggestOrder(t *testing.T) {
+	h := &Heuristic{Domains: map[string][]string{
+		"github.com":      {"github", "code"},
+		"gist.github.com": {"snippet"},
+		"example.com":     {"example"},
+	}}
+	in := Input{
+		Url:      "https://gist.github.com/someone/postgres-backup",
+		Title:    "Postgres backup script",
+		Keywords: []string{"Backup", "sql", "code"},
+	}
+	vocabulary := []string{"postgres", "backup", "rust"}
+
+	// backup is in use and a keyword, code is a site tag and a keyword which
+	// ties it with postgres
+	want := []string{"backup", "code", "postgres", "github", "snippet", "sql"}
+	for range 20 {
+		tags, err := h.Suggest(in, vocabulary)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(tags, want) {
+			t.Fatalf("suggested %v, want %v", tags, want)
+		}
+	}
+}
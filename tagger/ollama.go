@@ -0,0 +1,83 @@
+package tagger
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/lukasmwerner/mark/ollama"
+)
+
+// DefaultModel matches the browser extension's default.
+const DefaultModel = "gemma:7b"
+
+// DefaultPrompt is the browser extension's prompt with the tags in use added,
+// {{title}}, {{description}}, {{url}} and {{tags}} are filled in.
+const DefaultPrompt = `Generate 3-5 relevant tags for this content. Return only the tags as a JSON array of strings. No additional explanation needed.
+Prefer these existing tags when they fit: {{tags}}
+
+Title: {{title}}
+Description: {{description}}
+URL: {{url}}`
+
+// maxPromptTags keeps the prompt short for people with large collections.
+const maxPromptTags = 100
+
+// Ollama suggests tags with a local LLM.
+type Ollama struct {
+	Client *ollama.Client
+	Model  string
+	Prompt string
+}
+
+func NewOllama(client *ollama.Client, model string) *Ollama {
+	return &Ollama{Client: client, Model: model, Prompt: DefaultPrompt}
+}
+
+func (o *Ollama) Suggest(in Input, vocabulary []string) ([]string, error) {
+	if len(vocabulary) > maxPromptTags {
+		vocabulary = vocabulary[:maxPromptTags]
+	}
+	prompt := strings.NewReplacer(
+		"{{title}}", in.Title,
+		"{{description}}", in.Description,
+		"{{url}}", in.Url,
+		"{{tags}}", strings.Join(vocabulary, ", "),
+	).Replace(o.Prompt)
+
+	response, err := o.Client.Generate(o.Model, prompt, 0.3)
+	if err != nil {
+		return nil, err
+	}
+	return parseTags(response), nil
+}
+
+var (
+	jsonArray = regexp.MustCompile(`(?s)\[.*\]`)
+	tagToken  = regexp.MustCompile("\"([^\"]+)\"|'([^']+)'|`([^`]+)`|([a-zA-Z0-9-_]+)")
+)
+
+// parseTags reads the JSON array out of the response, models like to wrap it
+// in markdown or explain themselves, and falls back to picking out words.
+func parseTags(response string) []string {
+	var tags []string
+	if match := jsonArray.FindString(response); match != "" {
+		if err := json.Unmarshal([]byte(match), &tags); err == nil {
+			return tags
+		}
+	}
+	if err := json.Unmarshal([]byte(response), &tags); err == nil {
+		return tags
+	}
+
+	tags = []string{}
+	for _, match := range tagToken.FindAllStringSubmatch(response, -1) {
+		for _, group := range match[1:] {
+			if group != "" {
+				tags = append(tags, group)
+				break
+			}
+		}
+	}
+	return tags
+}
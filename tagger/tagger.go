@@ -0,0 +1,104 @@
+package tagger
+
+import (
+	"os"
+	"strings"
+
+	"github.com/lukasmwerner/mark/ollama"
+)
+
+// DefaultMaxTags is how many tags are suggested at most.
+const DefaultMaxTags = 5
+
+// Input is what is known about a page, everything but the url is optional.
+type Input struct {
+	Url         string   `json:"url"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Keywords    []string `json:"keywords,omitempty"`
+}
+
+// Suggester comes up with tags for a page. The vocabulary is the tags already
+// in use, which are preferred over new ones.
+type Suggester interface {
+	Suggest(in Input, vocabulary []string) ([]string, error)
+}
+
+// Tagger asks the LLM first and falls back to the heuristic when it is not
+// configured or can not be reached.
+type Tagger struct {
+	// LLM is skipped when nil
+	LLM       Suggester
+	Heuristic Suggester
+	MaxTags   int
+}
+
+// FromEnv uses ollama at OLLAMA_HOST with MARK_TAG_MODEL (gemma:7b by
+// default, like the browser extension). MARK_TAG_MODEL=none only uses the
+// heuristic.
+func FromEnv() *Tagger {
+	t := &Tagger{Heuristic: NewHeuristic(), MaxTags: DefaultMaxTags}
+	model := os.Getenv("MARK_TAG_MODEL")
+	if model == "" {
+		model = DefaultModel
+	}
+	if model != "none" {
+		t.LLM = NewOllama(ollama.FromEnv(), model)
+	}
+	return t
+}
+
+// Suggest returns the suggestions along with the error from the LLM, if it
+// failed, so callers can mention why the suggestions may be worse than usual.
+func (t *Tagger) Suggest(in Input, vocabulary []string) ([]string, error) {
+	var llmErr error
+	if t.LLM != nil {
+		tags, err := t.LLM.Suggest(in, vocabulary)
+		if err == nil && len(tags) > 0 {
+			return t.limit(normalize(tags, vocabulary)), nil
+		}
+		llmErr = err
+	}
+
+	tags, err := t.Heuristic.Suggest(in, vocabulary)
+	if err != nil {
+		return nil, err
+	}
+	return t.limit(normalize(tags, vocabulary)), llmErr
+}
+
+func (t *Tagger) limit(tags []string) []string {
+	if t.MaxTags > 0 && len(tags) > t.MaxTags {
+		return tags[:t.MaxTags]
+	}
+	return tags
+}
+
+// normalize drops duplicates and anything that can not be stored as a tag,
+// tags matching one in the vocabulary take its spelling.
+func normalize(tags, vocabulary []string) []string {
+	known := map[string]string{}
+	for _, tag := range vocabulary {
+		known[strings.ToLower(tag)] = tag
+	}
+
+	out := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		// commas seperate tags in the store
+		tag = strings.Join(strings.Fields(strings.ReplaceAll(tag, ",", " ")), " ")
+		tag = strings.Trim(tag, `#"'`+"`")
+		key := strings.ToLower(tag)
+		if tag == "" || len(tag) > 40 || seen[key] {
+			continue
+		}
+		seen[key] = true
+		if existing, ok := known[key]; ok {
+			tag = existing
+		} else {
+			tag = key
+		}
+		out = append(out, tag)
+	}
+	return out
+}
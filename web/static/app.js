@@ -0,0 +1,296 @@
+// The web interface of mark server, it only uses the versioned api with the
+// key kept in localStorage.
+"use strict";
+
+const api = "/api/v1";
+const keyStorage = "mark-key";
+
+const $ = (id) => document.getElementById(id);
+
+let editing = null;
+let searchTimer = null;
+let searchRequest = 0;
+
+function key() {
+  return localStorage.getItem(keyStorage) || "";
+}
+
+// request calls the api and returns the decoded body, errors are thrown with
+// the message from the server.
+async function request(method, path, body) {
+  const options = { method, headers: { Authorization: `Bearer ${key()}` } };
+  if (body !== undefined) {
+    options.headers["Content-Type"] = method === "PATCH" ? "application/merge-patch+json" : "application/json";
+    options.body = JSON.stringify(body);
+  }
+  const response = await fetch(api + path, options);
+  if (response.status === 401) {
+    const message = "Your key was not accepted, log in again";
+    logout(message);
+    throw new Error(message);
+  }
+  if (response.status === 204) {
+    return null;
+  }
+  const data = await response.json().catch(() => ({}));
+  if (!response.ok) {
+    throw new Error(data.error || `Request failed with ${response.status}`);
+  }
+  return data;
+}
+
+function status(message, isError) {
+  $("status").textContent = message || "";
+  $("status").className = isError ? "error" : "";
+}
+
+function show(loggedIn) {
+  $("login").hidden = loggedIn;
+  $("main").hidden = !loggedIn;
+  $("nav").hidden = !loggedIn;
+}
+
+function logout(message) {
+  localStorage.removeItem(keyStorage);
+  show(false);
+  status(message, Boolean(message));
+}
+
+async function login(event) {
+  event.preventDefault();
+  localStorage.setItem(keyStorage, $("key").value.trim());
+  $("key").value = "";
+  try {
+    await start();
+  } catch (error) {
+    status(error.message, true);
+  }
+}
+
+async function start() {
+  await loadTags();
+  show(true);
+  status("");
+  search();
+}
+
+async function loadTags() {
+  const tags = await request("GET", "/tags");
+  const select = $("tag");
+  const selected = select.value;
+  select.replaceChildren(new Option("All tags", ""));
+  for (const tag of tags) {
+    select.append(new Option(`${tag.name} (${tag.count})`, tag.name));
+  }
+  select.value = selected;
+}
+
+function query() {
+  let q = $("query").value;
+  const tag = $("tag").value;
+  if (tag) {
+    q += ` tag:${tag.includes(" ") ? `"${tag}"` : tag}`;
+  }
+  return q.trim();
+}
+
+function scheduleSearch() {
+  clearTimeout(searchTimer);
+  searchTimer = setTimeout(search, 200);
+}
+
+async function search() {
+  // answers to older searches that come in late are ignored
+  const current = ++searchRequest;
+  const params = new URLSearchParams({ q: query(), prefix: "true" });
+  try {
+    const results = await request("GET", `/bookmarks/search?${params}`);
+    if (current === searchRequest) {
+      render(results);
+    }
+  } catch (error) {
+    status(error.message, true);
+  }
+}
+
+function hostname(url) {
+  try {
+    return new URL(url).hostname;
+  } catch {
+    return url;
+  }
+}
+
+function render(bookmarks) {
+  const list = $("results");
+  list.replaceChildren();
+  $("empty").hidden = bookmarks.length > 0;
+
+  for (const bookmark of bookmarks) {
+    const item = document.createElement("li");
+
+    const title = document.createElement("div");
+    title.className = "title";
+    if (bookmark.favicon) {
+      const icon = document.createElement("img");
+      icon.src = bookmark.favicon;
+      icon.alt = "";
+      icon.loading = "lazy";
+      title.append(icon);
+    }
+    const link = document.createElement("a");
+    link.href = bookmark.url;
+    link.rel = "noopener noreferrer";
+    link.target = "_blank";
+    link.textContent = bookmark.title || bookmark.url;
+    title.append(link);
+    item.append(title);
+
+    const host = document.createElement("div");
+    host.className = "host";
+    host.textContent = hostname(bookmark.url);
+    item.append(host);
+
+    if (bookmark.description) {
+      const description = document.createElement("p");
+      description.className = "description";
+      description.textContent = bookmark.description;
+      item.append(description);
+    }
+    if (bookmark.summary) {
+      const summary = document.createElement("p");
+      summary.className = "summary";
+      summary.textContent = bookmark.summary;
+      item.append(summary);
+    }
+
+    const tags = document.createElement("div");
+    tags.className = "tags";
+    for (const tag of bookmark.tags) {
+      const button = document.createElement("button");
+      button.type = "button";
+      button.textContent = tag;
+      button.addEventListener("click", () => {
+        $("tag").value = tag;
+        search();
+      });
+      tags.append(button);
+    }
+    item.append(tags);
+
+    const actions = document.createElement("div");
+    actions.className = "actions";
+    const edit = document.createElement("button");
+    edit.type = "button";
+    edit.textContent = "Edit";
+    edit.addEventListener("click", () => openEditor(bookmark));
+    const remove = document.createElement("button");
+    remove.type = "button";
+    remove.textContent = "Delete";
+    remove.addEventListener("click", () => deleteBookmark(bookmark));
+    actions.append(edit, remove);
+    item.append(actions);
+
+    list.append(item);
+  }
+}
+
+function splitTags(value) {
+  return value
+    .split(",")
+    .map((tag) => tag.trim())
+    .filter((tag) => tag !== "");
+}
+
+// openEditor edits the bookmark, or adds a new one filled in with it when it
+// has no id.
+function openEditor(bookmark) {
+  editing = bookmark.id ? bookmark : null;
+  $("editor-title").textContent = editing ? "Edit bookmark" : "Add bookmark";
+  $("edit-url").value = bookmark.url || "";
+  $("edit-title").value = bookmark.title || "";
+  $("edit-description").value = bookmark.description || "";
+  $("edit-tags").value = (bookmark.tags || []).join(", ");
+  $("enrich-label").hidden = Boolean(editing);
+  $("editor-error").textContent = "";
+  $("editor").showModal();
+}
+
+async function saveBookmark(event) {
+  event.preventDefault();
+  const fields = {
+    url: $("edit-url").value.trim(),
+    title: $("edit-title").value.trim(),
+    description: $("edit-description").value.trim(),
+    tags: splitTags($("edit-tags").value),
+  };
+
+  try {
+    if (editing) {
+      // only what changed is sent, as a merge patch
+      const patch = {};
+      for (const [name, value] of Object.entries(fields)) {
+        if (JSON.stringify(value) !== JSON.stringify(editing[name])) {
+          patch[name] = value;
+        }
+      }
+      await request("PATCH", `/bookmarks/${editing.id}`, patch);
+      status("Saved");
+    } else {
+      const enrich = $("edit-enrich").checked ? "?enrich=true" : "";
+      await request("POST", `/bookmarks${enrich}`, fields);
+      status(enrich ? "Added, the title and description are filled in shortly" : "Added");
+    }
+    $("editor").close();
+    await loadTags();
+    search();
+  } catch (error) {
+    $("editor-error").textContent = error.message;
+  }
+}
+
+async function deleteBookmark(bookmark) {
+  if (!confirm(`Delete ${bookmark.title || bookmark.url}?`)) {
+    return;
+  }
+  try {
+    await request("DELETE", `/bookmarks/${bookmark.id}`);
+    status("Deleted");
+    await loadTags();
+    search();
+  } catch (error) {
+    status(error.message, true);
+  }
+}
+
+// bookmarklet opens the save page with the page it was clicked on, which uses
+// the key this browser is logged in with.
+function bookmarklet() {
+  const target = `${location.origin}/save`;
+  return (
+    "javascript:(function(){window.open(" +
+    `'${target}?url='+encodeURIComponent(location.href)+'&title='+encodeURIComponent(document.title),` +
+    "'mark','width=560,height=720')})()"
+  );
+}
+
+document.addEventListener("DOMContentLoaded", () => {
+  $("login-form").addEventListener("submit", login);
+  $("logout").addEventListener("click", () => logout());
+  $("query").addEventListener("input", scheduleSearch);
+  $("tag").addEventListener("change", search);
+  $("show-add").addEventListener("click", () => openEditor({}));
+  $("editor-form").addEventListener("submit", saveBookmark);
+  $("editor-cancel").addEventListener("click", () => $("editor").close());
+  $("show-bookmarklet").addEventListener("click", () => {
+    $("bookmarklet-link").href = bookmarklet();
+    $("bookmarklet").showModal();
+  });
+  $("bookmarklet-close").addEventListener("click", () => $("bookmarklet").close());
+
+  if (!key()) {
+    show(false);
+    return;
+  }
+  start().catch((error) => status(error.message, true));
+});
@@ -0,0 +1,200 @@
+// The page the bookmarklet opens, /save?url=...&title=...#key=... fills in a
+// bookmark for the page with what the server finds on it. The key after the #
+// is never sent to the server, without one the key from the web interface is
+// used.
+"use strict";
+
+const api = "/api/v1";
+const keyStorage = "mark-key";
+
+const $ = (id) => document.getElementById(id);
+
+let key = "";
+let existing = null;
+
+async function request(method, path, body) {
+  const options = { method, headers: { Authorization: `Bearer ${key}` } };
+  if (body !== undefined) {
+    options.headers["Content-Type"] = method === "PATCH" ? "application/merge-patch+json" : "application/json";
+    options.body = JSON.stringify(body);
+  }
+  const response = await fetch(api + path, options);
+  if (response.status === 401) {
+    const message = "Your key was not accepted, log in again";
+    showLogin(message);
+    throw new Error(message);
+  }
+  const data = await response.json().catch(() => ({}));
+  if (!response.ok) {
+    throw new Error(data.error || `Request failed with ${response.status}`);
+  }
+  return data;
+}
+
+function status(message, isError) {
+  $("status").textContent = message || "";
+  $("status").className = isError ? "error" : "";
+}
+
+function showLogin(message) {
+  localStorage.removeItem(keyStorage);
+  $("login").hidden = false;
+  $("main").hidden = true;
+  status(message, Boolean(message));
+}
+
+async function login(event) {
+  event.preventDefault();
+  key = $("key").value.trim();
+  localStorage.setItem(keyStorage, key);
+  $("key").value = "";
+  $("login").hidden = true;
+  start();
+}
+
+function splitTags(value) {
+  return value
+    .split(",")
+    .map((tag) => tag.trim())
+    .filter((tag) => tag !== "");
+}
+
+// fill sets a field unless something was already typed or passed in.
+function fill(id, value) {
+  if (value && !$(id).value.trim()) {
+    $(id).value = value;
+  }
+}
+
+async function start() {
+  $("main").hidden = false;
+  status("Looking at the page...");
+  const submitted = $("save-url").value.trim();
+  if (!submitted) {
+    status("");
+    return;
+  }
+
+  let page = { url: submitted, title: "", description: "", keywords: [] };
+  try {
+    page = await request("GET", `/pages?${new URLSearchParams({ url: submitted })}`);
+  } catch (error) {
+    // the page can still be saved as it is, the server fills it in later
+    status(error.message, true);
+    if (!$("login").hidden) {
+      return;
+    }
+  }
+
+  if (page.existing) {
+    existing = page.existing;
+    $("save-title").textContent = "Already saved";
+    $("save-submit").textContent = "Update";
+    $("save-url").value = existing.url;
+    $("save-page-title").value = existing.title;
+    $("save-description").value = existing.description;
+    $("save-tags").value = existing.tags.join(", ");
+  } else {
+    $("save-url").value = page.url;
+    fill("save-page-title", page.title);
+    fill("save-description", page.description);
+  }
+  if ($("status").className !== "error") {
+    status("");
+  }
+  suggest(page.keywords);
+}
+
+async function suggest(keywords) {
+  try {
+    const suggestions = await request("POST", "/tags/suggest?fetch=false", {
+      url: $("save-url").value.trim(),
+      title: $("save-page-title").value.trim(),
+      description: $("save-description").value.trim(),
+      keywords,
+    });
+    renderSuggestions(suggestions.tags);
+  } catch (error) {
+    status(error.message, true);
+  }
+}
+
+function renderSuggestions(tags) {
+  const list = $("suggestions");
+  list.replaceChildren();
+  const chosen = splitTags($("save-tags").value);
+  for (const tag of tags) {
+    if (chosen.includes(tag)) {
+      continue;
+    }
+    const button = document.createElement("button");
+    button.type = "button";
+    button.textContent = `+ ${tag}`;
+    button.addEventListener("click", () => {
+      $("save-tags").value = [...splitTags($("save-tags").value), tag].join(", ");
+      button.remove();
+    });
+    list.append(button);
+  }
+}
+
+async function save(event) {
+  event.preventDefault();
+  const fields = {
+    url: $("save-url").value.trim(),
+    title: $("save-page-title").value.trim(),
+    description: $("save-description").value.trim(),
+    tags: splitTags($("save-tags").value),
+  };
+
+  try {
+    if (existing) {
+      await request("PATCH", `/bookmarks/${existing.id}`, fields);
+    } else {
+      // what was left empty is filled in from the page after saving
+      await request("POST", "/bookmarks?enrich=true", fields);
+    }
+  } catch (error) {
+    $("save-error").textContent = error.message;
+    return;
+  }
+  $("main").hidden = true;
+  status(existing ? "Updated" : "Saved");
+  done();
+}
+
+// done closes the window the bookmarklet opened, pages opened some other way
+// stay open.
+function done() {
+  if (window.opener) {
+    setTimeout(() => window.close(), 600);
+  }
+}
+
+document.addEventListener("DOMContentLoaded", () => {
+  $("login-form").addEventListener("submit", login);
+  $("save-form").addEventListener("submit", save);
+  $("save-cancel").addEventListener("click", () => {
+    if (window.opener) {
+      window.close();
+    } else {
+      history.back();
+    }
+  });
+
+  const params = new URLSearchParams(location.search);
+  $("save-url").value = params.get("url") || "";
+  $("save-page-title").value = params.get("title") || "";
+
+  // the key is taken out of the address bar so it does not end up in the
+  // history of the browser
+  const hash = new URLSearchParams(location.hash.slice(1));
+  key = hash.get("key") || localStorage.getItem(keyStorage) || "";
+  history.replaceState(null, "", location.pathname + location.search);
+
+  if (!key) {
+    showLogin();
+    return;
+  }
+  start();
+});
@@ -0,0 +1,35 @@
+// Package web is the browser interface served by mark server at /, along with
+// the /save page the bookmarklet opens. It only talks to the server through
+// the versioned api.
+package web
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var static embed.FS
+
+// Handler serves the interface. Nothing here needs a key, the page asks for
+// one and keeps it in the browser.
+func Handler() http.Handler {
+	files, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err)
+	}
+	fileServer := http.FileServer(http.FS(files))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// favicons come from the bookmarked sites, everything else from here
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; img-src * data:; frame-ancestors 'none'")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		if r.URL.Path == "/save" {
+			// where the bookmarklet from mark bookmarklet points
+			http.ServeFileFS(w, r, files, "save.html")
+			return
+		}
+		fileServer.ServeHTTP(w, r)
+	})
+}